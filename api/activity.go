@@ -0,0 +1,31 @@
+package api
+
+import "sort"
+
+// NewActivity merges the records of an address into its activity feed, ordered by block.
+// Within a block, transactions come first, then token and NFT transfers, then withdrawals.
+func NewActivity(txs []Transaction, tokenTransfers []TokenTransfer, nftTransfers []NFTTransfer, withdrawals []Withdrawal) []Activity {
+	activity := make([]Activity, 0, len(txs)+len(tokenTransfers)+len(nftTransfers)+len(withdrawals))
+
+	for i := range txs {
+		activity = append(activity, Activity{Kind: ActivityTransaction, BlockNumber: txs[i].BlockNumber, Timestamp: txs[i].Timestamp, Transaction: &txs[i]})
+	}
+
+	for i := range tokenTransfers {
+		activity = append(activity, Activity{Kind: ActivityTokenTransfer, BlockNumber: tokenTransfers[i].BlockNumber, Timestamp: tokenTransfers[i].Timestamp, TokenTransfer: &tokenTransfers[i]})
+	}
+
+	for i := range nftTransfers {
+		activity = append(activity, Activity{Kind: ActivityNFTTransfer, BlockNumber: nftTransfers[i].BlockNumber, Timestamp: nftTransfers[i].Timestamp, NFTTransfer: &nftTransfers[i]})
+	}
+
+	for i := range withdrawals {
+		activity = append(activity, Activity{Kind: ActivityWithdrawal, BlockNumber: withdrawals[i].BlockNumber, Timestamp: withdrawals[i].Timestamp, Withdrawal: &withdrawals[i]})
+	}
+
+	sort.SliceStable(activity, func(i, j int) bool {
+		return activity[i].BlockNumber < activity[j].BlockNumber
+	})
+
+	return activity
+}
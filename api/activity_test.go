@@ -0,0 +1,41 @@
+package api_test
+
+import (
+	"testing"
+
+	"github.com/devshark/tx-parser-go/api"
+)
+
+func TestNewActivity(t *testing.T) {
+	activity := api.NewActivity(
+		[]api.Transaction{{Hash: "0x1", BlockNumber: 1}, {Hash: "0x3", BlockNumber: 3}},
+		[]api.TokenTransfer{{TransactionHash: "0x3", BlockNumber: 3}},
+		[]api.NFTTransfer{{TransactionHash: "0x2", BlockNumber: 2}},
+		[]api.Withdrawal{{Index: 7, BlockNumber: 1}},
+	)
+
+	expected := []struct {
+		kind        api.ActivityKind
+		blockNumber int64
+	}{
+		{api.ActivityTransaction, 1},
+		{api.ActivityWithdrawal, 1},
+		{api.ActivityNFTTransfer, 2},
+		{api.ActivityTransaction, 3},
+		{api.ActivityTokenTransfer, 3},
+	}
+
+	if len(activity) != len(expected) {
+		t.Fatalf("Expected %d entries, got %d", len(expected), len(activity))
+	}
+
+	for i, e := range expected {
+		if activity[i].Kind != e.kind || activity[i].BlockNumber != e.blockNumber {
+			t.Errorf("Expected entry %d to be a %s of block %d, got a %s of block %d", i, e.kind, e.blockNumber, activity[i].Kind, activity[i].BlockNumber)
+		}
+	}
+
+	if activity[1].Withdrawal == nil || activity[1].Withdrawal.Index != 7 || activity[1].Transaction != nil {
+		t.Errorf("Expected only the withdrawal to be set on its entry, got %+v", activity[1])
+	}
+}
@@ -1,6 +1,9 @@
 package api
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -12,6 +15,7 @@ type Block struct {
 	Nonce        string        `json:"nonce"`
 	Timestamp    time.Time     `json:"timestamp"`
 	Transactions []Transaction `json:"transactions"`
+	Withdrawals  []Withdrawal  `json:"withdrawals,omitempty"` // since the Shanghai fork
 	// Add more fields as needed, such as:
 	// GasUsed     int64       `json:"gasUsed"`
 	// GasLimit    int64       `json:"gasLimit"`
@@ -19,19 +23,251 @@ type Block struct {
 	// Miner        string         `json:"miner"`
 }
 
+// TransactionStatus is the finality of the block a transaction was included in
+type TransactionStatus string
+
+const (
+	StatusPending   TransactionStatus = "pending-confirmation"
+	StatusConfirmed TransactionStatus = "confirmed"
+	StatusFinalized TransactionStatus = "finalized"
+	StatusOrphaned  TransactionStatus = "orphaned"
+)
+
+// IsConfirmed reports whether the transaction reached the confirmation depth or finality
+func (s TransactionStatus) IsConfirmed() bool {
+	return s == StatusConfirmed || s == StatusFinalized
+}
+
+// TransactionType is the EIP-2718 type of a transaction
+type TransactionType uint8
+
+const (
+	TxTypeLegacy     TransactionType = 0
+	TxTypeAccessList TransactionType = 1 // EIP-2930
+	TxTypeDynamicFee TransactionType = 2 // EIP-1559
+	TxTypeBlob       TransactionType = 3 // EIP-4844
+)
+
+func (t TransactionType) String() string {
+	switch t {
+	case TxTypeLegacy:
+		return "legacy"
+	case TxTypeAccessList:
+		return "access-list"
+	case TxTypeDynamicFee:
+		return "dynamic-fee"
+	case TxTypeBlob:
+		return "blob"
+	default:
+		return fmt.Sprintf("type-%d", uint8(t))
+	}
+}
+
+// TransactionKind tells the transactions sent by accounts apart from the value
+// transfers made by contracts while executing them
+type TransactionKind string
+
+const (
+	KindExternal TransactionKind = "external"
+	KindInternal TransactionKind = "internal"
+)
+
 // Transaction represents an Ethereum transaction within a block
 type Transaction struct {
-	Hash             string `json:"hash"`
-	From             string `json:"from"`
-	To               string `json:"to"`
-	Value            int64  `json:"value"`
-	Input            string `json:"input"`
-	Nonce            string `json:"nonce"`
-	BlockHash        string `json:"blockHash"`
-	TransactionIndex uint   `json:"transactionIndex"`
-	// Gas              uint64 `json:"gas"`
-	// GasPrice         int64  `json:"gasPrice"`
-	// BlockNumber      int64  `json:"blockNumber"`
+	Hash     string          `json:"hash"`
+	Kind     TransactionKind `json:"kind"`
+	Type     TransactionType `json:"type"`
+	ChainID  int64           `json:"chainId,omitempty"` // not set on legacy transactions predating EIP-155
+	From     string          `json:"from"`
+	To       string          `json:"to"`
+	Value    BigInt          `json:"value"` // in wei
+	Input    string          `json:"input"`
+	Nonce    string          `json:"nonce"`
+	Gas      uint64          `json:"gas"`      // gas limit
+	GasPrice BigInt          `json:"gasPrice"` // in wei, the effective price for dynamic fee transactions
+	// only set on dynamic fee and blob transactions, in wei
+	MaxFeePerGas         *BigInt `json:"maxFeePerGas,omitempty"`
+	MaxPriorityFeePerGas *BigInt `json:"maxPriorityFeePerGas,omitempty"`
+	// only set on blob transactions, in wei
+	MaxFeePerBlobGas *BigInt   `json:"maxFeePerBlobGas,omitempty"`
+	BlockHash        string    `json:"blockHash"`
+	BlockNumber      int64     `json:"blockNumber"`
+	Timestamp        time.Time `json:"timestamp"` // of the block
+	TransactionIndex uint      `json:"transactionIndex"`
+	// Status is set by the parser and moves along as the block gets confirmations
+	Status TransactionStatus `json:"status,omitempty"`
+	// Receipt is fetched for the transactions of subscribed addresses, nil if the node could not provide it
+	Receipt *Receipt `json:"receipt,omitempty"`
+	// only set on internal transactions, which share the hash of the transaction they were made by.
+	// CallType is the opcode of the call, such as "call" or "create", and TraceAddress the position
+	// of the call in the call tree of the transaction.
+	CallType     string `json:"callType,omitempty"`
+	TraceAddress []int  `json:"traceAddress,omitempty"`
+	// ContractCreation is set on the transactions that deploy a contract, which have no recipient.
+	// ContractAddress is the address of the deployed contract, known once the receipt is fetched.
+	ContractCreation bool   `json:"contractCreation,omitempty"`
+	ContractAddress  string `json:"contractAddress,omitempty"`
+}
+
+// Key identifies the transaction among the others, internal transactions
+// are told apart from the transaction they were made by with their trace address
+func (tx Transaction) Key() string {
+	if tx.Kind != KindInternal {
+		return strings.ToLower(tx.Hash)
+	}
+
+	path := make([]string, len(tx.TraceAddress))
+	for i, index := range tx.TraceAddress {
+		path[i] = strconv.Itoa(index)
+	}
+
+	return strings.ToLower(tx.Hash) + ":" + strings.Join(path, ".")
+}
+
+// Failed reports whether the transaction is known to have reverted
+func (tx Transaction) Failed() bool {
+	return tx.Receipt != nil && tx.Receipt.Status == ReceiptFailed
+}
+
+// ReceiptStatus is the outcome of the execution of a transaction
+type ReceiptStatus string
+
+const (
+	ReceiptSuccess ReceiptStatus = "success"
+	ReceiptFailed  ReceiptStatus = "failed"
+)
+
+// Receipt is the result of the execution of a transaction
+type Receipt struct {
+	TransactionHash   string        `json:"transactionHash"`
+	Status            ReceiptStatus `json:"status,omitempty"` // not reported before the Byzantium fork
+	GasUsed           uint64        `json:"gasUsed"`
+	EffectiveGasPrice BigInt        `json:"effectiveGasPrice"` // in wei
+	Fee               BigInt        `json:"fee"`               // actually paid, gasUsed * effectiveGasPrice in wei
+	ContractAddress   string        `json:"contractAddress,omitempty"`
+	Logs              []Log         `json:"logs"`
+}
+
+// Succeeded reports whether the transaction was executed without reverting
+func (r *Receipt) Succeeded() bool {
+	return r.Status == ReceiptSuccess
+}
+
+// Log is an event emitted by a contract during the execution of a transaction
+type Log struct {
+	Address         string   `json:"address"`
+	Topics          []string `json:"topics"`
+	Data            string   `json:"data"`
+	LogIndex        uint     `json:"logIndex"`
+	TransactionHash string   `json:"transactionHash,omitempty"`
+	BlockHash       string   `json:"blockHash,omitempty"`
+	BlockNumber     int64    `json:"blockNumber,omitempty"`
+}
+
+// TokenTransfer is an ERC-20 Transfer event involving a subscribed address
+type TokenTransfer struct {
+	Contract        string            `json:"contract"` // address of the token
+	From            string            `json:"from"`
+	To              string            `json:"to"`
+	Amount          BigInt            `json:"amount"` // in the token's smallest unit
+	TransactionHash string            `json:"transactionHash"`
+	LogIndex        uint              `json:"logIndex"`
+	BlockHash       string            `json:"blockHash"`
+	BlockNumber     int64             `json:"blockNumber"`
+	Timestamp       time.Time         `json:"timestamp"`
+	Status          TransactionStatus `json:"status,omitempty"`
+}
+
+// TokenStandard is the interface implemented by the contract of an NFT
+type TokenStandard string
+
+const (
+	StandardERC721  TokenStandard = "erc721"
+	StandardERC1155 TokenStandard = "erc1155"
+)
+
+// NFTTransfer is an ERC-721 or ERC-1155 transfer involving a subscribed address.
+// An ERC-1155 TransferBatch event moves several tokens at once, each of them is
+// a separate NFTTransfer told apart by its BatchIndex.
+type NFTTransfer struct {
+	Standard        TokenStandard     `json:"standard"`
+	Contract        string            `json:"contract"` // address of the collection
+	TokenID         BigInt            `json:"tokenId"`
+	Amount          BigInt            `json:"amount"` // always 1 for ERC-721 tokens
+	Operator        string            `json:"operator,omitempty"`
+	From            string            `json:"from"`
+	To              string            `json:"to"`
+	TransactionHash string            `json:"transactionHash"`
+	LogIndex        uint              `json:"logIndex"`
+	BatchIndex      uint              `json:"batchIndex"`
+	BlockHash       string            `json:"blockHash"`
+	BlockNumber     int64             `json:"blockNumber"`
+	Timestamp       time.Time         `json:"timestamp"`
+	Status          TransactionStatus `json:"status,omitempty"`
+}
+
+// Withdrawal is a transfer from the beacon chain to the withdrawal address of a validator
+type Withdrawal struct {
+	Index          uint64            `json:"index"`
+	ValidatorIndex uint64            `json:"validatorIndex"`
+	Address        string            `json:"address"`
+	Amount         BigInt            `json:"amount"` // in gwei
+	BlockHash      string            `json:"blockHash"`
+	BlockNumber    int64             `json:"blockNumber"`
+	Timestamp      time.Time         `json:"timestamp"`
+	Status         TransactionStatus `json:"status,omitempty"`
+}
+
+// ActivityKind is the kind of record of an Activity
+type ActivityKind string
+
+const (
+	ActivityTransaction   ActivityKind = "transaction"
+	ActivityTokenTransfer ActivityKind = "token-transfer"
+	ActivityNFTTransfer   ActivityKind = "nft-transfer"
+	ActivityWithdrawal    ActivityKind = "withdrawal"
+)
+
+// Activity is an entry of the activity feed of an address, only the record of its kind is set
+type Activity struct {
+	Kind          ActivityKind   `json:"kind"`
+	BlockNumber   int64          `json:"blockNumber"`
+	Timestamp     time.Time      `json:"timestamp"`
+	Transaction   *Transaction   `json:"transaction,omitempty"`
+	TokenTransfer *TokenTransfer `json:"tokenTransfer,omitempty"`
+	NFTTransfer   *NFTTransfer   `json:"nftTransfer,omitempty"`
+	Withdrawal    *Withdrawal    `json:"withdrawal,omitempty"`
+}
+
+// FailedBlock is a block that could not be parsed and is waiting to be re-attempted
+type FailedBlock struct {
+	Number    int64     `json:"number"`
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"lastError"`
+	FailedAt  time.Time `json:"failedAt"`
+}
+
+// BackfillStatus is the state of a backfill job
+type BackfillStatus string
+
+const (
+	BackfillQueued    BackfillStatus = "queued"
+	BackfillRunning   BackfillStatus = "running"
+	BackfillCompleted BackfillStatus = "completed"
+	BackfillFailed    BackfillStatus = "failed"
+)
+
+// BackfillJob scans past blocks for the transactions of a newly subscribed address
+type BackfillJob struct {
+	ID           string         `json:"id"`
+	Address      string         `json:"address"`
+	FromBlock    int64          `json:"fromBlock"`
+	ToBlock      int64          `json:"toBlock"`
+	CurrentBlock int64          `json:"currentBlock"` // last block scanned so far
+	Status       BackfillStatus `json:"status"`
+	Error        string         `json:"error,omitempty"`
+	CreatedAt    time.Time      `json:"createdAt"`
+	UpdatedAt    time.Time      `json:"updatedAt"`
 }
 
 // Parser interface as defined in the requirements
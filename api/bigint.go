@@ -0,0 +1,152 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Denominations of ether in wei
+const (
+	GweiDecimals  = 9
+	EtherDecimals = 18
+)
+
+// BigInt is an immutable arbitrary-precision integer, such as an amount in wei.
+// The zero value is 0, and it is encoded in JSON as a decimal string.
+type BigInt struct {
+	int *big.Int
+}
+
+// NewBigInt returns the BigInt of an int64
+func NewBigInt(value int64) BigInt {
+	return BigInt{int: big.NewInt(value)}
+}
+
+// BigIntFromBig returns the BigInt of a big.Int, which is copied
+func BigIntFromBig(value *big.Int) BigInt {
+	if value == nil {
+		return BigInt{}
+	}
+
+	return BigInt{int: new(big.Int).Set(value)}
+}
+
+// ParseBigInt parses a decimal string, or a hexadecimal one with the "0x" prefix
+func ParseBigInt(value string) (BigInt, error) {
+	base := 10
+	if strings.HasPrefix(value, "0x") || strings.HasPrefix(value, "0X") {
+		value, base = value[2:], 16
+	}
+
+	parsed, ok := new(big.Int).SetString(value, base)
+	if !ok {
+		return BigInt{}, fmt.Errorf("invalid integer %q", value)
+	}
+
+	return BigInt{int: parsed}, nil
+}
+
+// Big returns a copy of the value as a big.Int
+func (b BigInt) Big() *big.Int {
+	if b.int == nil {
+		return new(big.Int)
+	}
+
+	return new(big.Int).Set(b.int)
+}
+
+// Int64 returns the value as an int64, the result is undefined if it does not fit
+func (b BigInt) Int64() int64 {
+	if b.int == nil {
+		return 0
+	}
+
+	return b.int.Int64()
+}
+
+// Sign returns -1, 0 or +1 depending on the sign of the value
+func (b BigInt) Sign() int {
+	if b.int == nil {
+		return 0
+	}
+
+	return b.int.Sign()
+}
+
+// Cmp compares the values, returning -1, 0 or +1
+func (b BigInt) Cmp(other BigInt) int {
+	return b.Big().Cmp(other.Big())
+}
+
+// Add returns the sum of the values
+func (b BigInt) Add(other BigInt) BigInt {
+	return BigInt{int: new(big.Int).Add(b.Big(), other.Big())}
+}
+
+// String returns the value in decimal
+func (b BigInt) String() string {
+	if b.int == nil {
+		return "0"
+	}
+
+	return b.int.String()
+}
+
+// Ether formats an amount in wei as ether, e.g. "1.5"
+func (b BigInt) Ether() string {
+	return b.Format(EtherDecimals)
+}
+
+// Gwei formats an amount in wei as gwei, e.g. "30.000000001"
+func (b BigInt) Gwei() string {
+	return b.Format(GweiDecimals)
+}
+
+// Format returns the value divided by 10^decimals without losing precision,
+// trailing zeros of the fraction are trimmed
+func (b BigInt) Format(decimals int) string {
+	digits := b.Big()
+
+	negative := digits.Sign() < 0
+	digits.Abs(digits)
+
+	str := digits.String()
+	if len(str) <= decimals {
+		str = strings.Repeat("0", decimals-len(str)+1) + str
+	}
+
+	integer, fraction := str[:len(str)-decimals], strings.TrimRight(str[len(str)-decimals:], "0")
+
+	if fraction != "" {
+		integer += "." + fraction
+	}
+
+	if negative {
+		return "-" + integer
+	}
+
+	return integer
+}
+
+func (b BigInt) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + b.String() + `"`), nil
+}
+
+// UnmarshalJSON accepts a decimal or "0x" prefixed hexadecimal string, or a plain JSON number
+func (b *BigInt) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		*b = BigInt{}
+		return nil
+	}
+
+	parsed, err := ParseBigInt(strings.Trim(string(data), `"`))
+	if err != nil {
+		return err
+	}
+
+	*b = parsed
+
+	return nil
+}
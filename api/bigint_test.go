@@ -0,0 +1,92 @@
+package api_test
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/devshark/tx-parser-go/api"
+)
+
+func TestBigIntJSON(t *testing.T) {
+	// 100 ether in wei, way above the int64 range
+	wei, _ := new(big.Int).SetString("100000000000000000000", 10)
+
+	tx := api.Transaction{Hash: "0x1", Value: api.BigIntFromBig(wei)}
+
+	data, err := json.Marshal(tx)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var decoded api.Transaction
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if decoded.Value.Cmp(tx.Value) != 0 {
+		t.Errorf("Expected value %s, got %s", tx.Value, decoded.Value)
+	}
+
+	var raw map[string]any
+	json.Unmarshal(data, &raw)
+
+	if raw["value"] != "100000000000000000000" {
+		t.Errorf("Expected value to be encoded as a decimal string, got %v", raw["value"])
+	}
+
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{`"0x56bc75e2d63100000"`, "100000000000000000000"},
+		{`12345`, "12345"},
+		{`null`, "0"},
+	}
+
+	for _, c := range cases {
+		var value api.BigInt
+		if err := json.Unmarshal([]byte(c.input), &value); err != nil {
+			t.Fatalf("Unexpected error for %s: %v", c.input, err)
+		}
+
+		if value.String() != c.expected {
+			t.Errorf("Expected %s for %s, got %s", c.expected, c.input, value)
+		}
+	}
+
+	var value api.BigInt
+	if err := json.Unmarshal([]byte(`"12x"`), &value); err == nil {
+		t.Error("Expected an error for an invalid integer, got nil")
+	}
+}
+
+func TestBigIntFormat(t *testing.T) {
+	cases := []struct {
+		wei   string
+		ether string
+		gwei  string
+	}{
+		{"0", "0", "0"},
+		{"1", "0.000000000000000001", "0.000000001"},
+		{"1500000000000000000", "1.5", "1500000000"},
+		{"30000000001", "0.000000030000000001", "30.000000001"},
+		{"-2000000000000000000", "-2", "-2000000000"},
+		{"123456789000000000000000", "123456.789", "123456789000000"},
+	}
+
+	for _, c := range cases {
+		value, err := api.ParseBigInt(c.wei)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if ether := value.Ether(); ether != c.ether {
+			t.Errorf("Expected %s wei to be %s ether, got %s", c.wei, c.ether, ether)
+		}
+
+		if gwei := value.Gwei(); gwei != c.gwei {
+			t.Errorf("Expected %s wei to be %s gwei, got %s", c.wei, c.gwei, gwei)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"strings"
+	"time"
+)
+
+// TransactionDirection is whether a transaction was sent or received by an address
+type TransactionDirection string
+
+const (
+	DirectionIn  TransactionDirection = "in"
+	DirectionOut TransactionDirection = "out"
+)
+
+// TransactionQuery selects a page of the transactions of an address, ordered by block number and
+// transaction index. Every filter set must match, the zero value of a filter does not filter.
+type TransactionQuery struct {
+	// FromBlock and ToBlock bound the block range, inclusive
+	FromBlock int64
+	ToBlock   int64
+	// FromTime and ToTime bound the time of the block, inclusive
+	FromTime time.Time
+	ToTime   time.Time
+	// Direction is relative to the address, a transaction to itself is both in and out
+	Direction TransactionDirection
+	// MinValue and MaxValue bound the value in wei, inclusive
+	MinValue *BigInt
+	MaxValue *BigInt
+	// Counterparty is the other side of the transaction, its recipient if sent and its sender if received
+	Counterparty string
+	// Status selects the transactions of a status, the orphaned ones are left out unless asked for
+	Status TransactionStatus
+	// Confirmed only selects the transactions that reached the confirmation depth or finality
+	Confirmed bool
+	// ExcludeFailed leaves out the transactions whose receipt reports they reverted
+	ExcludeFailed bool
+	Kind          TransactionKind
+	// Cursor resumes after the last transaction of the previous page, as returned in its NextCursor
+	Cursor string
+	// Limit is the size of the page, every transaction is returned if it is not set
+	Limit int
+}
+
+// TransactionPage is a page of the transactions selected by a TransactionQuery,
+// NextCursor is set when there are more transactions after it
+type TransactionPage struct {
+	Transactions []Transaction `json:"transactions"`
+	NextCursor   string        `json:"nextCursor,omitempty"`
+}
+
+// SelectsStatus reports whether a record with the given status is selected by a status filter:
+// only the records of that status if it is set, otherwise every record but the orphaned ones
+func SelectsStatus(filter, status TransactionStatus) bool {
+	if filter != "" {
+		return status == filter
+	}
+
+	return status != StatusOrphaned
+}
+
+// Matches reports whether the transaction of the address matches the filters of the query
+func (q TransactionQuery) Matches(address string, tx Transaction) bool {
+	if q.FromBlock > 0 && tx.BlockNumber < q.FromBlock {
+		return false
+	}
+
+	if q.ToBlock > 0 && tx.BlockNumber > q.ToBlock {
+		return false
+	}
+
+	if !q.FromTime.IsZero() && tx.Timestamp.Before(q.FromTime) {
+		return false
+	}
+
+	if !q.ToTime.IsZero() && tx.Timestamp.After(q.ToTime) {
+		return false
+	}
+
+	sent, received := strings.EqualFold(tx.From, address), strings.EqualFold(tx.To, address)
+
+	if q.Direction == DirectionOut && !sent || q.Direction == DirectionIn && !received {
+		return false
+	}
+
+	if q.MinValue != nil && tx.Value.Cmp(*q.MinValue) < 0 {
+		return false
+	}
+
+	if q.MaxValue != nil && tx.Value.Cmp(*q.MaxValue) > 0 {
+		return false
+	}
+
+	if q.Counterparty != "" &&
+		!(sent && strings.EqualFold(tx.To, q.Counterparty)) && !(received && strings.EqualFold(tx.From, q.Counterparty)) {
+		return false
+	}
+
+	if !SelectsStatus(q.Status, tx.Status) {
+		return false
+	}
+
+	if q.Confirmed && !tx.Status.IsConfirmed() {
+		return false
+	}
+
+	if q.ExcludeFailed && tx.Failed() {
+		return false
+	}
+
+	if q.Kind != "" && tx.Kind != q.Kind {
+		return false
+	}
+
+	return true
+}
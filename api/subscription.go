@@ -0,0 +1,33 @@
+package api
+
+import (
+	"slices"
+	"time"
+)
+
+// SubscriptionMetadata describes a subscription, as given by the subscriber
+type SubscriptionMetadata struct {
+	Label string   `json:"label,omitempty"`
+	Tags  []string `json:"tags,omitempty"`
+	// ExpiresAt is when the address stops being watched, never if it is not set
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// Subscription is an address watched by the parser
+type Subscription struct {
+	Address string `json:"address"`
+	SubscriptionMetadata
+	// CreatedAtBlock is the last parsed block when the address was subscribed
+	CreatedAtBlock int64     `json:"createdAtBlock"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+// Expired reports whether the subscription expired at the given time
+func (s Subscription) Expired(now time.Time) bool {
+	return s.ExpiresAt != nil && !now.Before(*s.ExpiresAt)
+}
+
+// HasTag reports whether the subscription is tagged with the given tag
+func (s Subscription) HasTag(tag string) bool {
+	return slices.Contains(s.Tags, tag)
+}
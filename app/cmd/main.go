@@ -3,10 +3,12 @@ package main
 import (
 	"context"
 	"errors"
+	"io"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -30,15 +32,82 @@ func main() {
 	config := NewConfig()
 	logger := log.Default()
 
-	blockchainClient := blockchain.NewPublicNodeClient(config.publicNodeURL, logger)
+	if len(config.publicNodeURLs) == 0 {
+		logger.Fatal("PUBLIC_NODE_URL must not be empty")
+	}
+
+	var blockchainClient blockchain.BlockchainClient
+
+	// several endpoints are load balanced, failing over whenever one of them is down or lagging
+	if len(config.publicNodeURLs) == 1 {
+		blockchainClient = newBlockchainClient(config.publicNodeURLs[0], config, logger)
+	} else {
+		endpoints := make([]blockchain.Endpoint, len(config.publicNodeURLs))
+		for i, nodeURL := range config.publicNodeURLs {
+			endpoints[i] = blockchain.Endpoint{URL: nodeURL, Client: newBlockchainClient(nodeURL, config, logger)}
+		}
+
+		failoverClient := blockchain.NewFailoverClient(endpoints, logger,
+			blockchain.WithStrategy(config.rpcStrategy),
+			blockchain.WithMaxHeadLag(config.maxHeadLag))
+
+		go failoverClient.Run(ctx)
+
+		blockchainClient = failoverClient
+	}
+
+	var (
+		txRepo    repository.TransactionRepository = repository.NewInMemoryTransactionRepository()
+		subRepo   repository.SubscriberRepository  = repository.NewInMemorySubscriberRepository()
+		blockRepo repository.BlockRepository       = repository.NewInMemoryBlockRepository()
+		store     io.Closer
+	)
+
+	// the repositories are kept in memory and lost on restart, unless a database or a storage directory is given
+	switch {
+	case config.databaseURL != "":
+		sqlStore, err := repository.NewSQLStore(ctx, config.databaseURL)
+		if err != nil {
+			logger.Fatalf("failed to open the database: %v", err)
+		}
+
+		txRepo, subRepo, blockRepo, store = sqlStore.Transactions(), sqlStore.Subscribers(), sqlStore.Blocks(), sqlStore
+
+		logger.Print("storing in the database")
+	case config.storageDir != "":
+		fileStore, err := repository.NewFileStore(config.storageDir)
+		if err != nil {
+			logger.Fatalf("failed to open the storage: %v", err)
+		}
 
-	txRepo := repository.NewInMemoryTransactionRepository()
-	subRepo := repository.NewInMemorySubscriberRepository()
-	blockRepo := repository.NewInMemoryBlockRepository()
+		txRepo, subRepo, blockRepo, store = fileStore.Transactions(), fileStore.Subscribers(), fileStore.Blocks(), fileStore
 
-	parser := worker.NewParserWorker(blockchainClient, txRepo, subRepo, blockRepo).WithCustomLogger(logger)
+		logger.Printf("storing in %s", config.storageDir)
+	}
 
-	router := httpHandler.NewRouter(blockchainClient, txRepo, subRepo, logger)
+	parser := worker.NewParserWorker(blockchainClient, txRepo, subRepo, blockRepo).
+		WithCustomLogger(logger).
+		WithStartBlock(config.startBlock).
+		WithConcurrency(int(config.workerConcurrency)).
+		WithBatchSize(int(config.batchSize)).
+		WithConfirmationDepth(config.confirmationDepth).
+		WithFinalityTags(config.finalityTags).
+		WithTokenTransfers(config.tokenTransfers).
+		WithNFTTransfers(config.nftTransfers).
+		WithInternalTransactions(config.internalTxs).
+		WithContractSubscriptions(config.subscribeContracts).
+		WithDeadLetterRetries(config.deadLetterInterval, worker.DefaultMaxDeadLetterAttempts)
+
+	backfiller := worker.NewBackfiller(blockchainClient, txRepo, blockRepo).
+		WithCustomLogger(logger).
+		WithConfirmationDepth(config.confirmationDepth).
+		WithBatchSize(int(config.batchSize)).
+		WithTokenTransfers(config.tokenTransfers).
+		WithNFTTransfers(config.nftTransfers).
+		WithInternalTransactions(config.internalTxs).
+		WithParserWorker(parser)
+
+	router := httpHandler.NewRouter(blockchainClient, txRepo, subRepo, blockRepo, backfiller, logger)
 	server := httpHandler.NewHttpServer(router, config.port, httpReadTimeout, httpWriteTimeout)
 
 	stop := make(chan os.Signal, 1)
@@ -75,6 +144,12 @@ func main() {
 		logger.Println("parser worker stopped")
 	}()
 
+	go func() {
+		if err := backfiller.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			logger.Printf("backfiller stopped: %v", err)
+		}
+	}()
+
 	logger.Print("the app is running")
 
 	<-stop
@@ -89,19 +164,70 @@ func main() {
 
 	<-workerStopped
 
+	if store != nil {
+		if err := store.Close(); err != nil {
+			log.Printf("failed to close the storage: %v", err)
+		}
+	}
+
 	log.Print("Gracefully stopped.")
 }
 
 type Config struct {
-	publicNodeURL string
-	port          int64
-	jobSchedule   time.Duration
+	publicNodeURLs     []string
+	rpcStrategy        string
+	maxHeadLag         int64
+	port               int64
+	jobSchedule        time.Duration
+	startBlock         int64
+	workerConcurrency  int64
+	batchSize          int64
+	deadLetterInterval time.Duration
+	confirmationDepth  int64
+	finalityTags       bool
+	tokenTransfers     bool
+	nftTransfers       bool
+	internalTxs        bool // tracing requires a node with the debug API, hence it is opt-in
+	subscribeContracts bool
+	storageDir         string
+	databaseURL        string // takes precedence over storageDir
 }
 
 func NewConfig() *Config {
+	// PUBLIC_NODE_URL accepts a comma-separated list of endpoints
+	publicNodeURLs := []string{}
+	for _, nodeURL := range strings.Split(env.GetEnv("PUBLIC_NODE_URL", "https://ethereum-rpc.publicnode.com/"), ",") {
+		if nodeURL = strings.TrimSpace(nodeURL); nodeURL != "" {
+			publicNodeURLs = append(publicNodeURLs, nodeURL)
+		}
+	}
+
 	return &Config{
-		publicNodeURL: env.GetEnv("PUBLIC_NODE_URL", "https://ethereum-rpc.publicnode.com/"),
-		port:          env.GetEnvInt64("PORT", 8080),
-		jobSchedule:   env.GetEnvDuration("JOB_SCHEDULE", 5*time.Second),
+		publicNodeURLs:     publicNodeURLs,
+		rpcStrategy:        env.GetEnv("RPC_STRATEGY", blockchain.StrategyRoundRobin),
+		maxHeadLag:         env.GetEnvInt64("RPC_MAX_HEAD_LAG", blockchain.DefaultMaxHeadLag),
+		port:               env.GetEnvInt64("PORT", 8080),
+		jobSchedule:        env.GetEnvDuration("JOB_SCHEDULE", 5*time.Second),
+		startBlock:         env.GetEnvInt64("START_BLOCK", 0),
+		workerConcurrency:  env.GetEnvInt64("WORKER_CONCURRENCY", worker.DefaultConcurrency),
+		batchSize:          env.GetEnvInt64("RPC_BATCH_SIZE", blockchain.DefaultBatchSize),
+		deadLetterInterval: env.GetEnvDuration("DEAD_LETTER_INTERVAL", worker.DefaultDeadLetterInterval),
+		confirmationDepth:  env.GetEnvInt64("CONFIRMATION_DEPTH", worker.DefaultConfirmationDepth),
+		finalityTags:       env.GetEnvBool("FINALITY_TAGS", false),
+		tokenTransfers:     env.GetEnvBool("TOKEN_TRANSFERS", false),
+		nftTransfers:       env.GetEnvBool("NFT_TRANSFERS", false),
+		internalTxs:        env.GetEnvBool("INTERNAL_TRANSACTIONS", false),
+		subscribeContracts: env.GetEnvBool("SUBSCRIBE_CONTRACTS", false),
+		storageDir:         env.GetEnv("STORAGE_DIR", ""),
+		databaseURL:        env.GetEnv("DATABASE_URL", ""),
 	}
 }
+
+// newBlockchainClient creates the client for a single endpoint, over websocket for ws:// and wss:// urls
+func newBlockchainClient(nodeURL string, config *Config, logger *log.Logger) blockchain.BlockchainClient {
+	if blockchain.IsWebSocketURL(nodeURL) {
+		return blockchain.NewWebSocketClient(nodeURL, logger)
+	}
+
+	return blockchain.NewPublicNodeClient(nodeURL, logger, blockchain.WithBatchSize(int(config.batchSize)))
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/devshark/tx-parser-go/app/internal/simchain"
+	"github.com/devshark/tx-parser-go/pkg/env"
+)
+
+const (
+	shutdownTimeout  = 5 * time.Second
+	httpReadTimeout  = 5 * time.Second
+	httpWriteTimeout = 10 * time.Second
+)
+
+// a development node serving a simulated chain over JSON-RPC, point PUBLIC_NODE_URL of the server to it
+func main() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	config := NewConfig()
+	logger := log.Default()
+
+	opts := []simchain.Option{
+		simchain.WithSeed(config.seed),
+		simchain.WithStartBlock(config.startBlock),
+		simchain.WithTransactionsPerBlock(int(config.txsPerBlock)),
+		simchain.WithBlockInterval(config.blockTime),
+	}
+
+	if len(config.addresses) > 0 {
+		opts = append(opts, simchain.WithAddresses(config.addresses...))
+	}
+
+	if config.tokenContract != "" {
+		opts = append(opts, simchain.WithToken(config.tokenContract))
+	}
+
+	chain := simchain.New(opts...)
+	chain.SetLatency(config.latency)
+	chain.SetErrorRate(float64(config.errorPercent) / 100)
+
+	server := &http.Server{
+		Addr:         ":" + strconv.FormatInt(config.port, 10),
+		Handler:      chain.Handler(),
+		ReadTimeout:  httpReadTimeout,
+		WriteTimeout: httpWriteTimeout,
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(
+		stop,
+		os.Interrupt,
+		syscall.SIGHUP,
+		syscall.SIGINT,
+		syscall.SIGTERM,
+		syscall.SIGQUIT,
+	)
+
+	go func() {
+		logger.Printf("dev node listening on port %d, head at block %d", config.port, chain.Head())
+
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Fatalf("http server failed to start: %v", err)
+		}
+	}()
+
+	go mine(ctx, chain, config, logger)
+
+	<-stop
+
+	log.Print("Shutting down...")
+	time.AfterFunc(shutdownTimeout, cancel)
+
+	if err := server.Shutdown(ctx); err != nil {
+		log.Fatal("Shutdown", err)
+	}
+
+	log.Print("Gracefully stopped.")
+}
+
+// mine mines a block every block time, and reorganizes the head every reorgEvery blocks
+func mine(ctx context.Context, chain *simchain.Chain, config *Config, logger *log.Logger) {
+	ticker := time.NewTicker(config.blockTime)
+	defer ticker.Stop()
+
+	for mined := int64(1); ; mined++ {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		block := chain.Mine(1)[0]
+		logger.Printf("mined block %d with %d transactions", block.Number, len(block.Transactions))
+
+		if config.reorgEvery > 0 && mined%config.reorgEvery == 0 {
+			if _, err := chain.Reorg(int(config.reorgDepth)); err != nil {
+				logger.Printf("failed to reorg: %v", err)
+				continue
+			}
+
+			logger.Printf("reorganized the last %d blocks", config.reorgDepth)
+		}
+	}
+}
+
+type Config struct {
+	port          int64
+	blockTime     time.Duration
+	seed          int64
+	startBlock    int64
+	addresses     []string
+	txsPerBlock   int64
+	tokenContract string
+	reorgEvery    int64
+	reorgDepth    int64
+	latency       time.Duration
+	errorPercent  int64
+}
+
+func NewConfig() *Config {
+	return &Config{
+		port:          env.GetEnvInt64("PORT", 8545),
+		blockTime:     env.GetEnvDuration("BLOCK_TIME", 2*time.Second),
+		seed:          env.GetEnvInt64("SEED", 1),
+		startBlock:    env.GetEnvInt64("START_BLOCK", 0),
+		addresses:     env.GetEnvValues("DEV_ADDRESSES"),
+		txsPerBlock:   env.GetEnvInt64("TXS_PER_BLOCK", simchain.DefaultTransactionsPerBlock),
+		tokenContract: env.GetEnv("TOKEN_CONTRACT", ""),
+		reorgEvery:    env.GetEnvInt64("REORG_EVERY", 0),
+		reorgDepth:    env.GetEnvInt64("REORG_DEPTH", 2),
+		latency:       env.GetEnvDuration("LATENCY", 0),
+		errorPercent:  env.GetEnvInt64("ERROR_PERCENT", 0),
+	}
+}
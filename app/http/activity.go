@@ -0,0 +1,34 @@
+package http
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/devshark/tx-parser-go/api"
+)
+
+// addressActivity gathers the records of the address selected by the status into its activity feed,
+// which leaves out the records of orphaned blocks unless the status asks for them
+func (h *httpHandler) addressActivity(ctx context.Context, address string, status api.TransactionStatus) ([]api.Activity, error) {
+	page, err := h.transactionRepo.QueryTransactions(ctx, address, api.TransactionQuery{Status: status})
+	if err != nil {
+		return nil, fmt.Errorf("QueryTransactions: %w", err)
+	}
+
+	tokenTransfers, err := h.transactionRepo.GetTokenTransfers(ctx, address, status)
+	if err != nil {
+		return nil, fmt.Errorf("GetTokenTransfers: %w", err)
+	}
+
+	nftTransfers, err := h.transactionRepo.GetNFTTransfers(ctx, address, status)
+	if err != nil {
+		return nil, fmt.Errorf("GetNFTTransfers: %w", err)
+	}
+
+	withdrawals, err := h.transactionRepo.GetWithdrawals(ctx, address, status)
+	if err != nil {
+		return nil, fmt.Errorf("GetWithdrawals: %w", err)
+	}
+
+	return api.NewActivity(page.Transactions, tokenTransfers, nftTransfers, withdrawals), nil
+}
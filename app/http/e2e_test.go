@@ -0,0 +1,196 @@
+package http_test
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/devshark/tx-parser-go/api"
+	httpHandler "github.com/devshark/tx-parser-go/app/http"
+	"github.com/devshark/tx-parser-go/app/internal/blockchain"
+	"github.com/devshark/tx-parser-go/app/internal/repository"
+	"github.com/devshark/tx-parser-go/app/worker"
+	"github.com/devshark/tx-parser-go/client"
+)
+
+var record = flag.Bool("record", false, "record the fixtures in testdata against the live node instead of replaying them")
+
+const (
+	startBlock = 21291150
+	headBlock  = startBlock + 2
+	subscriber = "0x00000000000000000000000000000000000a11ce"
+)
+
+// headClient stops the chain at a fixed head, so that recording against a live node
+// sends the same requests as replaying. It passes the optional interfaces through.
+type headClient struct {
+	blockchain.BlockchainClient
+	head int64
+}
+
+func (c *headClient) GetLatestBlockNumber(ctx context.Context) (int64, error) {
+	latest, err := c.BlockchainClient.GetLatestBlockNumber(ctx)
+
+	return min(latest, c.head), err
+}
+
+func (c *headClient) GetBlockReceipts(ctx context.Context, number int64) ([]*api.Receipt, error) {
+	receiptClient, ok := c.BlockchainClient.(blockchain.ReceiptClient)
+	if !ok {
+		return nil, blockchain.ErrNotImplemented
+	}
+
+	return receiptClient.GetBlockReceipts(ctx, number)
+}
+
+func (c *headClient) GetTransactionReceipt(ctx context.Context, hash string) (*api.Receipt, error) {
+	receiptClient, ok := c.BlockchainClient.(blockchain.ReceiptClient)
+	if !ok {
+		return nil, blockchain.ErrNotImplemented
+	}
+
+	return receiptClient.GetTransactionReceipt(ctx, hash)
+}
+
+func (c *headClient) GetLogs(ctx context.Context, filter blockchain.LogFilter) ([]api.Log, error) {
+	logClient, ok := c.BlockchainClient.(blockchain.LogClient)
+	if !ok {
+		return nil, blockchain.ErrNotImplemented
+	}
+
+	return logClient.GetLogs(ctx, filter)
+}
+
+// TestEndToEnd runs the worker and the http server against the synthetic node responses in
+// testdata/synthetic/e2e, run it with -record to record them again against PUBLIC_NODE_URL
+func TestEndToEnd(t *testing.T) {
+	dir := "testdata/synthetic/e2e"
+
+	var nodeClient blockchain.BlockchainClient
+	if *record {
+		nodeClient = blockchain.NewRecordingClient(os.Getenv("PUBLIC_NODE_URL"), dir, log.Default())
+	} else {
+		nodeClient = blockchain.NewReplayClient(dir, log.Default())
+	}
+
+	bcClient := &headClient{BlockchainClient: nodeClient, head: headBlock}
+
+	txRepo := repository.NewInMemoryTransactionRepository()
+	subRepo := repository.NewInMemorySubscriberRepository()
+	blockRepo := repository.NewInMemoryBlockRepository()
+
+	parser := worker.NewParserWorker(bcClient, txRepo, subRepo, blockRepo).
+		WithStartBlock(startBlock).
+		WithTokenTransfers(true)
+
+	backfiller := worker.NewBackfiller(bcClient, txRepo, blockRepo)
+
+	server := httptest.NewServer(httpHandler.NewRouter(bcClient, txRepo, subRepo, blockRepo, backfiller, log.Default()))
+	defer server.Close()
+
+	parserClient := client.NewClient(server.URL)
+
+	if !parserClient.Subscribe(subscriber) {
+		t.Fatalf("Expected to subscribe to %s", subscriber)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	go parser.Run(ctx, 50*time.Millisecond)
+
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		if parsed, _ := blockRepo.GetLastParsedBlock(ctx); parsed == headBlock {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the worker to parse up to block %d", headBlock)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if current := parserClient.GetCurrentBlock(); current != headBlock {
+		t.Errorf("Expected the current block to be %d, got %d", headBlock, current)
+	}
+
+	// a payment received in the first block and a contract deployment in the second
+	txs := parserClient.GetTransactions(subscriber)
+	if len(txs) != 2 {
+		t.Fatalf("Expected 2 transactions, got %d", len(txs))
+	}
+
+	payment, deployment := txs[0], txs[1]
+
+	if payment.BlockNumber != startBlock || payment.To != subscriber || payment.Value.Ether() != "1.5" || payment.Receipt == nil || !payment.Receipt.Succeeded() {
+		t.Errorf("Expected a successful payment of 1.5 ether in block %d, got %+v", startBlock, payment)
+	}
+
+	if deployment.BlockNumber != startBlock+1 || !deployment.ContractCreation || deployment.ContractAddress == "" {
+		t.Errorf("Expected a contract deployment in block %d, got %+v", startBlock+1, deployment)
+	}
+
+	// the same transactions one page at a time
+	first := parserClient.QueryTransactions(subscriber, api.TransactionQuery{Limit: 1})
+	if first == nil || len(first.Transactions) != 1 || first.Transactions[0].Hash != payment.Hash || first.NextCursor == "" {
+		t.Fatalf("Expected a first page with the payment and a cursor, got %+v", first)
+	}
+
+	second := parserClient.QueryTransactions(subscriber, api.TransactionQuery{Limit: 1, Cursor: first.NextCursor})
+	if second == nil || len(second.Transactions) != 1 || second.Transactions[0].Hash != deployment.Hash || second.NextCursor != "" {
+		t.Errorf("Expected a last page with the deployment, got %+v", second)
+	}
+
+	received := parserClient.QueryTransactions(subscriber, api.TransactionQuery{Direction: api.DirectionIn, MinValue: &payment.Value})
+	if received == nil || len(received.Transactions) != 1 || received.Transactions[0].Hash != payment.Hash {
+		t.Errorf("Expected only the payment to be received, got %+v", received)
+	}
+
+	tokenTransfers := parserClient.GetTokenTransfers(subscriber)
+	if len(tokenTransfers) != 1 || tokenTransfers[0].To != subscriber || tokenTransfers[0].Amount.Format(6) != "1000" {
+		t.Errorf("Expected a transfer of 1000 tokens, got %+v", tokenTransfers)
+	}
+
+	withdrawals := parserClient.GetWithdrawals(subscriber)
+	if len(withdrawals) != 1 || withdrawals[0].BlockNumber != headBlock {
+		t.Errorf("Expected a withdrawal in block %d, got %+v", headBlock, withdrawals)
+	}
+
+	if activity := parserClient.GetActivity(subscriber); len(activity) != 4 {
+		t.Errorf("Expected 4 entries in the activity feed, got %d", len(activity))
+	}
+
+	// the subscription is labelled, then dropped while its records are still served
+	expiry := time.Now().Add(time.Hour)
+	if !parserClient.SubscribeWithMetadata(subscriber, api.SubscriptionMetadata{Label: "alice", Tags: []string{"e2e"}, ExpiresAt: &expiry}) {
+		t.Fatalf("Expected to label the subscription to %s", subscriber)
+	}
+
+	subscriptions := parserClient.GetSubscriptions()
+	if len(subscriptions) != 1 || subscriptions[0].Address != subscriber || subscriptions[0].Label != "alice" ||
+		subscriptions[0].ExpiresAt == nil || subscriptions[0].CreatedAt.IsZero() {
+		t.Errorf("Expected the labelled subscription to %s, got %+v", subscriber, subscriptions)
+	}
+
+	if !parserClient.Unsubscribe(subscriber) {
+		t.Fatalf("Expected to unsubscribe from %s", subscriber)
+	}
+
+	if parserClient.Unsubscribe(subscriber) {
+		t.Errorf("Expected unsubscribing twice from %s to fail", subscriber)
+	}
+
+	if subscriptions := parserClient.GetSubscriptions(); subscriptions == nil || len(subscriptions) != 0 {
+		t.Errorf("Expected no subscriptions left, got %+v", subscriptions)
+	}
+
+	if txs := parserClient.GetTransactions(subscriber); len(txs) != 2 {
+		t.Errorf("Expected the transactions of %s to be kept, got %d", subscriber, len(txs))
+	}
+}
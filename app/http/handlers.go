@@ -1,20 +1,38 @@
 package http
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/devshark/tx-parser-go/api"
 	"github.com/devshark/tx-parser-go/app/internal/blockchain"
 	"github.com/devshark/tx-parser-go/app/internal/repository"
+	"github.com/devshark/tx-parser-go/app/worker"
 	"github.com/devshark/tx-parser-go/client"
 )
 
+const (
+	// DefaultPageLimit is the number of transactions of a page when the limit is not given
+	DefaultPageLimit = 100
+	// MaxPageLimit is the largest page of transactions served at once
+	MaxPageLimit = 1000
+)
+
 type httpHandler struct {
 	bcClient        blockchain.BlockchainClient
 	transactionRepo repository.TransactionRepository
 	subscriberRepo  repository.SubscriberRepository
+	blockRepo       repository.BlockRepository
+	backfiller      *worker.Backfiller
 	logger          *log.Logger
 }
 
@@ -36,6 +54,8 @@ func (h *httpHandler) GetCurrentBlock(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// GetTransactions returns a page of the transactions of the address, selected by the filters of the query.
+// The page holds DefaultPageLimit transactions unless the limit is given, next_cursor is set if there are more.
 func (h *httpHandler) GetTransactions(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
@@ -46,21 +66,168 @@ func (h *httpHandler) GetTransactions(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	transactions, err := h.transactionRepo.GetTransactions(ctx, address)
+	query, err := client.ParseTransactionQuery(r.URL.Query())
 	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if query.Limit == 0 {
+		query.Limit = DefaultPageLimit
+	}
+
+	query.Limit = min(query.Limit, MaxPageLimit)
+
+	page, err := h.transactionRepo.QueryTransactions(ctx, address, query)
+	if errors.Is(err, repository.ErrInvalidCursor) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	} else if err != nil {
 		h.logger.Printf("Failed to get transactions for address %s: %v", address, err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
 	tx := &client.AddressTransactionsResponse{
-		Transactions: transactions,
+		Transactions: page.Transactions,
+		NextCursor:   page.NextCursor,
 	}
 
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(tx)
 }
 
+func (h *httpHandler) GetTokenTransfers(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	address := r.PathValue("address")
+
+	if strings.TrimSpace(address) == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// the records of orphaned blocks are left out unless asked for
+	status, err := client.ParseStatus(r.URL.Query().Get("status"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	transfers, err := h.transactionRepo.GetTokenTransfers(ctx, address, status)
+	if err != nil {
+		h.logger.Printf("Failed to get token transfers for address %s: %v", address, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response := &client.TokenTransfersResponse{
+		TokenTransfers: transfers,
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *httpHandler) GetNFTTransfers(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	address := r.PathValue("address")
+
+	if strings.TrimSpace(address) == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// the records of orphaned blocks are left out unless asked for
+	status, err := client.ParseStatus(r.URL.Query().Get("status"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	transfers, err := h.transactionRepo.GetNFTTransfers(ctx, address, status)
+	if err != nil {
+		h.logger.Printf("Failed to get NFT transfers for address %s: %v", address, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response := &client.NFTTransfersResponse{
+		NFTTransfers: transfers,
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *httpHandler) GetWithdrawals(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	address := r.PathValue("address")
+
+	if strings.TrimSpace(address) == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// the records of orphaned blocks are left out unless asked for
+	status, err := client.ParseStatus(r.URL.Query().Get("status"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	withdrawals, err := h.transactionRepo.GetWithdrawals(ctx, address, status)
+	if err != nil {
+		h.logger.Printf("Failed to get withdrawals for address %s: %v", address, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response := &client.WithdrawalsResponse{
+		Withdrawals: withdrawals,
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetActivity returns the records of the address, transactions, transfers and withdrawals, in block order
+func (h *httpHandler) GetActivity(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	address := r.PathValue("address")
+
+	if strings.TrimSpace(address) == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// the records of orphaned blocks are left out unless asked for
+	status, err := client.ParseStatus(r.URL.Query().Get("status"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	activity, err := h.addressActivity(ctx, address, status)
+	if err != nil {
+		h.logger.Printf("Failed to get activity for address %s: %v", address, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response := &client.ActivityResponse{
+		Activity: activity,
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// PostSubscribeAddress subscribes the address. The metadata of the subscription is optionally given
+// in the body, which replaces the metadata of an existing subscription.
 func (h *httpHandler) PostSubscribeAddress(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
@@ -71,13 +238,199 @@ func (h *httpHandler) PostSubscribeAddress(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	if err := h.subscriberRepo.Subscribe(ctx, address); err != nil {
+	// optionally backfill the address' history starting from the given block
+	var fromBlock int64 = -1
+	if value := r.URL.Query().Get("fromBlock"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || parsed < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		fromBlock = parsed
+	}
+
+	var metadata *api.SubscriptionMetadata
+	if err := json.NewDecoder(r.Body).Decode(&metadata); err != nil && !errors.Is(err, io.EOF) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if metadata != nil && metadata.ExpiresAt != nil && !metadata.ExpiresAt.After(time.Now()) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if err := h.subscribe(ctx, address, metadata); err != nil {
 		h.logger.Printf("Failed to subscribe address %s: %v", address, err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
+	if fromBlock < 0 {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	job, err := h.backfiller.Enqueue(ctx, address, fromBlock)
+	if errors.Is(err, worker.ErrBackfillQueueFull) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	} else if err != nil {
+		h.logger.Printf("Failed to enqueue backfill for address %s: %v", address, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response := &client.BackfillJobResponse{
+		Job: job,
+	}
+
 	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(response)
+}
+
+// subscribe subscribes the address from the last parsed block with the metadata. Without metadata
+// a watched address is left as is, and an expired subscription is renewed without metadata.
+func (h *httpHandler) subscribe(ctx context.Context, address string, metadata *api.SubscriptionMetadata) error {
+	if metadata == nil {
+		subscribed, err := h.subscriberRepo.IsSubscribed(ctx, address)
+		if err != nil || subscribed {
+			return err
+		}
+
+		metadata = &api.SubscriptionMetadata{}
+	}
+
+	lastParsedBlock, err := h.blockRepo.GetLastParsedBlock(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get last parsed block: %w", err)
+	}
+
+	return h.subscriberRepo.SaveSubscription(ctx, api.Subscription{
+		Address:              address,
+		SubscriptionMetadata: *metadata,
+		CreatedAtBlock:       lastParsedBlock,
+	})
+}
+
+// DeleteSubscribeAddress stops watching the address, the records stored for it are still served
+func (h *httpHandler) DeleteSubscribeAddress(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	address := r.PathValue("address")
+
+	if strings.TrimSpace(address) == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	err := h.subscriberRepo.Unsubscribe(ctx, address)
+	if errors.Is(err, repository.ErrNotSubscribed) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	} else if err != nil {
+		h.logger.Printf("Failed to unsubscribe address %s: %v", address, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetSubscriptions returns every subscription, only those with the given tag if any
+func (h *httpHandler) GetSubscriptions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	subscriptions, err := h.subscriberRepo.GetSubscriptions(ctx)
+	if err != nil {
+		h.logger.Printf("Failed to get subscriptions: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if tag := r.URL.Query().Get("tag"); tag != "" {
+		subscriptions = slices.DeleteFunc(subscriptions, func(subscription api.Subscription) bool {
+			return !subscription.HasTag(tag)
+		})
+	}
+
+	response := &client.SubscriptionsResponse{
+		Subscriptions: subscriptions,
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *httpHandler) GetBackfillJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.backfiller.GetJob(r.PathValue("id"))
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	response := &client.BackfillJobResponse{
+		Job: job,
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *httpHandler) GetFailedBlocks(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	failedBlocks, err := h.blockRepo.GetFailedBlocks(ctx)
+	if err != nil {
+		h.logger.Printf("Failed to get failed blocks: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response := &client.FailedBlocksResponse{
+		FailedBlocks: failedBlocks,
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// PostRequeueFailedBlock resets the attempts of a failed block so the worker picks it up again
+func (h *httpHandler) PostRequeueFailedBlock(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	blockNumber, err := strconv.ParseInt(r.PathValue("number"), 10, 64)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	failedBlocks, err := h.blockRepo.GetFailedBlocks(ctx)
+	if err != nil {
+		h.logger.Printf("Failed to get failed blocks: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	for _, failed := range failedBlocks {
+		if failed.Number != blockNumber {
+			continue
+		}
+
+		failed.Attempts = 0
+
+		if err := h.blockRepo.AddFailedBlock(ctx, failed); err != nil {
+			h.logger.Printf("Failed to requeue block %d: %v", blockNumber, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	w.WriteHeader(http.StatusNotFound)
 }
 
 func (h *httpHandler) HandleHealthCheck(w http.ResponseWriter, r *http.Request) {
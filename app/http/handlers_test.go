@@ -0,0 +1,181 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/devshark/tx-parser-go/api"
+	httpHandler "github.com/devshark/tx-parser-go/app/http"
+	"github.com/devshark/tx-parser-go/app/internal/repository"
+	"github.com/devshark/tx-parser-go/client"
+)
+
+// newOrphanedServer serves the records of the subscriber in two blocks, those of block 0xb1
+// orphaned by a reorganization and those of block 0xb2 not
+func newOrphanedServer(t *testing.T) *httptest.Server {
+	ctx := context.Background()
+
+	txRepo := repository.NewInMemoryTransactionRepository()
+
+	for i, blockHash := range []string{"0xb1", "0xb2"} {
+		txHash := []string{"0x1", "0x2"}[i]
+
+		txRepo.SaveTransaction(ctx, subscriber, api.Transaction{Hash: txHash, From: subscriber, BlockHash: blockHash, Status: api.StatusPending})
+		txRepo.SaveTokenTransfer(ctx, subscriber, api.TokenTransfer{TransactionHash: txHash, From: subscriber, Amount: api.NewBigInt(5), BlockHash: blockHash, Status: api.StatusPending})
+		txRepo.SaveNFTTransfer(ctx, subscriber, api.NFTTransfer{TransactionHash: txHash, From: subscriber, TokenID: api.NewBigInt(7), BlockHash: blockHash, Status: api.StatusPending})
+		txRepo.SaveWithdrawal(ctx, subscriber, api.Withdrawal{Index: uint64(i), Address: subscriber, Amount: api.NewBigInt(32), BlockHash: blockHash, Status: api.StatusPending})
+	}
+
+	if err := txRepo.UpdateBlockStatus(ctx, "0xb1", api.StatusOrphaned); err != nil {
+		t.Fatalf("UpdateBlockStatus returned unexpected error: %v", err)
+	}
+
+	router := httpHandler.NewRouter(nil, txRepo, repository.NewInMemorySubscriberRepository(), repository.NewInMemoryBlockRepository(), nil, log.New(io.Discard, "", 0))
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestOrphanedRecords(t *testing.T) {
+	server := newOrphanedServer(t)
+
+	endpoints := []struct {
+		path   string
+		blocks func(body io.Reader) ([]string, error)
+	}{
+		{"/token-transfers/", func(body io.Reader) ([]string, error) {
+			var response client.TokenTransfersResponse
+			err := json.NewDecoder(body).Decode(&response)
+
+			blocks := []string{}
+			for _, transfer := range response.TokenTransfers {
+				blocks = append(blocks, transfer.BlockHash)
+			}
+			return blocks, err
+		}},
+		{"/nft-transfers/", func(body io.Reader) ([]string, error) {
+			var response client.NFTTransfersResponse
+			err := json.NewDecoder(body).Decode(&response)
+
+			blocks := []string{}
+			for _, transfer := range response.NFTTransfers {
+				blocks = append(blocks, transfer.BlockHash)
+			}
+			return blocks, err
+		}},
+		{"/withdrawals/", func(body io.Reader) ([]string, error) {
+			var response client.WithdrawalsResponse
+			err := json.NewDecoder(body).Decode(&response)
+
+			blocks := []string{}
+			for _, withdrawal := range response.Withdrawals {
+				blocks = append(blocks, withdrawal.BlockHash)
+			}
+			return blocks, err
+		}},
+	}
+
+	tests := []struct {
+		query          string
+		expectedStatus int
+		expected       []string
+	}{
+		{"", http.StatusOK, []string{"0xb2"}},
+		{"?status=orphaned", http.StatusOK, []string{"0xb1"}},
+		{"?status=confirmed", http.StatusOK, []string{}},
+		{"?status=unknown", http.StatusBadRequest, nil},
+	}
+
+	for _, endpoint := range endpoints {
+		t.Run(strings.Trim(endpoint.path, "/"), func(t *testing.T) {
+			for _, test := range tests {
+				resp, err := http.Get(server.URL + endpoint.path + subscriber + test.query)
+				if err != nil {
+					t.Fatalf("GET returned unexpected error: %v", err)
+				}
+				defer resp.Body.Close()
+
+				if resp.StatusCode != test.expectedStatus {
+					t.Errorf("%q: expected status %d, got %d", test.query, test.expectedStatus, resp.StatusCode)
+					continue
+				}
+
+				if test.expectedStatus != http.StatusOK {
+					continue
+				}
+
+				blocks, err := endpoint.blocks(resp.Body)
+				if err != nil {
+					t.Fatalf("%q: failed to decode the response: %v", test.query, err)
+				}
+
+				if !slices.Equal(blocks, test.expected) {
+					t.Errorf("%q: expected the records of blocks %v, got %v", test.query, test.expected, blocks)
+				}
+			}
+		})
+	}
+}
+
+func TestActivity_OrphanedRecords(t *testing.T) {
+	server := newOrphanedServer(t)
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"", []string{"0xb2", "0xb2", "0xb2", "0xb2"}},
+		{"?status=orphaned", []string{"0xb1", "0xb1", "0xb1", "0xb1"}},
+	}
+
+	for _, test := range tests {
+		resp, err := http.Get(server.URL + "/activity/" + subscriber + test.query)
+		if err != nil {
+			t.Fatalf("GET returned unexpected error: %v", err)
+		}
+		defer resp.Body.Close()
+
+		var response client.ActivityResponse
+
+		if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+			t.Fatalf("%q: failed to decode the response: %v", test.query, err)
+		}
+
+		// the transaction, the token and NFT transfers and the withdrawal of a single block
+		blocks := []string{}
+		for _, activity := range response.Activity {
+			switch activity.Kind {
+			case api.ActivityTransaction:
+				blocks = append(blocks, activity.Transaction.BlockHash)
+			case api.ActivityTokenTransfer:
+				blocks = append(blocks, activity.TokenTransfer.BlockHash)
+			case api.ActivityNFTTransfer:
+				blocks = append(blocks, activity.NFTTransfer.BlockHash)
+			case api.ActivityWithdrawal:
+				blocks = append(blocks, activity.Withdrawal.BlockHash)
+			}
+		}
+
+		if !slices.Equal(blocks, test.expected) {
+			t.Errorf("%q: expected the activity of blocks %v, got %v", test.query, test.expected, blocks)
+		}
+	}
+
+	resp, err := http.Get(server.URL + "/activity/" + subscriber + "?status=unknown")
+	if err != nil {
+		t.Fatalf("GET returned unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected an unknown status to be rejected, got status %d", resp.StatusCode)
+	}
+}
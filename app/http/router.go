@@ -8,12 +8,15 @@ import (
 
 	"github.com/devshark/tx-parser-go/app/internal/blockchain"
 	"github.com/devshark/tx-parser-go/app/internal/repository"
+	"github.com/devshark/tx-parser-go/app/worker"
 )
 
 func NewRouter(
 	bcClient blockchain.BlockchainClient,
 	transactionRepo repository.TransactionRepository,
 	subscriberRepo repository.SubscriberRepository,
+	blockRepo repository.BlockRepository,
+	backfiller *worker.Backfiller,
 	logger *log.Logger) http.Handler {
 	mux := http.NewServeMux()
 
@@ -21,13 +24,24 @@ func NewRouter(
 		bcClient:        bcClient,
 		transactionRepo: transactionRepo,
 		subscriberRepo:  subscriberRepo,
+		blockRepo:       blockRepo,
+		backfiller:      backfiller,
 		logger:          logger,
 	}
 
 	mux.HandleFunc("GET /healthz", handler.HandleHealthCheck)
 	mux.HandleFunc("GET /block/current", handler.GetCurrentBlock)
 	mux.HandleFunc("GET /transactions/{address}", handler.GetTransactions)
+	mux.HandleFunc("GET /token-transfers/{address}", handler.GetTokenTransfers)
+	mux.HandleFunc("GET /nft-transfers/{address}", handler.GetNFTTransfers)
+	mux.HandleFunc("GET /withdrawals/{address}", handler.GetWithdrawals)
+	mux.HandleFunc("GET /activity/{address}", handler.GetActivity)
 	mux.HandleFunc("POST /subscribe/{address}", handler.PostSubscribeAddress)
+	mux.HandleFunc("DELETE /subscribe/{address}", handler.DeleteSubscribeAddress)
+	mux.HandleFunc("GET /subscriptions", handler.GetSubscriptions)
+	mux.HandleFunc("GET /backfill/{id}", handler.GetBackfillJob)
+	mux.HandleFunc("GET /blocks/failed", handler.GetFailedBlocks)
+	mux.HandleFunc("POST /blocks/failed/{number}/retry", handler.PostRequeueFailedBlock)
 
 	return mux
 }
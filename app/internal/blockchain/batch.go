@@ -0,0 +1,112 @@
+package blockchain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/devshark/tx-parser-go/api"
+)
+
+type rpcBlockResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      int64           `json:"id"`
+	Result  *EthBlockResult `json:"result"`
+	Error   *rpcError       `json:"error"`
+}
+
+// GetBlocksByRange fetches the blocks between from and to (inclusive) with JSON-RPC batches,
+// each batch holds at most batchSize requests. The blocks are returned in order, the ones
+// that could not be fetched are nil and reported through a *BatchError. A batch that failed
+// as a whole, such as a rate limit or a server error, is returned as is so the caller backs off.
+func (c *publicNodeClient) GetBlocksByRange(ctx context.Context, from, to int64) ([]*api.Block, error) {
+	if from < 0 || to < from {
+		return nil, fmt.Errorf("invalid block range %d to %d", from, to)
+	}
+
+	blocks := make([]*api.Block, to-from+1)
+	batchErr := &BatchError{Errors: make(map[int64]error)}
+
+	for start := from; start <= to; start += int64(c.batchSize) {
+		end := min(start+int64(c.batchSize)-1, to)
+
+		if err := c.getBlocksBatch(ctx, start, end, blocks[start-from:end-from+1], batchErr); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(batchErr.Errors) > 0 {
+		return blocks, batchErr
+	}
+
+	return blocks, nil
+}
+
+// getBlocksBatch sends a single batch and fills blocks with the results, correlated by request id
+func (c *publicNodeClient) getBlocksBatch(ctx context.Context, from, to int64, blocks []*api.Block, batchErr *BatchError) error {
+	requests := make([]rpcRequest, 0, to-from+1)
+	for number := from; number <= to; number++ {
+		requests = append(requests, rpcRequest{
+			JSONRPC: "2.0",
+			Method:  "eth_getBlockByNumber",
+			Params:  []any{fmt.Sprintf("0x%x", number), true},
+			ID:      number,
+		})
+	}
+
+	reqBody, err := json.Marshal(requests)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	body, err := c.post(ctx, reqBody)
+	if err != nil {
+		return err
+	}
+
+	var responses []rpcBlockResponse
+
+	if err := json.Unmarshal(body, &responses); err != nil {
+		// nodes that do not support batches answer with a single error object
+		var response rpcMessage
+		if json.Unmarshal(body, &response) == nil && response.Error != nil {
+			return newRPCError(response.Error, nil)
+		}
+
+		return fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	// responses may come back in any order
+	for _, response := range responses {
+		if response.ID < from || response.ID > to {
+			continue
+		}
+
+		var block *api.Block
+
+		switch {
+		case response.Error != nil:
+			err = newRPCError(response.Error, nil)
+		case response.Result == nil:
+			err = fmt.Errorf("%w: block %d", ErrBlockNotAvailable, response.ID)
+		default:
+			block, err = convertBlock(response.Result)
+		}
+
+		if err != nil {
+			batchErr.Errors[response.ID] = err
+			continue
+		}
+
+		blocks[response.ID-from] = block
+	}
+
+	// requests the node did not answer
+	for number := from; number <= to; number++ {
+		if _, failed := batchErr.Errors[number]; blocks[number-from] == nil && !failed {
+			batchErr.Errors[number] = fmt.Errorf("no response for block %d", number)
+		}
+	}
+
+	return nil
+}
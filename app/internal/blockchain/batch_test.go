@@ -0,0 +1,142 @@
+package blockchain_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/devshark/tx-parser-go/app/internal/blockchain"
+)
+
+// newBatchServer answers eth_getBlockByNumber batches in reverse order,
+// failing block 12 with an rpc error and returning null for block 13
+func newBatchServer(t *testing.T, batches *atomic.Int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		batches.Add(1)
+
+		var requests []struct {
+			ID     int64 `json:"id"`
+			Params []any `json:"params"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&requests); err != nil {
+			t.Errorf("Expected a batch request: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		responses := make([]string, 0, len(requests))
+		for i := len(requests) - 1; i >= 0; i-- {
+			number, _ := strconv.ParseInt(strings.TrimPrefix(requests[i].Params[0].(string), "0x"), 16, 64)
+
+			switch number {
+			case 12:
+				responses = append(responses, fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"error":{"code":-32000,"message":"header not found"}}`, requests[i].ID))
+			case 13:
+				responses = append(responses, fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"result":null}`, requests[i].ID))
+			default:
+				responses = append(responses, fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"result":{"number":"0x%x","hash":"0x%d","timestamp":"0x6746c0a3","transactions":[]}}`, requests[i].ID, number, number))
+			}
+		}
+
+		w.Write([]byte("[" + strings.Join(responses, ",") + "]"))
+	}))
+}
+
+func TestPublicNodeClientGetBlocksByRange(t *testing.T) {
+	var batches atomic.Int32
+
+	server := newBatchServer(t, &batches)
+	defer server.Close()
+
+	client := blockchain.NewPublicNodeClient(server.URL, log.Default(), blockchain.WithBatchSize(3))
+
+	rangeClient, ok := client.(blockchain.RangeClient)
+	if !ok {
+		t.Fatal("Expected publicNodeClient to implement RangeClient")
+	}
+
+	blocks, err := rangeClient.GetBlocksByRange(context.Background(), 10, 16)
+
+	var batchErr *blockchain.BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("Expected a BatchError, got %v", err)
+	}
+
+	if len(batchErr.Errors) != 2 || batchErr.Errors[12] == nil || batchErr.Errors[13] == nil {
+		t.Errorf("Expected blocks 12 and 13 to fail, got %v", batchErr.Errors)
+	}
+
+	for _, number := range []int64{12, 13} {
+		if !errors.Is(batchErr.Errors[number], blockchain.ErrBlockNotAvailable) {
+			t.Errorf("Expected block %d to not be available yet, got %v", number, batchErr.Errors[number])
+		}
+	}
+
+	if batches.Load() != 3 {
+		t.Errorf("Expected 3 batches, got %d", batches.Load())
+	}
+
+	if len(blocks) != 7 {
+		t.Fatalf("Expected 7 blocks, got %d", len(blocks))
+	}
+
+	for i, block := range blocks {
+		number := int64(10 + i)
+
+		if number == 12 || number == 13 {
+			if block != nil {
+				t.Errorf("Expected block %d to be nil", number)
+			}
+			continue
+		}
+
+		if block == nil || block.Number != number || block.Hash != fmt.Sprintf("0x%d", number) {
+			t.Errorf("Expected block %d at position %d, got %+v", number, i, block)
+		}
+	}
+
+	// Test invalid range
+	if _, err := rangeClient.GetBlocksByRange(context.Background(), 5, 4); err == nil {
+		t.Fatal("Expected error for invalid range, got nil")
+	}
+}
+
+func TestPublicNodeClientGetBlocksByRange_WholeBatchFailed(t *testing.T) {
+	var requests atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Retry-After", "2")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	client := blockchain.NewPublicNodeClient(server.URL, log.Default(), blockchain.WithBatchSize(3)).(blockchain.RangeClient)
+
+	// the rate limit is returned as is rather than spread over the blocks, and no other batch is sent
+	blocks, err := client.GetBlocksByRange(context.Background(), 10, 16)
+
+	var batchErr *blockchain.BatchError
+	if errors.As(err, &batchErr) || !errors.Is(err, blockchain.ErrRateLimited) || !blockchain.IsRetryable(err) {
+		t.Fatalf("Expected a retryable rate limit, got %v", err)
+	}
+
+	var rpcErr *blockchain.RPCError
+	if !errors.As(err, &rpcErr) || rpcErr.RetryAfter != 2*time.Second {
+		t.Errorf("Expected the Retry-After of the rate limit, got %v", err)
+	}
+
+	if blocks != nil || requests.Load() != 1 {
+		t.Errorf("Expected a single request and no blocks, got %d requests and %v", requests.Load(), blocks)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"math/big"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -18,16 +19,78 @@ type BlockchainClient interface {
 	// Add more methods as needed
 }
 
+// Block tags understood by eth_getBlockByNumber
+const (
+	TagLatest    = "latest"
+	TagSafe      = "safe"
+	TagFinalized = "finalized"
+)
+
+// FinalityClient is implemented by clients that can resolve the node's block tags
+type FinalityClient interface {
+	GetBlockNumberByTag(ctx context.Context, tag string) (int64, error)
+}
+
+// RangeClient is implemented by clients that can fetch consecutive blocks in bulk,
+// blocks that could not be fetched are nil and reported through a *BatchError
+type RangeClient interface {
+	GetBlocksByRange(ctx context.Context, from, to int64) ([]*api.Block, error)
+}
+
+// BatchError reports the blocks of a range that could not be fetched
+type BatchError struct {
+	Errors map[int64]error
+}
+
+func (e *BatchError) Error() string {
+	numbers := make([]int64, 0, len(e.Errors))
+	for number := range e.Errors {
+		numbers = append(numbers, number)
+	}
+
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+
+	return fmt.Sprintf("failed to fetch %d blocks, first is block %d: %v", len(numbers), numbers[0], e.Errors[numbers[0]])
+}
+
+// getBlocksOneByOne fetches consecutive blocks from a client that cannot fetch them in bulk
+func getBlocksOneByOne(ctx context.Context, client BlockchainClient, from, to int64) ([]*api.Block, error) {
+	blocks := make([]*api.Block, 0, to-from+1)
+	for number := from; number <= to; number++ {
+		block, err := client.GetBlockByNumber(ctx, number)
+		if err != nil {
+			return nil, err
+		}
+
+		blocks = append(blocks, block)
+	}
+
+	return blocks, nil
+}
+
 // uses big.Int to parse the hex string
 func HexToInt64(hexStr string) (int64, error) {
 	bigInt := new(big.Int)
 
 	// Parse the hex string (without the "0x" prefix)
-	bigInt.SetString(hexStr[2:], 16)
+	if _, ok := bigInt.SetString(strings.TrimPrefix(hexStr, "0x"), 16); !ok {
+		return 0, fmt.Errorf("%w: invalid hexadecimal string %q", ErrInvalidResponse, hexStr)
+	}
 
 	return bigInt.Int64(), nil
 }
 
+// HexToBigInt parses a hex string without losing precision, such as an amount in wei
+func HexToBigInt(hexStr string) (api.BigInt, error) {
+	bigInt := new(big.Int)
+
+	if _, ok := bigInt.SetString(strings.TrimPrefix(hexStr, "0x"), 16); !ok {
+		return api.BigInt{}, fmt.Errorf("%w: invalid hexadecimal string %q", ErrInvalidResponse, hexStr)
+	}
+
+	return api.BigIntFromBig(bigInt), nil
+}
+
 func HexToInt[T int | uint | uint64](hexStr string) (T, error) {
 	// Remove the "0x" prefix if it exists
 	hexStr = strings.TrimPrefix(hexStr, "0x")
@@ -0,0 +1,543 @@
+package blockchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/devshark/tx-parser-go/api"
+)
+
+const (
+	// DefaultHealthCheckInterval is how often every endpoint's head is checked
+	DefaultHealthCheckInterval = 10 * time.Second
+	// DefaultRequestTimeout bounds a single call before failing over to the next endpoint
+	DefaultRequestTimeout = 10 * time.Second
+	// DefaultMaxHeadLag is how many blocks an endpoint may lag behind the best head before being ejected
+	DefaultMaxHeadLag = 5
+
+	// latencySmoothing is the weight of a new sample in the moving average of an endpoint's latency
+	latencySmoothing = 0.2
+)
+
+// Routing strategies of the FailoverClient
+const (
+	StrategyRoundRobin = "round-robin"
+	StrategyLatency    = "latency"
+)
+
+var (
+	ErrNoEndpoints    = errors.New("no endpoints configured")
+	ErrNotImplemented = errors.New("not implemented by any endpoint")
+
+	errSubscriptionEnded = errors.New("head subscription ended")
+)
+
+// Endpoint is a node the FailoverClient can route calls to
+type Endpoint struct {
+	URL    string
+	Client BlockchainClient
+}
+
+// endpointState is what the FailoverClient knows about an endpoint
+type endpointState struct {
+	Endpoint
+	healthy bool
+	head    int64
+	latency time.Duration // moving average of successful calls
+}
+
+// FailoverClient is a BlockchainClient spreading calls over several endpoints.
+// Failed calls are retried on the next endpoint, and endpoints that fail or lag behind
+// are left out until a health check finds them healthy again.
+type FailoverClient struct {
+	logger              *log.Logger
+	strategy            string
+	maxHeadLag          int64
+	healthCheckInterval time.Duration
+	requestTimeout      time.Duration
+	next                atomic.Uint64 // round-robin counter
+	mu                  sync.RWMutex  // mutex for endpoints
+	endpoints           []*endpointState
+}
+
+// FailoverOption customizes the FailoverClient
+type FailoverOption func(*FailoverClient)
+
+// WithStrategy sets how calls are routed, either StrategyRoundRobin or StrategyLatency
+func WithStrategy(strategy string) FailoverOption {
+	return func(c *FailoverClient) {
+		if strategy == StrategyRoundRobin || strategy == StrategyLatency {
+			c.strategy = strategy
+		}
+	}
+}
+
+// WithMaxHeadLag sets how many blocks an endpoint may lag behind the best head before being ejected
+func WithMaxHeadLag(blocks int64) FailoverOption {
+	return func(c *FailoverClient) {
+		if blocks >= 0 {
+			c.maxHeadLag = blocks
+		}
+	}
+}
+
+// WithHealthCheckInterval sets how often the endpoints are health-checked
+func WithHealthCheckInterval(interval time.Duration) FailoverOption {
+	return func(c *FailoverClient) {
+		if interval > 0 {
+			c.healthCheckInterval = interval
+		}
+	}
+}
+
+// WithRequestTimeout sets how long a call may take before failing over to the next endpoint
+func WithRequestTimeout(timeout time.Duration) FailoverOption {
+	return func(c *FailoverClient) {
+		if timeout > 0 {
+			c.requestTimeout = timeout
+		}
+	}
+}
+
+// NewFailoverClient creates a FailoverClient over the given endpoints, which all start as healthy
+func NewFailoverClient(endpoints []Endpoint, logger *log.Logger, opts ...FailoverOption) *FailoverClient {
+	client := &FailoverClient{
+		logger:              logger,
+		strategy:            StrategyRoundRobin,
+		maxHeadLag:          DefaultMaxHeadLag,
+		healthCheckInterval: DefaultHealthCheckInterval,
+		requestTimeout:      DefaultRequestTimeout,
+		endpoints:           make([]*endpointState, len(endpoints)),
+	}
+
+	for i, endpoint := range endpoints {
+		client.endpoints[i] = &endpointState{Endpoint: endpoint, healthy: true}
+	}
+
+	for _, opt := range opts {
+		opt(client)
+	}
+
+	return client
+}
+
+// Run health-checks the endpoints every health check interval until the context is cancelled
+func (c *FailoverClient) Run(ctx context.Context) error {
+	ticker := time.NewTicker(c.healthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		c.HealthCheck(ctx)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// HealthCheck fetches the head of every endpoint, marking the ones that fail
+// or lag more than the max head lag behind the best head as unhealthy
+func (c *FailoverClient) HealthCheck(ctx context.Context) {
+	c.mu.RLock()
+	endpoints := c.endpoints
+	c.mu.RUnlock()
+
+	heads := make([]int64, len(endpoints))
+	errs := make([]error, len(endpoints))
+	latencies := make([]time.Duration, len(endpoints))
+
+	var wg sync.WaitGroup
+
+	for i, endpoint := range endpoints {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			callCtx, cancel := context.WithTimeout(ctx, c.requestTimeout)
+			defer cancel()
+
+			start := time.Now()
+			heads[i], errs[i] = endpoint.Client.GetLatestBlockNumber(callCtx)
+			latencies[i] = time.Since(start)
+		}()
+	}
+
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return
+	}
+
+	var bestHead int64
+	for i := range endpoints {
+		if errs[i] == nil {
+			bestHead = max(bestHead, heads[i])
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, endpoint := range endpoints {
+		healthy := false
+
+		switch {
+		case errs[i] != nil:
+			c.logger.Printf("endpoint %s failed its health check: %v", endpoint.URL, errs[i])
+		case bestHead-heads[i] > c.maxHeadLag:
+			c.logger.Printf("endpoint %s lags %d blocks behind", endpoint.URL, bestHead-heads[i])
+		default:
+			healthy = true
+			endpoint.head = heads[i]
+			endpoint.observe(latencies[i])
+		}
+
+		if healthy && !endpoint.healthy {
+			c.logger.Printf("endpoint %s is healthy again", endpoint.URL)
+		}
+
+		endpoint.healthy = healthy
+	}
+}
+
+// GetLatestBlockNumber fetches the latest block number
+func (c *FailoverClient) GetLatestBlockNumber(ctx context.Context) (int64, error) {
+	return failover(ctx, c, func(ctx context.Context, client BlockchainClient) (int64, error) {
+		return client.GetLatestBlockNumber(ctx)
+	})
+}
+
+// GetBlockByNumber fetches the block with the given number
+func (c *FailoverClient) GetBlockByNumber(ctx context.Context, number int64) (*api.Block, error) {
+	return failover(ctx, c, func(ctx context.Context, client BlockchainClient) (*api.Block, error) {
+		return client.GetBlockByNumber(ctx, number)
+	})
+}
+
+// GetBlockNumberByTag fetches the number of the block with the given tag,
+// from the endpoints that can resolve block tags
+func (c *FailoverClient) GetBlockNumberByTag(ctx context.Context, tag string) (int64, error) {
+	return failover(ctx, c, func(ctx context.Context, client BlockchainClient) (int64, error) {
+		finalityClient, ok := client.(FinalityClient)
+		if !ok {
+			return 0, ErrNotImplemented
+		}
+
+		return finalityClient.GetBlockNumberByTag(ctx, tag)
+	})
+}
+
+// GetBlockReceipts fetches the receipts of every transaction in a block, from the endpoints that provide receipts
+func (c *FailoverClient) GetBlockReceipts(ctx context.Context, number int64) ([]*api.Receipt, error) {
+	return failover(ctx, c, func(ctx context.Context, client BlockchainClient) ([]*api.Receipt, error) {
+		receiptClient, ok := client.(ReceiptClient)
+		if !ok {
+			return nil, ErrNotImplemented
+		}
+
+		return receiptClient.GetBlockReceipts(ctx, number)
+	})
+}
+
+// GetTransactionReceipt fetches the receipt of a transaction, from the endpoints that provide receipts
+func (c *FailoverClient) GetTransactionReceipt(ctx context.Context, hash string) (*api.Receipt, error) {
+	return failover(ctx, c, func(ctx context.Context, client BlockchainClient) (*api.Receipt, error) {
+		receiptClient, ok := client.(ReceiptClient)
+		if !ok {
+			return nil, ErrNotImplemented
+		}
+
+		return receiptClient.GetTransactionReceipt(ctx, hash)
+	})
+}
+
+// TraceBlockByNumber traces the transactions of a block, from the endpoints that provide traces
+func (c *FailoverClient) TraceBlockByNumber(ctx context.Context, number int64) ([]TransactionTrace, error) {
+	return failover(ctx, c, func(ctx context.Context, client BlockchainClient) ([]TransactionTrace, error) {
+		traceClient, ok := client.(TraceClient)
+		if !ok {
+			return nil, ErrNotImplemented
+		}
+
+		return traceClient.TraceBlockByNumber(ctx, number)
+	})
+}
+
+// GetLogs fetches the logs matching the filter, from the endpoints that can query logs
+func (c *FailoverClient) GetLogs(ctx context.Context, filter LogFilter) ([]api.Log, error) {
+	return failover(ctx, c, func(ctx context.Context, client BlockchainClient) ([]api.Log, error) {
+		logClient, ok := client.(LogClient)
+		if !ok {
+			return nil, ErrNotImplemented
+		}
+
+		return logClient.GetLogs(ctx, filter)
+	})
+}
+
+// GetBlocksByRange fetches consecutive blocks, one by one from endpoints that cannot fetch them in bulk.
+// A batch the endpoint answered does not fail over, its *BatchError is returned along with the blocks.
+func (c *FailoverClient) GetBlocksByRange(ctx context.Context, from, to int64) ([]*api.Block, error) {
+	var partial *BatchError
+
+	blocks, err := failover(ctx, c, func(ctx context.Context, client BlockchainClient) ([]*api.Block, error) {
+		if rangeClient, ok := client.(RangeClient); ok {
+			blocks, err := rangeClient.GetBlocksByRange(ctx, from, to)
+
+			if errors.As(err, &partial) {
+				return blocks, nil
+			}
+
+			partial = nil
+
+			return blocks, err
+		}
+
+		return getBlocksOneByOne(ctx, client, from, to)
+	})
+	if err == nil && partial != nil {
+		return blocks, partial
+	}
+
+	return blocks, err
+}
+
+// SubscribeNewHeads pushes the new heads of an endpoint that can push them, switching over to the next one
+// when its subscription fails or ends, or when it is found unhealthy while another one is healthy.
+// Heads are dropped if the receiver is behind, only the latest one matters.
+func (c *FailoverClient) SubscribeNewHeads(ctx context.Context) (<-chan int64, error) {
+	c.mu.RLock()
+	supported := false
+	for _, endpoint := range c.endpoints {
+		if _, ok := endpoint.Client.(HeadSubscriber); ok {
+			supported = true
+		}
+	}
+	c.mu.RUnlock()
+
+	if !supported {
+		return nil, ErrNotImplemented
+	}
+
+	heads := make(chan int64, 1)
+
+	go func() {
+		defer close(heads)
+
+		delay := minReconnectDelay
+
+		for {
+			if c.relayHeads(ctx, heads) {
+				delay = minReconnectDelay
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+				delay = min(2*delay, maxReconnectDelay)
+			}
+		}
+	}()
+
+	return heads, nil
+}
+
+// relayHeads subscribes to the first candidate endpoint that accepts, and forwards its heads until the
+// subscription ends, the endpoint turns unhealthy or the context is cancelled. It reports whether
+// an endpoint accepted the subscription.
+func (c *FailoverClient) relayHeads(ctx context.Context, heads chan int64) bool {
+	for _, endpoint := range c.candidates() {
+		subscriber, ok := endpoint.Client.(HeadSubscriber)
+		if !ok {
+			continue
+		}
+
+		subCtx, cancel := context.WithCancel(ctx)
+
+		upstream, err := subscriber.SubscribeNewHeads(subCtx)
+		if err != nil {
+			cancel()
+
+			if ctx.Err() != nil {
+				return false
+			}
+
+			if IsRetryable(err) {
+				c.eject(endpoint, err)
+			}
+
+			continue
+		}
+
+		c.forwardHeads(ctx, endpoint, upstream, heads)
+		cancel()
+
+		return true
+	}
+
+	return false
+}
+
+// forwardHeads forwards the heads of the endpoint's subscription until it ends, the endpoint turns
+// unhealthy while another endpoint that can push heads is healthy, or the context is cancelled
+func (c *FailoverClient) forwardHeads(ctx context.Context, endpoint *endpointState, upstream <-chan int64, heads chan int64) {
+	ticker := time.NewTicker(c.healthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case head, ok := <-upstream:
+			if !ok {
+				c.eject(endpoint, errSubscriptionEnded)
+				return
+			}
+
+			pushHead(heads, head)
+		case <-ticker.C:
+			if c.shouldSwitchSubscription(endpoint) {
+				c.logger.Printf("endpoint %s is unhealthy, moving the head subscription", endpoint.URL)
+				return
+			}
+		}
+	}
+}
+
+// shouldSwitchSubscription reports whether the endpoint is unhealthy while another endpoint
+// that can push heads is healthy
+func (c *FailoverClient) shouldSwitchSubscription(active *endpointState) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if active.healthy {
+		return false
+	}
+
+	for _, endpoint := range c.endpoints {
+		if _, ok := endpoint.Client.(HeadSubscriber); ok && endpoint.healthy {
+			return true
+		}
+	}
+
+	return false
+}
+
+// failover tries the call on every candidate endpoint until one succeeds. The endpoints that fail
+// with transport errors, timeouts, server errors or rate limits are marked as unhealthy until the next
+// health check, while the errors any endpoint would answer the same, such as invalid params,
+// are returned at once.
+func failover[T any](ctx context.Context, c *FailoverClient, call func(ctx context.Context, client BlockchainClient) (T, error)) (T, error) {
+	var zero T
+
+	candidates := c.candidates()
+	if len(candidates) == 0 {
+		return zero, ErrNoEndpoints
+	}
+
+	var errs []error
+
+	for _, endpoint := range candidates {
+		callCtx, cancel := context.WithTimeout(ctx, c.requestTimeout)
+
+		start := time.Now()
+		result, err := call(callCtx, endpoint.Client)
+		latency := time.Since(start)
+		timedOut := callCtx.Err() != nil
+
+		cancel()
+
+		if err == nil {
+			c.mu.Lock()
+			endpoint.observe(latency)
+			c.mu.Unlock()
+
+			return result, nil
+		}
+
+		// the caller gave up, there is no point in trying the other endpoints
+		if ctx.Err() != nil {
+			return zero, ctx.Err()
+		}
+
+		// unlike the deadline of the caller, the timeout of a single call is worth retrying
+		if timedOut && errors.Is(err, context.DeadlineExceeded) {
+			err = fmt.Errorf("%w after %s", ErrRequestTimeout, c.requestTimeout)
+		}
+
+		errs = append(errs, fmt.Errorf("%s: %w", endpoint.URL, err))
+
+		switch {
+		case errors.Is(err, ErrNotImplemented) || errors.Is(err, ErrMethodNotFound) || errors.Is(err, ErrBlockNotAvailable):
+			// another endpoint may support the call or have the block already, this one is still healthy
+		case IsRetryable(err):
+			c.eject(endpoint, err)
+		default:
+			return zero, fmt.Errorf("%s: %w", endpoint.URL, err)
+		}
+	}
+
+	return zero, fmt.Errorf("all endpoints failed: %w", errors.Join(errs...))
+}
+
+// candidates returns the endpoints to try in order, the healthy ones first
+func (c *FailoverClient) candidates() []*endpointState {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	healthy := make([]*endpointState, 0, len(c.endpoints))
+	unhealthy := make([]*endpointState, 0)
+
+	for _, endpoint := range c.endpoints {
+		if endpoint.healthy {
+			healthy = append(healthy, endpoint)
+		} else {
+			unhealthy = append(unhealthy, endpoint)
+		}
+	}
+
+	switch c.strategy {
+	case StrategyLatency:
+		sort.SliceStable(healthy, func(i, j int) bool { return healthy[i].latency < healthy[j].latency })
+	default:
+		if len(healthy) > 0 {
+			offset := int((c.next.Add(1) - 1) % uint64(len(healthy)))
+			healthy = append(healthy[offset:], healthy[:offset]...)
+		}
+	}
+
+	// unhealthy endpoints are the last resort, better than failing outright
+	return append(healthy, unhealthy...)
+}
+
+// eject marks the endpoint as unhealthy until the next health check
+func (c *FailoverClient) eject(endpoint *endpointState, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if endpoint.healthy {
+		c.logger.Printf("endpoint %s failed, failing over: %v", endpoint.URL, err)
+	}
+
+	endpoint.healthy = false
+}
+
+// observe adds a latency sample to the moving average
+func (e *endpointState) observe(latency time.Duration) {
+	if e.latency == 0 {
+		e.latency = latency
+		return
+	}
+
+	e.latency = time.Duration((1-latencySmoothing)*float64(e.latency) + latencySmoothing*float64(latency))
+}
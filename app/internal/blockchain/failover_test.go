@@ -0,0 +1,334 @@
+package blockchain_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/devshark/tx-parser-go/api"
+	"github.com/devshark/tx-parser-go/app/internal/blockchain"
+)
+
+// stubNode is an endpoint with a fixed head that can be taken down
+type stubNode struct {
+	mu    sync.Mutex
+	head  int64
+	down  bool
+	delay time.Duration
+	err   error // returned for every block
+	calls atomic.Int32
+}
+
+func (n *stubNode) GetLatestBlockNumber(ctx context.Context) (int64, error) {
+	n.calls.Add(1)
+
+	n.mu.Lock()
+	head, down, delay := n.head, n.down, n.delay
+	n.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	case <-time.After(delay):
+	}
+
+	if down {
+		return 0, errors.New("connection refused")
+	}
+
+	return head, nil
+}
+
+func (n *stubNode) GetBlockByNumber(ctx context.Context, number int64) (*api.Block, error) {
+	if _, err := n.GetLatestBlockNumber(ctx); err != nil {
+		return nil, err
+	}
+
+	if n.err != nil {
+		return nil, n.err
+	}
+
+	return &api.Block{Number: number}, nil
+}
+
+func (n *stubNode) setDown(down bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.down = down
+}
+
+func newFailoverClient(nodes []*stubNode, opts ...blockchain.FailoverOption) *blockchain.FailoverClient {
+	endpoints := make([]blockchain.Endpoint, len(nodes))
+	for i, node := range nodes {
+		endpoints[i] = blockchain.Endpoint{URL: string(rune('a' + i)), Client: node}
+	}
+
+	return blockchain.NewFailoverClient(endpoints, log.Default(), opts...)
+}
+
+func TestFailoverClient_RoundRobin(t *testing.T) {
+	nodes := []*stubNode{{head: 100}, {head: 100}, {head: 100}}
+	client := newFailoverClient(nodes)
+
+	ctx := context.Background()
+
+	for i := 0; i < 9; i++ {
+		if _, err := client.GetBlockByNumber(ctx, 1); err != nil {
+			t.Fatalf("GetBlockByNumber returned an error: %v", err)
+		}
+	}
+
+	for i, node := range nodes {
+		if calls := node.calls.Load(); calls != 3 {
+			t.Errorf("Expected endpoint %d to get 3 calls, got %d", i, calls)
+		}
+	}
+}
+
+func TestFailoverClient_Failover(t *testing.T) {
+	nodes := []*stubNode{{head: 100}, {head: 100}}
+	client := newFailoverClient(nodes)
+
+	ctx := context.Background()
+
+	nodes[0].setDown(true)
+
+	for i := 0; i < 4; i++ {
+		head, err := client.GetLatestBlockNumber(ctx)
+		if err != nil {
+			t.Fatalf("GetLatestBlockNumber returned an error: %v", err)
+		}
+		if head != 100 {
+			t.Errorf("Expected head 100, got %d", head)
+		}
+	}
+
+	// the failed endpoint is only tried once, then left out until it is healthy again
+	if calls := nodes[0].calls.Load(); calls != 1 {
+		t.Errorf("Expected the failed endpoint to get 1 call, got %d", calls)
+	}
+
+	nodes[0].setDown(false)
+	client.HealthCheck(ctx)
+
+	nodes[0].calls.Store(0)
+	for i := 0; i < 4; i++ {
+		client.GetLatestBlockNumber(ctx)
+	}
+
+	if calls := nodes[0].calls.Load(); calls != 2 {
+		t.Errorf("Expected the recovered endpoint to get 2 calls, got %d", calls)
+	}
+
+	// every endpoint is down
+	nodes[0].setDown(true)
+	nodes[1].setDown(true)
+
+	if _, err := client.GetLatestBlockNumber(ctx); err == nil {
+		t.Error("Expected an error when every endpoint is down, got nil")
+	}
+}
+
+func TestFailoverClient_Timeout(t *testing.T) {
+	nodes := []*stubNode{{head: 100, delay: time.Second}, {head: 100}}
+	client := newFailoverClient(nodes, blockchain.WithRequestTimeout(50*time.Millisecond))
+
+	start := time.Now()
+
+	if _, err := client.GetLatestBlockNumber(context.Background()); err != nil {
+		t.Fatalf("GetLatestBlockNumber returned an error: %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected the slow endpoint to time out, the call took %s", elapsed)
+	}
+}
+
+func TestFailoverClient_HeadLag(t *testing.T) {
+	nodes := []*stubNode{{head: 90}, {head: 100}, {head: 98}}
+	client := newFailoverClient(nodes, blockchain.WithMaxHeadLag(5))
+
+	ctx := context.Background()
+	client.HealthCheck(ctx)
+
+	for _, node := range nodes {
+		node.calls.Store(0)
+	}
+
+	for i := 0; i < 4; i++ {
+		if head, _ := client.GetLatestBlockNumber(ctx); head == 90 {
+			t.Fatal("Expected the lagging endpoint to be ejected")
+		}
+	}
+
+	if calls := nodes[0].calls.Load(); calls != 0 {
+		t.Errorf("Expected the lagging endpoint to get no calls, got %d", calls)
+	}
+}
+
+func TestFailoverClient_Latency(t *testing.T) {
+	nodes := []*stubNode{{head: 100, delay: 30 * time.Millisecond}, {head: 100}}
+	client := newFailoverClient(nodes, blockchain.WithStrategy(blockchain.StrategyLatency))
+
+	ctx := context.Background()
+	client.HealthCheck(ctx)
+
+	for _, node := range nodes {
+		node.calls.Store(0)
+	}
+
+	for i := 0; i < 4; i++ {
+		client.GetLatestBlockNumber(ctx)
+	}
+
+	if calls := nodes[1].calls.Load(); calls != 4 {
+		t.Errorf("Expected the fastest endpoint to get 4 calls, got %d", calls)
+	}
+}
+
+func TestFailoverClient_RequestErrors(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("block not available", func(t *testing.T) {
+		nodes := []*stubNode{{head: 99, err: fmt.Errorf("%w: block 100", blockchain.ErrBlockNotAvailable)}, {head: 100}}
+		client := newFailoverClient(nodes, blockchain.WithStrategy(blockchain.StrategyLatency))
+
+		// the endpoint a block behind is skipped, but stays in rotation
+		for i := 0; i < 2; i++ {
+			if block, err := client.GetBlockByNumber(ctx, 100); err != nil || block.Number != 100 {
+				t.Fatalf("Expected block 100 from the other endpoint, got %+v, %v", block, err)
+			}
+		}
+
+		if calls := nodes[0].calls.Load(); calls != 2 {
+			t.Errorf("Expected the endpoint behind to be tried on every call, got %d calls", calls)
+		}
+	})
+
+	t.Run("invalid params", func(t *testing.T) {
+		invalid := &blockchain.RPCError{Code: -32602, Message: "invalid argument 0"}
+
+		nodes := []*stubNode{{head: 100, err: invalid}, {head: 100, err: invalid}}
+		client := newFailoverClient(nodes, blockchain.WithStrategy(blockchain.StrategyLatency))
+
+		// the error is the caller's, returned at once and without ejecting the endpoint
+		for i := 0; i < 2; i++ {
+			var rpcErr *blockchain.RPCError
+			if _, err := client.GetBlockByNumber(ctx, 1); !errors.As(err, &rpcErr) || rpcErr.Code != -32602 {
+				t.Fatalf("Expected the invalid params error, got %v", err)
+			}
+		}
+
+		if calls := nodes[0].calls.Load(); calls != 2 {
+			t.Errorf("Expected the first endpoint to answer both calls, got %d calls", calls)
+		}
+
+		if calls := nodes[1].calls.Load(); calls != 0 {
+			t.Errorf("Expected the second endpoint not to be tried, got %d calls", calls)
+		}
+	})
+}
+
+// headsNode is a stubNode that can push new heads, each subscription on a feed of its own
+type headsNode struct {
+	*stubNode
+	feeds chan chan int64
+}
+
+func (n *headsNode) SubscribeNewHeads(ctx context.Context) (<-chan int64, error) {
+	if _, err := n.GetLatestBlockNumber(ctx); err != nil {
+		return nil, err
+	}
+
+	feed := make(chan int64)
+	n.feeds <- feed
+
+	return feed, nil
+}
+
+func TestFailoverClient_SubscribeNewHeads(t *testing.T) {
+	nodes := []*headsNode{
+		{stubNode: &stubNode{head: 100}, feeds: make(chan chan int64, 10)},
+		{stubNode: &stubNode{head: 100}, feeds: make(chan chan int64, 10)},
+	}
+
+	endpoints := make([]blockchain.Endpoint, len(nodes))
+	for i, node := range nodes {
+		endpoints[i] = blockchain.Endpoint{URL: string(rune('a' + i)), Client: node}
+	}
+
+	client := blockchain.NewFailoverClient(endpoints, log.Default(), blockchain.WithHealthCheckInterval(10*time.Millisecond))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	subscribed := func(node *headsNode) chan int64 {
+		t.Helper()
+
+		select {
+		case feed := <-node.feeds:
+			return feed
+		case <-ctx.Done():
+			t.Fatal("Expected a subscription to the endpoint")
+			return nil
+		}
+	}
+
+	receive := func(heads <-chan int64, expected int64) {
+		t.Helper()
+
+		select {
+		case head := <-heads:
+			if head != expected {
+				t.Errorf("Expected head %d, got %d", expected, head)
+			}
+		case <-ctx.Done():
+			t.Fatalf("Expected head %d", expected)
+		}
+	}
+
+	// the first endpoint fails to subscribe, the second one pushes the heads
+	nodes[0].setDown(true)
+
+	heads, err := client.SubscribeNewHeads(ctx)
+	if err != nil {
+		t.Fatalf("SubscribeNewHeads returned an error: %v", err)
+	}
+
+	feed := subscribed(nodes[1])
+	feed <- 101
+	receive(heads, 101)
+
+	// the subscription of the second endpoint ends, the first one is back up
+	nodes[0].setDown(false)
+	close(feed)
+
+	feed = subscribed(nodes[0])
+	feed <- 102
+	receive(heads, 102)
+
+	// the health check finds the first endpoint down while the second one is healthy
+	nodes[0].setDown(true)
+	client.HealthCheck(ctx)
+
+	feed = subscribed(nodes[1])
+	feed <- 103
+	receive(heads, 103)
+
+	// the channel is closed once the context is cancelled
+	cancel()
+
+	for range heads {
+	}
+
+	// without an endpoint that can push heads, the caller polls instead
+	if _, err := newFailoverClient([]*stubNode{{head: 100}}).SubscribeNewHeads(context.Background()); !errors.Is(err, blockchain.ErrNotImplemented) {
+		t.Errorf("Expected ErrNotImplemented, got %v", err)
+	}
+}
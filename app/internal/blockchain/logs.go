@@ -0,0 +1,172 @@
+package blockchain
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/devshark/tx-parser-go/api"
+)
+
+// TransferEventTopic is the topic of Transfer(address,address,uint256), emitted by ERC-20 and ERC-721 tokens
+const TransferEventTopic = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+
+// LogFilter selects the logs returned by eth_getLogs, either of a block range or of the block
+// with the given hash. Each position of Topics lists the accepted values of that topic,
+// an empty position accepts anything.
+type LogFilter struct {
+	FromBlock int64
+	ToBlock   int64
+	BlockHash string
+	Addresses []string
+	Topics    [][]string
+}
+
+// LogClient is implemented by clients that can query the logs of a block range
+type LogClient interface {
+	GetLogs(ctx context.Context, filter LogFilter) ([]api.Log, error)
+}
+
+// EthLog is a log as returned by eth_getLogs and within receipts
+type EthLog struct {
+	Address          string   `json:"address"`
+	Topics           []string `json:"topics"`
+	Data             string   `json:"data"`
+	BlockNumber      string   `json:"blockNumber"`
+	BlockHash        string   `json:"blockHash"`
+	TransactionHash  string   `json:"transactionHash"`
+	TransactionIndex string   `json:"transactionIndex"`
+	LogIndex         string   `json:"logIndex"`
+	Removed          bool     `json:"removed"`
+}
+
+func (c *publicNodeClient) GetLogs(ctx context.Context, filter LogFilter) ([]api.Log, error) {
+	return getLogs(ctx, c, filter)
+}
+
+func (c *webSocketClient) GetLogs(ctx context.Context, filter LogFilter) ([]api.Log, error) {
+	return getLogs(ctx, c, filter)
+}
+
+func getLogs(ctx context.Context, c rpcCaller, filter LogFilter) ([]api.Log, error) {
+	params := map[string]any{}
+
+	if filter.BlockHash != "" {
+		params["blockHash"] = filter.BlockHash
+	} else {
+		params["fromBlock"] = fmt.Sprintf("0x%x", filter.FromBlock)
+		params["toBlock"] = fmt.Sprintf("0x%x", filter.ToBlock)
+	}
+
+	if len(filter.Addresses) > 0 {
+		params["address"] = filter.Addresses
+	}
+
+	if len(filter.Topics) > 0 {
+		topics := make([]any, len(filter.Topics))
+		for i, values := range filter.Topics {
+			if len(values) > 0 {
+				topics[i] = values
+			}
+		}
+
+		params["topics"] = topics
+	}
+
+	var result []EthLog
+
+	if err := c.call(ctx, "eth_getLogs", []any{params}, &result); err != nil {
+		return nil, err
+	}
+
+	logs := make([]api.Log, 0, len(result))
+	for _, l := range result {
+		if l.Removed {
+			continue
+		}
+
+		converted, err := convertLog(&l)
+		if err != nil {
+			return nil, err
+		}
+
+		logs = append(logs, converted)
+	}
+
+	return logs, nil
+}
+
+// convertLog converts the node's representation of a log to an api.Log
+func convertLog(l *EthLog) (api.Log, error) {
+	logIndex, err := HexToInt[uint](l.LogIndex)
+	if err != nil {
+		return api.Log{}, fmt.Errorf("failed to parse log index: %w", err)
+	}
+
+	var blockNumber int64
+	if l.BlockNumber != "" {
+		if blockNumber, err = HexToInt64(l.BlockNumber); err != nil {
+			return api.Log{}, fmt.Errorf("failed to parse block number: %w", err)
+		}
+	}
+
+	return api.Log{
+		Address:         l.Address,
+		Topics:          l.Topics,
+		Data:            l.Data,
+		LogIndex:        logIndex,
+		TransactionHash: l.TransactionHash,
+		BlockHash:       l.BlockHash,
+		BlockNumber:     blockNumber,
+	}, nil
+}
+
+// AddressTopic encodes an address as an indexed event argument, to filter logs by it
+func AddressTopic(address string) string {
+	return "0x" + strings.Repeat("0", 24) + strings.ToLower(strings.TrimPrefix(address, "0x"))
+}
+
+// TopicAddress decodes an address from an indexed event argument
+func TopicAddress(topic string) (string, bool) {
+	topic = strings.TrimPrefix(topic, "0x")
+	if len(topic) != 64 {
+		return "", false
+	}
+
+	return "0x" + strings.ToLower(topic[24:]), true
+}
+
+// DecodeTokenTransfer decodes an ERC-20 Transfer log. ERC-721 transfers share the same event
+// but index the token id as a fourth topic, they are decoded by DecodeNFTTransfers.
+func DecodeTokenTransfer(l api.Log) (api.TokenTransfer, bool) {
+	if len(l.Topics) != 3 || !strings.EqualFold(l.Topics[0], TransferEventTopic) {
+		return api.TokenTransfer{}, false
+	}
+
+	from, okFrom := TopicAddress(l.Topics[1])
+	to, okTo := TopicAddress(l.Topics[2])
+	if !okFrom || !okTo {
+		return api.TokenTransfer{}, false
+	}
+
+	data := strings.TrimPrefix(l.Data, "0x")
+	if len(data) != 64 {
+		return api.TokenTransfer{}, false
+	}
+
+	amount, err := HexToBigInt(data)
+	if err != nil {
+		return api.TokenTransfer{}, false
+	}
+
+	return api.TokenTransfer{
+		Contract:        strings.ToLower(l.Address),
+		From:            from,
+		To:              to,
+		Amount:          amount,
+		TransactionHash: l.TransactionHash,
+		LogIndex:        l.LogIndex,
+		BlockHash:       l.BlockHash,
+		BlockNumber:     l.BlockNumber,
+	}, true
+}
@@ -0,0 +1,102 @@
+package blockchain_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/devshark/tx-parser-go/api"
+	"github.com/devshark/tx-parser-go/app/internal/blockchain"
+)
+
+const (
+	sender    = "0x00000000000000000000000000000000000000aa"
+	recipient = "0x00000000000000000000000000000000000000bb"
+)
+
+func TestDecodeTokenTransfer(t *testing.T) {
+	transferLog := api.Log{
+		Address: "0xdAC17F958D2ee523a2206206994597C13D831ec7",
+		Topics:  []string{blockchain.TransferEventTopic, blockchain.AddressTopic(sender), blockchain.AddressTopic(recipient)},
+		// 1,000,000 USDT with 6 decimals
+		Data:            "0x000000000000000000000000000000000000000000000000000000e8d4a51000",
+		TransactionHash: "0x1",
+		LogIndex:        3,
+	}
+
+	transfer, ok := blockchain.DecodeTokenTransfer(transferLog)
+	if !ok {
+		t.Fatal("Expected the log to decode as a token transfer")
+	}
+
+	if transfer.Contract != "0xdac17f958d2ee523a2206206994597c13d831ec7" || transfer.From != sender || transfer.To != recipient {
+		t.Errorf("Unexpected transfer %+v", transfer)
+	}
+
+	if transfer.Amount.Format(6) != "1000000" {
+		t.Errorf("Expected an amount of 1000000 tokens, got %s", transfer.Amount.Format(6))
+	}
+
+	// an ERC-721 transfer indexes the token id as a fourth topic
+	nftLog := transferLog
+	nftLog.Topics = append(nftLog.Topics, "0x0000000000000000000000000000000000000000000000000000000000000001")
+	nftLog.Data = "0x"
+
+	if _, ok := blockchain.DecodeTokenTransfer(nftLog); ok {
+		t.Error("Expected an ERC-721 transfer to not decode as a token transfer")
+	}
+
+	otherLog := transferLog
+	otherLog.Topics = []string{"0x8c5be1e5ebec7d5bd14f71427d1e84f3dd0314c0f7b2291e5b200ac8c7c3b925"}
+
+	if _, ok := blockchain.DecodeTokenTransfer(otherLog); ok {
+		t.Error("Expected an Approval log to not decode as a token transfer")
+	}
+}
+
+func TestPublicNodeClientGetLogs(t *testing.T) {
+	var params map[string]any
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Method string           `json:"method"`
+			Params []map[string]any `json:"params"`
+		}
+
+		json.NewDecoder(r.Body).Decode(&request)
+		params = request.Params[0]
+
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":1,"result":[
+			{"address":"0xc0ffee","topics":[%q],"data":"0x","blockNumber":"0x10","blockHash":"0xb16","transactionHash":"0x1","logIndex":"0x0"},
+			{"address":"0xc0ffee","topics":[%q],"data":"0x","blockNumber":"0x10","blockHash":"0xb16","transactionHash":"0x1","logIndex":"0x1","removed":true}
+		]}`, blockchain.TransferEventTopic, blockchain.TransferEventTopic)
+	}))
+	defer server.Close()
+
+	client := blockchain.NewPublicNodeClient(server.URL, log.Default()).(blockchain.LogClient)
+
+	logs, err := client.GetLogs(context.Background(), blockchain.LogFilter{
+		BlockHash: "0xb16",
+		Topics:    [][]string{{blockchain.TransferEventTopic}, nil, {blockchain.AddressTopic(recipient)}},
+	})
+	if err != nil {
+		t.Fatalf("GetLogs returned an error: %v", err)
+	}
+
+	if len(logs) != 1 || logs[0].BlockNumber != 16 || logs[0].BlockHash != "0xb16" {
+		t.Errorf("Expected the log that was not removed, got %+v", logs)
+	}
+
+	if params["blockHash"] != "0xb16" || params["fromBlock"] != nil {
+		t.Errorf("Expected the logs to be filtered by block hash, got %v", params)
+	}
+
+	topics, _ := params["topics"].([]any)
+	if len(topics) != 3 || topics[1] != nil {
+		t.Errorf("Expected 3 topics with a wildcard in the middle, got %v", params["topics"])
+	}
+}
@@ -0,0 +1,165 @@
+package blockchain
+
+import (
+	"strings"
+
+	"github.com/devshark/tx-parser-go/api"
+)
+
+const (
+	// TransferSingleEventTopic is the topic of the ERC-1155 TransferSingle(address,address,address,uint256,uint256)
+	TransferSingleEventTopic = "0xc3d58168c5ae7397731d063d5bbf3d657854427343f4c083240f7aacaa2d0f62"
+	// TransferBatchEventTopic is the topic of the ERC-1155 TransferBatch(address,address,address,uint256[],uint256[])
+	TransferBatchEventTopic = "0x4a39dc06d4c0dbc64b70af90fd698a233a518aa5d07e595d983b8c0526c8f7fb"
+)
+
+// wordSize is the length of an ABI encoded word in hex characters
+const wordSize = 64
+
+// DecodeNFTTransfers decodes an ERC-721 Transfer or an ERC-1155 TransferSingle or TransferBatch log,
+// the latter results in one transfer per token
+func DecodeNFTTransfers(l api.Log) ([]api.NFTTransfer, bool) {
+	if len(l.Topics) != 4 {
+		return nil, false
+	}
+
+	switch strings.ToLower(l.Topics[0]) {
+	case TransferEventTopic:
+		return decodeERC721Transfer(l)
+	case TransferSingleEventTopic, TransferBatchEventTopic:
+		return decodeERC1155Transfer(l)
+	default:
+		return nil, false
+	}
+}
+
+// decodeERC721Transfer decodes Transfer(address indexed from, address indexed to, uint256 indexed tokenId)
+func decodeERC721Transfer(l api.Log) ([]api.NFTTransfer, bool) {
+	from, okFrom := TopicAddress(l.Topics[1])
+	to, okTo := TopicAddress(l.Topics[2])
+	if !okFrom || !okTo {
+		return nil, false
+	}
+
+	tokenID, err := HexToBigInt(l.Topics[3])
+	if err != nil {
+		return nil, false
+	}
+
+	transfer := newNFTTransfer(l, api.StandardERC721)
+	transfer.From = from
+	transfer.To = to
+	transfer.TokenID = tokenID
+	transfer.Amount = api.NewBigInt(1)
+
+	return []api.NFTTransfer{transfer}, true
+}
+
+// decodeERC1155Transfer decodes TransferSingle(address indexed operator, address indexed from,
+// address indexed to, uint256 id, uint256 value) and TransferBatch(address indexed operator,
+// address indexed from, address indexed to, uint256[] ids, uint256[] values)
+func decodeERC1155Transfer(l api.Log) ([]api.NFTTransfer, bool) {
+	operator, okOperator := TopicAddress(l.Topics[1])
+	from, okFrom := TopicAddress(l.Topics[2])
+	to, okTo := TopicAddress(l.Topics[3])
+	if !okOperator || !okFrom || !okTo {
+		return nil, false
+	}
+
+	data := strings.TrimPrefix(l.Data, "0x")
+
+	var ids, values []api.BigInt
+
+	if strings.EqualFold(l.Topics[0], TransferSingleEventTopic) {
+		words, ok := decodeWords(data)
+		if !ok || len(words) != 2 {
+			return nil, false
+		}
+
+		ids, values = words[:1], words[1:]
+	} else {
+		var okIDs, okValues bool
+
+		ids, okIDs = decodeArray(data, 0)
+		values, okValues = decodeArray(data, 1)
+		if !okIDs || !okValues || len(ids) != len(values) {
+			return nil, false
+		}
+	}
+
+	transfers := make([]api.NFTTransfer, len(ids))
+	for i := range ids {
+		transfer := newNFTTransfer(l, api.StandardERC1155)
+		transfer.Operator = operator
+		transfer.From = from
+		transfer.To = to
+		transfer.TokenID = ids[i]
+		transfer.Amount = values[i]
+		transfer.BatchIndex = uint(i)
+
+		transfers[i] = transfer
+	}
+
+	return transfers, true
+}
+
+func newNFTTransfer(l api.Log, standard api.TokenStandard) api.NFTTransfer {
+	return api.NFTTransfer{
+		Standard:        standard,
+		Contract:        strings.ToLower(l.Address),
+		TransactionHash: l.TransactionHash,
+		LogIndex:        l.LogIndex,
+		BlockHash:       l.BlockHash,
+		BlockNumber:     l.BlockNumber,
+	}
+}
+
+// decodeWords splits ABI encoded data into uint256 words
+func decodeWords(data string) ([]api.BigInt, bool) {
+	if len(data)%wordSize != 0 {
+		return nil, false
+	}
+
+	words := make([]api.BigInt, 0, len(data)/wordSize)
+	for i := 0; i < len(data); i += wordSize {
+		word, err := HexToBigInt(data[i : i+wordSize])
+		if err != nil {
+			return nil, false
+		}
+
+		words = append(words, word)
+	}
+
+	return words, true
+}
+
+// decodeArray decodes the uint256[] that is the given argument of ABI encoded data,
+// the argument holds the offset in bytes of the array's length followed by its elements
+func decodeArray(data string, argument int) ([]api.BigInt, bool) {
+	offset, ok := decodeLength(data, argument*wordSize)
+	if !ok || offset%32 != 0 {
+		return nil, false
+	}
+
+	start := offset*2 + wordSize
+	length, ok := decodeLength(data, start-wordSize)
+	if !ok || length > (len(data)-start)/wordSize {
+		return nil, false
+	}
+
+	return decodeWords(data[start : start+length*wordSize])
+}
+
+// decodeLength decodes the word at the given position as an offset or a length
+func decodeLength(data string, position int) (int, bool) {
+	if position < 0 || position+wordSize > len(data) {
+		return 0, false
+	}
+
+	word, err := HexToBigInt(data[position : position+wordSize])
+	if err != nil || !word.Big().IsInt64() || word.Int64() > int64(len(data)) {
+		return 0, false
+	}
+
+	return int(word.Int64()), true
+}
@@ -0,0 +1,108 @@
+package blockchain_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/devshark/tx-parser-go/api"
+	"github.com/devshark/tx-parser-go/app/internal/blockchain"
+)
+
+const operator = "0x00000000000000000000000000000000000000cc"
+
+func word(value int) string {
+	return fmt.Sprintf("%064x", value)
+}
+
+func TestDecodeNFTTransfers(t *testing.T) {
+	t.Run("ERC721", func(t *testing.T) {
+		transfers, ok := blockchain.DecodeNFTTransfers(api.Log{
+			Address:         "0xBC4CA0EdA7647A8aB7C2061c2E118A18a936f13D",
+			Topics:          []string{blockchain.TransferEventTopic, blockchain.AddressTopic(sender), blockchain.AddressTopic(recipient), "0x" + word(1234)},
+			Data:            "0x",
+			TransactionHash: "0x1",
+			LogIndex:        2,
+		})
+		if !ok || len(transfers) != 1 {
+			t.Fatalf("Expected the log to decode as a single NFT transfer, got %+v", transfers)
+		}
+
+		transfer := transfers[0]
+		if transfer.Standard != api.StandardERC721 || transfer.Contract != "0xbc4ca0eda7647a8ab7c2061c2e118a18a936f13d" || transfer.From != sender || transfer.To != recipient {
+			t.Errorf("Unexpected transfer %+v", transfer)
+		}
+
+		if transfer.TokenID.String() != "1234" || transfer.Amount.String() != "1" || transfer.LogIndex != 2 {
+			t.Errorf("Expected token 1234 to be transferred once, got %+v", transfer)
+		}
+	})
+
+	t.Run("ERC1155Single", func(t *testing.T) {
+		transfers, ok := blockchain.DecodeNFTTransfers(api.Log{
+			Address: "0xc0ffee",
+			Topics:  []string{blockchain.TransferSingleEventTopic, blockchain.AddressTopic(operator), blockchain.AddressTopic(sender), blockchain.AddressTopic(recipient)},
+			Data:    "0x" + word(7) + word(25),
+		})
+		if !ok || len(transfers) != 1 {
+			t.Fatalf("Expected the log to decode as a single NFT transfer, got %+v", transfers)
+		}
+
+		transfer := transfers[0]
+		if transfer.Standard != api.StandardERC1155 || transfer.Operator != operator || transfer.From != sender || transfer.To != recipient {
+			t.Errorf("Unexpected transfer %+v", transfer)
+		}
+
+		if transfer.TokenID.String() != "7" || transfer.Amount.String() != "25" {
+			t.Errorf("Expected 25 of token 7 to be transferred, got %+v", transfer)
+		}
+	})
+
+	t.Run("ERC1155Batch", func(t *testing.T) {
+		// ids and values are dynamic arrays, referenced by their offset in bytes
+		data := "0x" + word(64) + word(160) +
+			word(2) + word(7) + word(8) +
+			word(2) + word(25) + word(1)
+
+		transfers, ok := blockchain.DecodeNFTTransfers(api.Log{
+			Address: "0xc0ffee",
+			Topics:  []string{blockchain.TransferBatchEventTopic, blockchain.AddressTopic(operator), blockchain.AddressTopic(sender), blockchain.AddressTopic(recipient)},
+			Data:    data,
+		})
+		if !ok || len(transfers) != 2 {
+			t.Fatalf("Expected the log to decode as two NFT transfers, got %+v", transfers)
+		}
+
+		for i, expected := range [][2]string{{"7", "25"}, {"8", "1"}} {
+			if transfers[i].TokenID.String() != expected[0] || transfers[i].Amount.String() != expected[1] || transfers[i].BatchIndex != uint(i) {
+				t.Errorf("Expected %s of token %s at batch index %d, got %+v", expected[1], expected[0], i, transfers[i])
+			}
+		}
+	})
+
+	t.Run("Malformed", func(t *testing.T) {
+		logs := map[string]api.Log{
+			"ERC-20 transfer": {
+				Topics: []string{blockchain.TransferEventTopic, blockchain.AddressTopic(sender), blockchain.AddressTopic(recipient)},
+				Data:   "0x" + word(1),
+			},
+			"truncated single": {
+				Topics: []string{blockchain.TransferSingleEventTopic, blockchain.AddressTopic(operator), blockchain.AddressTopic(sender), blockchain.AddressTopic(recipient)},
+				Data:   "0x" + word(7),
+			},
+			"out of bounds batch": {
+				Topics: []string{blockchain.TransferBatchEventTopic, blockchain.AddressTopic(operator), blockchain.AddressTopic(sender), blockchain.AddressTopic(recipient)},
+				Data:   "0x" + word(64) + word(160) + word(5) + word(7),
+			},
+			"mismatched batch": {
+				Topics: []string{blockchain.TransferBatchEventTopic, blockchain.AddressTopic(operator), blockchain.AddressTopic(sender), blockchain.AddressTopic(recipient)},
+				Data:   "0x" + word(64) + word(128) + word(1) + word(7) + word(0),
+			},
+		}
+
+		for name, l := range logs {
+			if transfers, ok := blockchain.DecodeNFTTransfers(l); ok {
+				t.Errorf("Expected the %s log to not decode as NFT transfers, got %+v", name, transfers)
+			}
+		}
+	})
+}
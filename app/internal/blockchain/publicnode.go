@@ -1,142 +1,206 @@
 package blockchain
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
-	"strings"
 
 	"github.com/devshark/tx-parser-go/api"
 )
 
+// DefaultBatchSize is the number of requests sent in a single JSON-RPC batch
+const DefaultBatchSize = 20
+
 // publicNodeClient is a BlockchainClient for interacting with a public Ethereum node
 type publicNodeClient struct {
 	publicNodeURL string
 	logger        *log.Logger
+	batchSize     int
+	httpClient    *http.Client
+}
+
+// PublicNodeOption customizes the publicNodeClient
+type PublicNodeOption func(*publicNodeClient)
+
+// WithHTTPClient sets the http client used to reach the node
+func WithHTTPClient(httpClient *http.Client) PublicNodeOption {
+	return func(c *publicNodeClient) {
+		if httpClient != nil {
+			c.httpClient = httpClient
+		}
+	}
+}
+
+// WithBatchSize sets the number of requests sent in a single JSON-RPC batch
+func WithBatchSize(size int) PublicNodeOption {
+	return func(c *publicNodeClient) {
+		if size > 0 {
+			c.batchSize = size
+		}
+	}
 }
 
-func NewPublicNodeClient(publicNodeURL string, logger *log.Logger) BlockchainClient {
-	return &publicNodeClient{publicNodeURL: publicNodeURL, logger: logger}
+func NewPublicNodeClient(publicNodeURL string, logger *log.Logger, opts ...PublicNodeOption) BlockchainClient {
+	client := &publicNodeClient{publicNodeURL: publicNodeURL, logger: logger, batchSize: DefaultBatchSize, httpClient: http.DefaultClient}
+
+	for _, opt := range opts {
+		opt(client)
+	}
+
+	return client
 }
 
 type EthBlock struct {
-	Jsonrpc string `json:"jsonrpc"`
-	ID      int    `json:"id"`
-	Result  struct {
-		Hash                  string `json:"hash"`
-		ParentHash            string `json:"parentHash"`
-		Sha3Uncles            string `json:"sha3Uncles"`
-		Miner                 string `json:"miner"`
-		StateRoot             string `json:"stateRoot"`
-		TransactionsRoot      string `json:"transactionsRoot"`
-		ReceiptsRoot          string `json:"receiptsRoot"`
-		LogsBloom             string `json:"logsBloom"`
-		Difficulty            string `json:"difficulty"`
-		Number                string `json:"number"`
-		GasLimit              string `json:"gasLimit"`
-		GasUsed               string `json:"gasUsed"`
-		Timestamp             string `json:"timestamp"`
-		ExtraData             string `json:"extraData"`
-		MixHash               string `json:"mixHash"`
-		Nonce                 string `json:"nonce"`
-		BaseFeePerGas         string `json:"baseFeePerGas"`
-		WithdrawalsRoot       string `json:"withdrawalsRoot"`
-		BlobGasUsed           string `json:"blobGasUsed"`
-		ExcessBlobGas         string `json:"excessBlobGas"`
-		ParentBeaconBlockRoot string `json:"parentBeaconBlockRoot"`
-		TotalDifficulty       string `json:"totalDifficulty"`
-		Size                  string `json:"size"`
-		Uncles                []any  `json:"uncles"`
-		Transactions          []struct {
-			Type                 string   `json:"type"`
-			ChainID              string   `json:"chainId"`
-			Nonce                string   `json:"nonce"`
-			Gas                  string   `json:"gas"`
-			MaxFeePerGas         string   `json:"maxFeePerGas,omitempty"`
-			MaxPriorityFeePerGas string   `json:"maxPriorityFeePerGas,omitempty"`
-			To                   string   `json:"to"`
-			Value                string   `json:"value"`
-			AccessList           []any    `json:"accessList,omitempty"`
-			Input                string   `json:"input"`
-			R                    string   `json:"r"`
-			S                    string   `json:"s"`
-			YParity              string   `json:"yParity,omitempty"`
-			V                    string   `json:"v"`
-			Hash                 string   `json:"hash"`
-			BlockHash            string   `json:"blockHash"`
-			BlockNumber          string   `json:"blockNumber"`
-			TransactionIndex     string   `json:"transactionIndex"`
-			From                 string   `json:"from"`
-			GasPrice             string   `json:"gasPrice"`
-			BlobVersionedHashes  []string `json:"blobVersionedHashes,omitempty"`
-			MaxFeePerBlobGas     string   `json:"maxFeePerBlobGas,omitempty"`
-		} `json:"transactions"`
-		Withdrawals []struct {
-			Index          string `json:"index"`
-			ValidatorIndex string `json:"validatorIndex"`
-			Address        string `json:"address"`
-			Amount         string `json:"amount"`
-		} `json:"withdrawals"`
-	} `json:"result"`
+	Jsonrpc string         `json:"jsonrpc"`
+	ID      int            `json:"id"`
+	Result  EthBlockResult `json:"result"`
+}
+
+// EthBlockResult is the block returned by eth_getBlockByNumber with full transactions
+type EthBlockResult struct {
+	Hash                  string           `json:"hash"`
+	ParentHash            string           `json:"parentHash"`
+	Sha3Uncles            string           `json:"sha3Uncles"`
+	Miner                 string           `json:"miner"`
+	StateRoot             string           `json:"stateRoot"`
+	TransactionsRoot      string           `json:"transactionsRoot"`
+	ReceiptsRoot          string           `json:"receiptsRoot"`
+	LogsBloom             string           `json:"logsBloom"`
+	Difficulty            string           `json:"difficulty"`
+	Number                string           `json:"number"`
+	GasLimit              string           `json:"gasLimit"`
+	GasUsed               string           `json:"gasUsed"`
+	Timestamp             string           `json:"timestamp"`
+	ExtraData             string           `json:"extraData"`
+	MixHash               string           `json:"mixHash"`
+	Nonce                 string           `json:"nonce"`
+	BaseFeePerGas         string           `json:"baseFeePerGas"`
+	WithdrawalsRoot       string           `json:"withdrawalsRoot"`
+	BlobGasUsed           string           `json:"blobGasUsed"`
+	ExcessBlobGas         string           `json:"excessBlobGas"`
+	ParentBeaconBlockRoot string           `json:"parentBeaconBlockRoot"`
+	TotalDifficulty       string           `json:"totalDifficulty"`
+	Size                  string           `json:"size"`
+	Uncles                []any            `json:"uncles"`
+	Transactions          []EthTransaction `json:"transactions"`
+	Withdrawals           []EthWithdrawal  `json:"withdrawals"`
+}
+
+// EthWithdrawal is a withdrawal as returned within a block, its amount is in gwei
+type EthWithdrawal struct {
+	Index          string `json:"index"`
+	ValidatorIndex string `json:"validatorIndex"`
+	Address        string `json:"address"`
+	Amount         string `json:"amount"`
+}
+
+// EthTransaction is a transaction as returned within a block by eth_getBlockByNumber
+type EthTransaction struct {
+	Type                 string   `json:"type"`
+	ChainID              string   `json:"chainId"`
+	Nonce                string   `json:"nonce"`
+	Gas                  string   `json:"gas"`
+	MaxFeePerGas         string   `json:"maxFeePerGas,omitempty"`
+	MaxPriorityFeePerGas string   `json:"maxPriorityFeePerGas,omitempty"`
+	To                   string   `json:"to"`
+	Value                string   `json:"value"`
+	AccessList           []any    `json:"accessList,omitempty"`
+	Input                string   `json:"input"`
+	R                    string   `json:"r"`
+	S                    string   `json:"s"`
+	YParity              string   `json:"yParity,omitempty"`
+	V                    string   `json:"v"`
+	Hash                 string   `json:"hash"`
+	BlockHash            string   `json:"blockHash"`
+	BlockNumber          string   `json:"blockNumber"`
+	TransactionIndex     string   `json:"transactionIndex"`
+	From                 string   `json:"from"`
+	GasPrice             string   `json:"gasPrice"`
+	BlobVersionedHashes  []string `json:"blobVersionedHashes,omitempty"`
+	MaxFeePerBlobGas     string   `json:"maxFeePerBlobGas,omitempty"`
 }
 
 // GetLatestBlockNumber fetches the latest block number
 func (c *publicNodeClient) GetLatestBlockNumber(ctx context.Context) (int64, error) {
-	reqBody := strings.NewReader(`{
-		"jsonrpc": "2.0",
-		"method": "eth_blockNumber",
-		"params": [],
-		"id": 1
-	}`)
-
-	req, err := http.NewRequestWithContext(ctx, "POST", c.publicNodeURL, reqBody)
+	var result string
+
+	if err := c.call(ctx, "eth_blockNumber", []any{}, &result); err != nil {
+		return 0, err
+	}
+
+	// Parse the hexadecimal value to int64
+	blockNum, err := HexToInt64(result)
 	if err != nil {
-		return 0, fmt.Errorf("failed to create request: %w", err)
+		return 0, fmt.Errorf("failed to parse block number: %w", err)
+	}
+
+	return blockNum, nil
+}
+
+// GetBlockByNumber fetches the block with the given number
+func (c *publicNodeClient) GetBlockByNumber(ctx context.Context, number int64) (*api.Block, error) {
+	var result *EthBlockResult
+
+	if err := c.call(ctx, "eth_getBlockByNumber", []any{fmt.Sprintf("0x%x", number), true}, &result); err != nil {
+		return nil, err
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	// nodes answer null for blocks they do not have yet
+	if result == nil {
+		return nil, fmt.Errorf("%w: block %d", ErrBlockNotAvailable, number)
+	}
+
+	return convertBlock(result)
+}
+
+// call sends a single JSON-RPC request and unmarshals its result,
+// failed requests and error objects are returned as an *RPCError
+func (c *publicNodeClient) call(ctx context.Context, method string, params []any, result any) error {
+	reqBody, err := json.Marshal(rpcRequest{JSONRPC: "2.0", Method: method, Params: params, ID: 1})
 	if err != nil {
-		return 0, fmt.Errorf("failed to make request: %w", err)
+		return fmt.Errorf("failed to marshal request: %w", err)
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	body, err := c.post(ctx, reqBody)
 	if err != nil {
-		return 0, fmt.Errorf("failed to read response body: %w", err)
+		return err
 	}
 
-	var result struct {
-		JSONRPC string `json:"jsonrpc"`
-		ID      int    `json:"id"`
-		Result  string `json:"result"`
+	var response rpcMessage
+
+	if err := json.Unmarshal(body, &response); err != nil {
+		return fmt.Errorf("failed to unmarshal response: %w", err)
 	}
 
-	if err := json.Unmarshal(body, &result); err != nil {
-		return 0, fmt.Errorf("failed to unmarshal response: %w", err)
+	if response.Error != nil {
+		return newRPCError(response.Error, nil)
 	}
 
-	// Parse the hexadecimal value to int64
-	blockNum, err := HexToInt64(result.Result)
-	if err != nil {
-		return 0, fmt.Errorf("failed to parse block number: %w", err)
+	if len(response.Result) == 0 {
+		return fmt.Errorf("empty response to %s", method)
 	}
 
-	return blockNum, nil
-}
+	if err := json.Unmarshal(response.Result, result); err != nil {
+		return fmt.Errorf("failed to unmarshal result: %w", err)
+	}
 
-// GetBlockByNumber fetches the block with the given number
-func (c *publicNodeClient) GetBlockByNumber(ctx context.Context, number int64) (*api.Block, error) {
-	reqBody := fmt.Sprintf(`{"jsonrpc":"2.0","method":"eth_getBlockByNumber","params":["0x%x",true],"id":1}`, number)
+	return nil
+}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", c.publicNodeURL, strings.NewReader(reqBody))
+// post sends a request body to the node and returns the response body
+func (c *publicNodeClient) post(ctx context.Context, reqBody []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", c.publicNodeURL, bytes.NewReader(reqBody))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to make request: %w", err)
 	}
@@ -147,15 +211,34 @@ func (c *publicNodeClient) GetBlockByNumber(ctx context.Context, number int64) (
 		return nil, fmt.Errorf("failed to read response body: %w", err)
 	}
 
-	var result EthBlock
+	if err := checkResponse(resp); err != nil {
+		// some nodes explain the failure with an error object
+		var response rpcMessage
+		if json.Unmarshal(body, &response) == nil && response.Error != nil {
+			return nil, newRPCError(response.Error, resp)
+		}
+
+		return nil, err
+	}
+
+	return body, nil
+}
 
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+// convertBlock converts the node's representation of a block to an api.Block
+func convertBlock(result *EthBlockResult) (*api.Block, error) {
+	timeStamp, err := HexToTime(result.Timestamp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse timestamp: %w", err)
 	}
 
-	txs := make([]api.Transaction, len(result.Result.Transactions))
-	for i, t := range result.Result.Transactions {
-		value, err := HexToInt64(t.Value)
+	blockNumber, err := HexToInt64(result.Number)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse block number: %w", err)
+	}
+
+	txs := make([]api.Transaction, len(result.Transactions))
+	for i, t := range result.Transactions {
+		value, err := HexToBigInt(t.Value)
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse value: %w", err)
 		}
@@ -165,34 +248,157 @@ func (c *publicNodeClient) GetBlockByNumber(ctx context.Context, number int64) (
 			return nil, fmt.Errorf("failed to parse transaction index: %w", err)
 		}
 
-		txs[i] = api.Transaction{
+		tx := api.Transaction{
 			Hash:             t.Hash,
+			Kind:             api.KindExternal,
 			From:             t.From,
 			To:               t.To,
 			Input:            t.Input,
 			Nonce:            t.Nonce,
 			Value:            value,
 			BlockHash:        t.BlockHash,
+			BlockNumber:      blockNumber,
+			Timestamp:        timeStamp,
 			TransactionIndex: transactionIndex,
+			ContractCreation: t.To == "",
 		}
-	}
 
-	timeStamp, err := HexToTime(result.Result.Timestamp)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse timestamp: %w", err)
+		if err := convertFees(&tx, &t); err != nil {
+			return nil, fmt.Errorf("transaction %s: %w", t.Hash, err)
+		}
+
+		txs[i] = tx
 	}
 
-	blockNumber, err := HexToInt64(result.Result.Number)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse block number: %w", err)
+	withdrawals := make([]api.Withdrawal, len(result.Withdrawals))
+	for i, w := range result.Withdrawals {
+		withdrawal, err := convertWithdrawal(w)
+		if err != nil {
+			return nil, fmt.Errorf("withdrawal %s: %w", w.Index, err)
+		}
+
+		withdrawal.BlockHash = result.Hash
+		withdrawal.BlockNumber = blockNumber
+		withdrawal.Timestamp = timeStamp
+
+		withdrawals[i] = withdrawal
 	}
 
 	return &api.Block{
 		Number:       blockNumber,
-		Hash:         result.Result.Hash,
-		ParentHash:   result.Result.ParentHash,
-		Nonce:        result.Result.Nonce,
+		Hash:         result.Hash,
+		ParentHash:   result.ParentHash,
+		Nonce:        result.Nonce,
 		Timestamp:    timeStamp,
 		Transactions: txs,
+		Withdrawals:  withdrawals,
+	}, nil
+}
+
+// convertWithdrawal converts the node's representation of a withdrawal to an api.Withdrawal
+func convertWithdrawal(w EthWithdrawal) (api.Withdrawal, error) {
+	index, err := HexToInt[uint64](w.Index)
+	if err != nil {
+		return api.Withdrawal{}, fmt.Errorf("failed to parse index: %w", err)
+	}
+
+	validatorIndex, err := HexToInt[uint64](w.ValidatorIndex)
+	if err != nil {
+		return api.Withdrawal{}, fmt.Errorf("failed to parse validator index: %w", err)
+	}
+
+	amount, err := HexToBigInt(w.Amount)
+	if err != nil {
+		return api.Withdrawal{}, fmt.Errorf("failed to parse amount: %w", err)
+	}
+
+	return api.Withdrawal{
+		Index:          index,
+		ValidatorIndex: validatorIndex,
+		Address:        w.Address,
+		Amount:         amount,
 	}, nil
 }
+
+// GetBlockNumberByTag fetches the number of the block with the given tag, such as "safe" or "finalized"
+func (c *publicNodeClient) GetBlockNumberByTag(ctx context.Context, tag string) (int64, error) {
+	var result *struct {
+		Number string `json:"number"`
+	}
+
+	if err := c.call(ctx, "eth_getBlockByNumber", []any{tag, false}, &result); err != nil {
+		return 0, err
+	}
+
+	if result == nil || result.Number == "" {
+		return 0, fmt.Errorf("%w: block tag %q", ErrBlockNotAvailable, tag)
+	}
+
+	blockNum, err := HexToInt64(result.Number)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse block number: %w", err)
+	}
+
+	return blockNum, nil
+}
+
+// convertFees parses the type, chain id, gas and fee fields of a transaction,
+// the ones that do not apply to its type are left empty
+func convertFees(tx *api.Transaction, t *EthTransaction) error {
+	var err error
+
+	if t.Type != "" {
+		parsed, err := HexToInt[uint64](t.Type)
+		if err != nil {
+			return fmt.Errorf("failed to parse type: %w", err)
+		}
+
+		tx.Type = api.TransactionType(parsed)
+	}
+
+	if t.ChainID != "" {
+		if tx.ChainID, err = HexToInt64(t.ChainID); err != nil {
+			return fmt.Errorf("failed to parse chain id: %w", err)
+		}
+	}
+
+	if t.Gas != "" {
+		if tx.Gas, err = HexToInt[uint64](t.Gas); err != nil {
+			return fmt.Errorf("failed to parse gas: %w", err)
+		}
+	}
+
+	if t.GasPrice != "" {
+		if tx.GasPrice, err = HexToBigInt(t.GasPrice); err != nil {
+			return fmt.Errorf("failed to parse gas price: %w", err)
+		}
+	}
+
+	if tx.MaxFeePerGas, err = optionalHexToBigInt(t.MaxFeePerGas); err != nil {
+		return fmt.Errorf("failed to parse max fee per gas: %w", err)
+	}
+
+	if tx.MaxPriorityFeePerGas, err = optionalHexToBigInt(t.MaxPriorityFeePerGas); err != nil {
+		return fmt.Errorf("failed to parse max priority fee per gas: %w", err)
+	}
+
+	if tx.MaxFeePerBlobGas, err = optionalHexToBigInt(t.MaxFeePerBlobGas); err != nil {
+		return fmt.Errorf("failed to parse max fee per blob gas: %w", err)
+	}
+
+	return nil
+}
+
+// optionalHexToBigInt parses a hex string that may be missing, in which case it returns nil
+func optionalHexToBigInt(hexStr string) (*api.BigInt, error) {
+	if hexStr == "" {
+		return nil, nil
+	}
+
+	value, err := HexToBigInt(hexStr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &value, nil
+}
@@ -3,9 +3,12 @@ package blockchain_test
 import (
 	"context"
 	"log"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"testing"
 
+	"github.com/devshark/tx-parser-go/api"
 	"github.com/devshark/tx-parser-go/app/internal/blockchain"
 )
 
@@ -45,9 +48,10 @@ func TestNewPublicNodeClient(t *testing.T) {
 	}
 }
 
+// TestPublicNodeClientMethods runs against the synthetic responses in testdata/synthetic/publicnode,
+// which stand in for mainnet block 21291150 until they are recorded against a real node with -record
 func TestPublicNodeClientMethods(t *testing.T) {
-	url := "https://ethereum-rpc.publicnode.com/"
-	client := blockchain.NewPublicNodeClient(url, log.Default())
+	client := fixtureClient(t, "https://ethereum-rpc.publicnode.com/", "testdata/synthetic/publicnode")
 
 	// Test GetLatestBlockNumber method
 	t.Run("GetLatestBlockNumber", func(t *testing.T) {
@@ -76,6 +80,98 @@ func TestPublicNodeClientMethods(t *testing.T) {
 	})
 }
 
+func TestPublicNodeClientTransactionFields(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{
+			"number":"0x144e08e","hash":"0xb1","parentHash":"0xb0","timestamp":"0x6746c0a3",
+			"transactions":[
+				{"type":"0x0","hash":"0x1","from":"0xa","to":"0xb","value":"0x0","transactionIndex":"0x0","gas":"0x5208","gasPrice":"0x3b9aca00"},
+				{"type":"0x2","chainId":"0x1","hash":"0x2","from":"0xa","to":"0xb","value":"0x0","transactionIndex":"0x1","gas":"0x5208","gasPrice":"0x4a817c800",
+					"maxFeePerGas":"0x6fc23ac00","maxPriorityFeePerGas":"0x3b9aca00"},
+				{"type":"0x3","chainId":"0x1","hash":"0x3","from":"0xa","to":"0xb","value":"0x0","transactionIndex":"0x2","gas":"0x5208","gasPrice":"0x4a817c800",
+					"maxFeePerGas":"0x6fc23ac00","maxPriorityFeePerGas":"0x3b9aca00","maxFeePerBlobGas":"0x1"},
+				{"type":"0x2","chainId":"0x1","hash":"0x4","from":"0xa","to":null,"value":"0x0","transactionIndex":"0x3","gas":"0x5208","gasPrice":"0x4a817c800",
+					"maxFeePerGas":"0x6fc23ac00","maxPriorityFeePerGas":"0x3b9aca00"}
+			]}}`))
+	}))
+	defer server.Close()
+
+	client := blockchain.NewPublicNodeClient(server.URL, log.Default())
+
+	block, err := client.GetBlockByNumber(context.Background(), 21291150)
+	if err != nil {
+		t.Fatalf("GetBlockByNumber returned an error: %v", err)
+	}
+
+	if len(block.Transactions) != 4 {
+		t.Fatalf("Expected 4 transactions, got %d", len(block.Transactions))
+	}
+
+	legacy, dynamicFee, blob, deployment := block.Transactions[0], block.Transactions[1], block.Transactions[2], block.Transactions[3]
+
+	if legacy.Type != api.TxTypeLegacy || legacy.ChainID != 0 || legacy.MaxFeePerGas != nil || legacy.MaxPriorityFeePerGas != nil {
+		t.Errorf("Expected a legacy transaction without chain id nor dynamic fees, got %+v", legacy)
+	}
+
+	if legacy.Gas != 21000 || legacy.GasPrice.Gwei() != "1" {
+		t.Errorf("Expected 21000 gas at 1 gwei, got %d gas at %s gwei", legacy.Gas, legacy.GasPrice.Gwei())
+	}
+
+	if dynamicFee.Type != api.TxTypeDynamicFee || dynamicFee.ChainID != 1 {
+		t.Errorf("Expected a dynamic fee transaction on chain 1, got %s on chain %d", dynamicFee.Type, dynamicFee.ChainID)
+	}
+
+	if dynamicFee.MaxFeePerGas == nil || dynamicFee.MaxFeePerGas.Gwei() != "30" || dynamicFee.MaxPriorityFeePerGas.Gwei() != "1" || dynamicFee.GasPrice.Gwei() != "20" {
+		t.Errorf("Expected fees of 30 and 1 gwei at an effective 20 gwei, got %+v", dynamicFee)
+	}
+
+	if blob.Type != api.TxTypeBlob || blob.MaxFeePerBlobGas == nil || blob.MaxFeePerBlobGas.String() != "1" {
+		t.Errorf("Expected a blob transaction with a max fee per blob gas, got %+v", blob)
+	}
+
+	if legacy.ContractCreation || !deployment.ContractCreation || deployment.To != "" {
+		t.Errorf("Expected only the transaction without a recipient to be a contract creation, got %+v", deployment)
+	}
+
+	for _, tx := range block.Transactions {
+		if tx.BlockNumber != 21291150 || !tx.Timestamp.Equal(block.Timestamp) {
+			t.Errorf("Expected transaction %s to carry the block number and timestamp, got %d and %s", tx.Hash, tx.BlockNumber, tx.Timestamp)
+		}
+	}
+}
+
+func TestPublicNodeClientWithdrawals(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{
+			"number":"0x144e08e","hash":"0xb1","parentHash":"0xb0","timestamp":"0x6746c0a3","transactions":[],
+			"withdrawals":[
+				{"index":"0x4a8b2e1","validatorIndex":"0x10e4c1","address":"0xB9D7934878B5FB9610B3fE8A5e441e8fad7E293f","amount":"0x11b9f9c"}
+			]}}`))
+	}))
+	defer server.Close()
+
+	client := blockchain.NewPublicNodeClient(server.URL, log.Default())
+
+	block, err := client.GetBlockByNumber(context.Background(), 21291150)
+	if err != nil {
+		t.Fatalf("GetBlockByNumber returned an error: %v", err)
+	}
+
+	if len(block.Withdrawals) != 1 {
+		t.Fatalf("Expected 1 withdrawal, got %d", len(block.Withdrawals))
+	}
+
+	withdrawal := block.Withdrawals[0]
+
+	if withdrawal.Index != 78164705 || withdrawal.ValidatorIndex != 1107137 || withdrawal.Amount.String() != "18587548" {
+		t.Errorf("Expected withdrawal 78164705 of 18587548 gwei by validator 1107137, got %+v", withdrawal)
+	}
+
+	if withdrawal.BlockHash != "0xb1" || withdrawal.BlockNumber != 21291150 || !withdrawal.Timestamp.Equal(block.Timestamp) {
+		t.Errorf("Expected the withdrawal to carry the block hash, number and timestamp, got %+v", withdrawal)
+	}
+}
+
 func TestPublicNodeClientErrorCases(t *testing.T) {
 	// Use an invalid URL to simulate network errors
 	invalidURL := "https://invalid-url.example.com/"
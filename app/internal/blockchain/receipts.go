@@ -0,0 +1,143 @@
+package blockchain
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/devshark/tx-parser-go/api"
+)
+
+// ReceiptClient is implemented by clients that can fetch transaction receipts
+type ReceiptClient interface {
+	// GetBlockReceipts fetches the receipts of every transaction in a block, in the order of the transactions
+	GetBlockReceipts(ctx context.Context, number int64) ([]*api.Receipt, error)
+	GetTransactionReceipt(ctx context.Context, hash string) (*api.Receipt, error)
+}
+
+// EthReceipt is a receipt as returned by eth_getTransactionReceipt and eth_getBlockReceipts
+type EthReceipt struct {
+	TransactionHash   string   `json:"transactionHash"`
+	TransactionIndex  string   `json:"transactionIndex"`
+	BlockHash         string   `json:"blockHash"`
+	BlockNumber       string   `json:"blockNumber"`
+	From              string   `json:"from"`
+	To                string   `json:"to"`
+	CumulativeGasUsed string   `json:"cumulativeGasUsed"`
+	GasUsed           string   `json:"gasUsed"`
+	EffectiveGasPrice string   `json:"effectiveGasPrice"`
+	ContractAddress   string   `json:"contractAddress"`
+	Status            string   `json:"status"`
+	Type              string   `json:"type"`
+	Logs              []EthLog `json:"logs"`
+}
+
+// rpcCaller sends a single JSON-RPC request, it is implemented by the http and websocket clients
+type rpcCaller interface {
+	call(ctx context.Context, method string, params []any, result any) error
+}
+
+func (c *publicNodeClient) GetBlockReceipts(ctx context.Context, number int64) ([]*api.Receipt, error) {
+	return getBlockReceipts(ctx, c, number)
+}
+
+func (c *publicNodeClient) GetTransactionReceipt(ctx context.Context, hash string) (*api.Receipt, error) {
+	return getTransactionReceipt(ctx, c, hash)
+}
+
+func (c *webSocketClient) GetBlockReceipts(ctx context.Context, number int64) ([]*api.Receipt, error) {
+	return getBlockReceipts(ctx, c, number)
+}
+
+func (c *webSocketClient) GetTransactionReceipt(ctx context.Context, hash string) (*api.Receipt, error) {
+	return getTransactionReceipt(ctx, c, hash)
+}
+
+func getBlockReceipts(ctx context.Context, c rpcCaller, number int64) ([]*api.Receipt, error) {
+	var result []*EthReceipt
+
+	if err := c.call(ctx, "eth_getBlockReceipts", []any{fmt.Sprintf("0x%x", number)}, &result); err != nil {
+		return nil, err
+	}
+
+	if result == nil {
+		return nil, fmt.Errorf("%w: receipts of block %d", ErrBlockNotAvailable, number)
+	}
+
+	receipts := make([]*api.Receipt, len(result))
+	for i, r := range result {
+		receipt, err := convertReceipt(r)
+		if err != nil {
+			return nil, err
+		}
+
+		receipts[i] = receipt
+	}
+
+	return receipts, nil
+}
+
+func getTransactionReceipt(ctx context.Context, c rpcCaller, hash string) (*api.Receipt, error) {
+	var result *EthReceipt
+
+	if err := c.call(ctx, "eth_getTransactionReceipt", []any{hash}, &result); err != nil {
+		return nil, err
+	}
+
+	// nodes answer null until the transaction is included
+	if result == nil {
+		return nil, fmt.Errorf("%w: receipt of transaction %s", ErrBlockNotAvailable, hash)
+	}
+
+	return convertReceipt(result)
+}
+
+// convertReceipt converts the node's representation of a receipt to an api.Receipt
+func convertReceipt(result *EthReceipt) (*api.Receipt, error) {
+	if result == nil {
+		return nil, fmt.Errorf("missing receipt")
+	}
+
+	gasUsed, err := HexToInt[uint64](result.GasUsed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse gas used of %s: %w", result.TransactionHash, err)
+	}
+
+	var effectiveGasPrice api.BigInt
+	if result.EffectiveGasPrice != "" {
+		if effectiveGasPrice, err = HexToBigInt(result.EffectiveGasPrice); err != nil {
+			return nil, fmt.Errorf("failed to parse effective gas price of %s: %w", result.TransactionHash, err)
+		}
+	}
+
+	receipt := &api.Receipt{
+		TransactionHash:   result.TransactionHash,
+		GasUsed:           gasUsed,
+		EffectiveGasPrice: effectiveGasPrice,
+		Fee:               api.BigIntFromBig(new(big.Int).Mul(new(big.Int).SetUint64(gasUsed), effectiveGasPrice.Big())),
+		ContractAddress:   result.ContractAddress,
+		Logs:              make([]api.Log, 0, len(result.Logs)),
+	}
+
+	switch result.Status {
+	case "0x1":
+		receipt.Status = api.ReceiptSuccess
+	case "0x0":
+		receipt.Status = api.ReceiptFailed
+	}
+
+	for _, l := range result.Logs {
+		if l.Removed {
+			continue
+		}
+
+		converted, err := convertLog(&l)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse log of %s: %w", result.TransactionHash, err)
+		}
+
+		receipt.Logs = append(receipt.Logs, converted)
+	}
+
+	return receipt, nil
+}
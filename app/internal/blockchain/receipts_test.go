@@ -0,0 +1,101 @@
+package blockchain_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/devshark/tx-parser-go/api"
+	"github.com/devshark/tx-parser-go/app/internal/blockchain"
+)
+
+const receiptsJSON = `[
+	{"transactionHash":"0x1","gasUsed":"0x5208","effectiveGasPrice":"0x4a817c800","status":"0x1","logs":[]},
+	{"transactionHash":"0x2","gasUsed":"0x7530","effectiveGasPrice":"0x4a817c800","status":"0x0","logs":[]},
+	{"transactionHash":"0x3","gasUsed":"0x186a0","effectiveGasPrice":"0x4a817c800","status":"0x1","contractAddress":"0xc0ffee",
+		"logs":[{"address":"0xc0ffee","topics":["0xddf252ad"],"data":"0x","logIndex":"0x4"},{"address":"0xc0ffee","topics":[],"data":"0x","logIndex":"0x5","removed":true}]}
+]`
+
+// newReceiptServer answers eth_getBlockReceipts for block 1 and eth_getTransactionReceipt,
+// and rejects eth_getBlockReceipts for any other block as unsupported
+func newReceiptServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Method string `json:"method"`
+			Params []any  `json:"params"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("Expected a request: %v", err)
+			return
+		}
+
+		switch {
+		case request.Method == "eth_getBlockReceipts" && request.Params[0] == "0x1":
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":1,"result":%s}`, receiptsJSON)
+		case request.Method == "eth_getTransactionReceipt" && request.Params[0] == "0x2":
+			fmt.Fprint(w, `{"jsonrpc":"2.0","id":1,"result":{"transactionHash":"0x2","gasUsed":"0x7530","effectiveGasPrice":"0x4a817c800","status":"0x0","logs":[]}}`)
+		case request.Method == "eth_getTransactionReceipt":
+			fmt.Fprint(w, `{"jsonrpc":"2.0","id":1,"result":null}`)
+		default:
+			fmt.Fprint(w, `{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"the method eth_getBlockReceipts does not exist/is not available"}}`)
+		}
+	}))
+}
+
+func TestPublicNodeClientReceipts(t *testing.T) {
+	server := newReceiptServer(t)
+	defer server.Close()
+
+	client, ok := blockchain.NewPublicNodeClient(server.URL, log.Default()).(blockchain.ReceiptClient)
+	if !ok {
+		t.Fatal("Expected publicNodeClient to implement ReceiptClient")
+	}
+
+	ctx := context.Background()
+
+	receipts, err := client.GetBlockReceipts(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetBlockReceipts returned an error: %v", err)
+	}
+
+	if len(receipts) != 3 {
+		t.Fatalf("Expected 3 receipts, got %d", len(receipts))
+	}
+
+	if !receipts[0].Succeeded() || receipts[1].Status != api.ReceiptFailed {
+		t.Errorf("Expected the first receipt to succeed and the second to fail, got %s and %s", receipts[0].Status, receipts[1].Status)
+	}
+
+	// 21000 gas at 20 gwei
+	if receipts[0].GasUsed != 21000 || receipts[0].Fee.Ether() != "0.00042" {
+		t.Errorf("Expected a fee of 0.00042 ether for 21000 gas, got %s ether for %d gas", receipts[0].Fee.Ether(), receipts[0].GasUsed)
+	}
+
+	// removed logs are left out
+	if receipts[2].ContractAddress != "0xc0ffee" || len(receipts[2].Logs) != 1 || receipts[2].Logs[0].LogIndex != 4 {
+		t.Errorf("Expected a contract creation with 1 log, got %+v", receipts[2])
+	}
+
+	if _, err := client.GetBlockReceipts(ctx, 2); !errors.Is(err, blockchain.ErrMethodNotFound) {
+		t.Errorf("Expected ErrMethodNotFound, got %v", err)
+	}
+
+	receipt, err := client.GetTransactionReceipt(ctx, "0x2")
+	if err != nil {
+		t.Fatalf("GetTransactionReceipt returned an error: %v", err)
+	}
+
+	if receipt.Status != api.ReceiptFailed || receipt.GasUsed != 30000 {
+		t.Errorf("Expected a failed receipt using 30000 gas, got %+v", receipt)
+	}
+
+	if _, err := client.GetTransactionReceipt(ctx, "0x9"); !errors.Is(err, blockchain.ErrBlockNotAvailable) {
+		t.Errorf("Expected ErrBlockNotAvailable for an unknown transaction, got %v", err)
+	}
+}
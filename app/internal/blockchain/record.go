@@ -0,0 +1,190 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ErrFixtureNotFound is returned by a replay client for requests that were not recorded
+var ErrFixtureNotFound = errors.New("no recorded response")
+
+// replayURL is the url of the node behind a replay client, which is never reached
+const replayURL = "http://replay.invalid/"
+
+// fixture is a JSON-RPC request and the response of the node to it, as recorded to a file
+type fixture struct {
+	Request    json.RawMessage `json:"request"`
+	StatusCode int             `json:"statusCode"`
+	Response   json.RawMessage `json:"response"`
+}
+
+// NewRecordingClient returns a client of the node at publicNodeURL that records every JSON-RPC
+// request it sends, batches included, along with the response to a fixture file in dir.
+// A request sent again overwrites the response recorded for it.
+func NewRecordingClient(publicNodeURL, dir string, logger *log.Logger, opts ...PublicNodeOption) BlockchainClient {
+	transport := NewRecordingTransport(dir, http.DefaultTransport)
+
+	return NewPublicNodeClient(publicNodeURL, logger, append(opts, WithHTTPClient(&http.Client{Transport: transport}))...)
+}
+
+// NewReplayClient returns a client that answers the requests recorded in dir by a recording client,
+// without network access. The requests must be the same, which requires the same batch size.
+func NewReplayClient(dir string, logger *log.Logger, opts ...PublicNodeOption) BlockchainClient {
+	transport := NewReplayTransport(dir)
+
+	return NewPublicNodeClient(replayURL, logger, append(opts, WithHTTPClient(&http.Client{Transport: transport}))...)
+}
+
+// NewRecordingTransport returns a transport sending the requests through next and recording them
+// along with their response to dir. Giving it to the public node clients of a FailoverClient's
+// endpoints with WithHTTPClient records the requests of every endpoint.
+func NewRecordingTransport(dir string, next http.RoundTripper) http.RoundTripper {
+	return &recordingTransport{dir: dir, next: next}
+}
+
+// NewReplayTransport returns a transport answering the requests with the responses recorded in dir,
+// so that they go through the decoding and error handling of the client as the live ones would
+func NewReplayTransport(dir string) http.RoundTripper {
+	return &replayTransport{dir: dir}
+}
+
+// recordingTransport sends the requests to the node and writes them to dir along with their response
+type recordingTransport struct {
+	dir  string
+	next http.RoundTripper
+	mu   sync.Mutex // serializes writing fixtures
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	// the fixture can only hold a response that is valid json
+	if json.Valid(respBody) {
+		if err := t.write(reqBody, fixture{Request: reqBody, StatusCode: resp.StatusCode, Response: respBody}); err != nil {
+			return nil, err
+		}
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	return resp, nil
+}
+
+func (t *recordingTransport) write(reqBody []byte, fixture fixture) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	data, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal fixture: %w", err)
+	}
+
+	if err := os.MkdirAll(t.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create fixture directory: %w", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(t.dir, fixtureName(reqBody)), append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write fixture: %w", err)
+	}
+
+	return nil
+}
+
+// replayTransport answers the requests with the responses recorded in dir
+type replayTransport struct {
+	dir string
+}
+
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(t.dir, fixtureName(reqBody)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w for %s", ErrFixtureNotFound, reqBody)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture: %w", err)
+	}
+
+	var fixture fixture
+
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal fixture: %w", err)
+	}
+
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", fixture.StatusCode, http.StatusText(fixture.StatusCode)),
+		StatusCode: fixture.StatusCode,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(fixture.Response)),
+		Request:    req,
+	}, nil
+}
+
+// readBody reads the body of the request and restores it for the next reader
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+
+	req.Body.Close()
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	return body, nil
+}
+
+// fixtureName names the fixture of a request after its method and a hash of its body,
+// the requests are marshalled deterministically so the same request gets the same name
+func fixtureName(reqBody []byte) string {
+	method := "unknown"
+
+	var single rpcRequest
+	var batch []rpcRequest
+
+	if json.Unmarshal(reqBody, &single) == nil && single.Method != "" {
+		method = single.Method
+	} else if json.Unmarshal(reqBody, &batch) == nil && len(batch) > 0 {
+		method = "batch_" + batch[0].Method
+	}
+
+	hash := sha256.Sum256(reqBody)
+
+	return fmt.Sprintf("%s-%s.json", strings.ReplaceAll(method, "/", "_"), hex.EncodeToString(hash[:8]))
+}
@@ -0,0 +1,137 @@
+package blockchain_test
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/devshark/tx-parser-go/app/internal/blockchain"
+)
+
+var record = flag.Bool("record", false, "record the fixtures in testdata against the live node instead of replaying them")
+
+// fixtureClient replays the fixtures in dir, or records them against the node at nodeURL with -record
+func fixtureClient(t *testing.T, nodeURL, dir string) blockchain.BlockchainClient {
+	t.Helper()
+
+	if *record {
+		if url := os.Getenv("PUBLIC_NODE_URL"); url != "" {
+			nodeURL = url
+		}
+
+		return blockchain.NewRecordingClient(nodeURL, dir, log.Default())
+	}
+
+	return blockchain.NewReplayClient(dir, log.Default())
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	var requests atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), "eth_getBlockReceipts") {
+			w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"the method eth_getBlockReceipts does not exist"}}`))
+			return
+		}
+
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x144e08e"}`))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	recording := blockchain.NewRecordingClient(server.URL, dir, log.Default())
+
+	recorded, err := recording.GetLatestBlockNumber(ctx)
+	if err != nil {
+		t.Fatalf("GetLatestBlockNumber returned an error while recording: %v", err)
+	}
+
+	if _, err := recording.(blockchain.ReceiptClient).GetBlockReceipts(ctx, 21291150); !errors.Is(err, blockchain.ErrMethodNotFound) {
+		t.Fatalf("Expected ErrMethodNotFound while recording, got %v", err)
+	}
+
+	server.Close()
+
+	replay := blockchain.NewReplayClient(dir, log.Default())
+
+	replayed, err := replay.GetLatestBlockNumber(ctx)
+	if err != nil {
+		t.Fatalf("GetLatestBlockNumber returned an error while replaying: %v", err)
+	}
+
+	if recorded != 21291150 || replayed != recorded {
+		t.Errorf("Expected block 21291150 to be recorded and replayed, got %d and %d", recorded, replayed)
+	}
+
+	// the recorded error response goes through the error handling of the client again
+	_, err = replay.(blockchain.ReceiptClient).GetBlockReceipts(ctx, 21291150)
+
+	var rpcErr *blockchain.RPCError
+	if !errors.As(err, &rpcErr) || rpcErr.Code != -32601 || !errors.Is(err, blockchain.ErrMethodNotFound) || blockchain.IsRetryable(err) {
+		t.Errorf("Expected the method not found error to be replayed, got %v", err)
+	}
+
+	if requests.Load() != 2 {
+		t.Errorf("Expected the node to be reached twice, got %d requests", requests.Load())
+	}
+
+	// the block was not recorded
+	if _, err := replay.GetBlockByNumber(ctx, 21291150); !errors.Is(err, blockchain.ErrFixtureNotFound) {
+		t.Errorf("Expected ErrFixtureNotFound for a request that was not recorded, got %v", err)
+	}
+}
+
+func TestRecordAndReplay_Failover(t *testing.T) {
+	servers := make([]*httptest.Server, 2)
+	for i := range servers {
+		servers[i] = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x144e08e"}`))
+		}))
+		defer servers[i].Close()
+	}
+
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	// every endpoint of the failover client records through the same transport
+	failoverClient := func(transport http.RoundTripper) *blockchain.FailoverClient {
+		endpoints := make([]blockchain.Endpoint, len(servers))
+		for i, server := range servers {
+			client := blockchain.NewPublicNodeClient(server.URL, log.Default(), blockchain.WithHTTPClient(&http.Client{Transport: transport}))
+			endpoints[i] = blockchain.Endpoint{URL: server.URL, Client: client}
+		}
+
+		return blockchain.NewFailoverClient(endpoints, log.Default())
+	}
+
+	recorded, err := failoverClient(blockchain.NewRecordingTransport(dir, http.DefaultTransport)).GetLatestBlockNumber(ctx)
+	if err != nil {
+		t.Fatalf("GetLatestBlockNumber returned an error while recording: %v", err)
+	}
+
+	for _, server := range servers {
+		server.Close()
+	}
+
+	replayed, err := failoverClient(blockchain.NewReplayTransport(dir)).GetLatestBlockNumber(ctx)
+	if err != nil {
+		t.Fatalf("GetLatestBlockNumber returned an error while replaying: %v", err)
+	}
+
+	if recorded != 21291150 || replayed != recorded {
+		t.Errorf("Expected block 21291150 to be recorded and replayed, got %d and %d", recorded, replayed)
+	}
+}
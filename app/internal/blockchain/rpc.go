@@ -0,0 +1,177 @@
+package blockchain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kinds of RPCError, match them with errors.Is
+var (
+	ErrRateLimited       = errors.New("rate limited")
+	ErrMethodNotFound    = errors.New("method not found")
+	ErrBlockNotAvailable = errors.New("block not yet available")
+	ErrServerError       = errors.New("server error")
+)
+
+var (
+	// ErrInvalidResponse is a response that could not be decoded, asking again gets the same answer
+	ErrInvalidResponse = errors.New("invalid response")
+	// ErrRequestTimeout is a single request that took too long, unlike the deadline of the caller
+	ErrRequestTimeout = errors.New("request timed out")
+)
+
+// JSON-RPC error codes
+const (
+	codeMethodNotFound = -32601
+	codeInternalError  = -32603
+	codeServerError    = -32000 // used by most nodes for anything, classified by message
+	codeLimitExceeded  = -32005
+)
+
+type rpcRequest struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  []any  `json:"params"`
+	ID      int64  `json:"id"`
+}
+
+// rpcMessage is any message received from the node: a response or a subscription notification
+type rpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      *int64          `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// rpcError is the error object of a JSON-RPC response
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+// RPCError is a JSON-RPC error or an HTTP error status returned by the node
+type RPCError struct {
+	Code       int    // JSON-RPC error code, 0 if the node only answered with an HTTP status
+	Message    string // JSON-RPC error message or HTTP status text
+	HTTPStatus int
+	RetryAfter time.Duration // how long the node asked to wait, if rate limited
+	kind       error
+}
+
+func (e *RPCError) Error() string {
+	if e.Code == 0 {
+		return fmt.Sprintf("http error %d: %s", e.HTTPStatus, e.Message)
+	}
+
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+// Unwrap returns the kind of the error, such as ErrRateLimited
+func (e *RPCError) Unwrap() error {
+	return e.kind
+}
+
+// RetryDelay lets retry.Retry honor the Retry-After of a rate limit
+func (e *RPCError) RetryDelay() time.Duration {
+	return e.RetryAfter
+}
+
+// newRPCError classifies the error object of a response, if any, or the HTTP status of a failed request
+func newRPCError(rpcErr *rpcError, resp *http.Response) *RPCError {
+	err := &RPCError{}
+
+	if resp != nil {
+		err.HTTPStatus = resp.StatusCode
+		err.Message = http.StatusText(resp.StatusCode)
+		err.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+	}
+
+	if rpcErr != nil {
+		err.Code = rpcErr.Code
+		err.Message = rpcErr.Message
+	}
+
+	message := strings.ToLower(err.Message)
+
+	switch {
+	case err.HTTPStatus == http.StatusTooManyRequests || err.Code == codeLimitExceeded ||
+		strings.Contains(message, "rate limit") || strings.Contains(message, "too many requests"):
+		err.kind = ErrRateLimited
+	case err.Code == codeMethodNotFound || strings.Contains(message, "method not found") ||
+		strings.Contains(message, "not supported"):
+		err.kind = ErrMethodNotFound
+	case strings.Contains(message, "header not found") || strings.Contains(message, "unknown block") ||
+		strings.Contains(message, "block not found"):
+		err.kind = ErrBlockNotAvailable
+	case err.HTTPStatus >= http.StatusInternalServerError || err.Code == codeInternalError || err.Code == codeServerError:
+		err.kind = ErrServerError
+	}
+
+	return err
+}
+
+// checkResponse returns an RPCError if the request failed with an HTTP error status
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices {
+		return nil
+	}
+
+	return newRPCError(nil, resp)
+}
+
+// IsRetryable reports whether a failed call is worth retrying: rate limits, blocks the node
+// does not have yet, server and transport errors are, while the other JSON-RPC errors are not.
+// Neither are a cancelled or expired context, which only the caller can renew, nor a response
+// that could not be decoded.
+func IsRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || isDecodeError(err) {
+		return false
+	}
+
+	var rpcErr *RPCError
+	if !errors.As(err, &rpcErr) {
+		return true
+	}
+
+	return rpcErr.kind != nil && !errors.Is(rpcErr.kind, ErrMethodNotFound)
+}
+
+// isDecodeError reports whether the response of the node could not be decoded
+func isDecodeError(err error) bool {
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+		numErr    *strconv.NumError
+	)
+
+	return errors.Is(err, ErrInvalidResponse) || errors.As(err, &syntaxErr) || errors.As(err, &typeErr) || errors.As(err, &numErr)
+}
+
+// parseRetryAfter parses a Retry-After header, either in seconds or as an HTTP date
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0)
+	}
+
+	return 0
+}
@@ -0,0 +1,163 @@
+package blockchain_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/devshark/tx-parser-go/app/internal/blockchain"
+)
+
+func TestPublicNodeClientErrors(t *testing.T) {
+	cases := []struct {
+		name       string
+		status     int
+		header     map[string]string
+		body       string
+		kind       error
+		retryable  bool
+		retryAfter time.Duration
+	}{
+		{
+			name:       "rate limited with Retry-After",
+			status:     http.StatusTooManyRequests,
+			header:     map[string]string{"Retry-After": "3"},
+			kind:       blockchain.ErrRateLimited,
+			retryable:  true,
+			retryAfter: 3 * time.Second,
+		},
+		{
+			name:      "rate limited by an error object",
+			status:    http.StatusOK,
+			body:      `{"jsonrpc":"2.0","id":1,"error":{"code":-32005,"message":"daily request count exceeded, request rate limited"}}`,
+			kind:      blockchain.ErrRateLimited,
+			retryable: true,
+		},
+		{
+			name:   "method not found",
+			status: http.StatusOK,
+			body:   `{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"the method eth_getBlockByNumber does not exist/is not available"}}`,
+			kind:   blockchain.ErrMethodNotFound,
+		},
+		{
+			name:      "header not found",
+			status:    http.StatusOK,
+			body:      `{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"header not found"}}`,
+			kind:      blockchain.ErrBlockNotAvailable,
+			retryable: true,
+		},
+		{
+			name:      "block not produced yet",
+			status:    http.StatusOK,
+			body:      `{"jsonrpc":"2.0","id":1,"result":null}`,
+			kind:      blockchain.ErrBlockNotAvailable,
+			retryable: true,
+		},
+		{
+			name:      "server error",
+			status:    http.StatusServiceUnavailable,
+			body:      `upstream unavailable`,
+			kind:      blockchain.ErrServerError,
+			retryable: true,
+		},
+		{
+			name:   "malformed response",
+			status: http.StatusOK,
+			body:   `<html>not json</html>`,
+		},
+		{
+			name:   "malformed block",
+			status: http.StatusOK,
+			body:   `{"jsonrpc":"2.0","id":1,"result":{"number":"0xzz","hash":"0x1","timestamp":"0x6746c0a3","transactions":[]}}`,
+			kind:   blockchain.ErrInvalidResponse,
+		},
+		{
+			name:   "invalid params",
+			status: http.StatusOK,
+			body:   `{"jsonrpc":"2.0","id":1,"error":{"code":-32602,"message":"invalid argument 0: hex string without 0x prefix"}}`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for key, value := range c.header {
+					w.Header().Set(key, value)
+				}
+				w.WriteHeader(c.status)
+				w.Write([]byte(c.body))
+			}))
+			defer server.Close()
+
+			client := blockchain.NewPublicNodeClient(server.URL, log.Default())
+
+			block, err := client.GetBlockByNumber(context.Background(), 1)
+			if err == nil {
+				t.Fatalf("Expected an error, got block %+v", block)
+			}
+
+			if c.kind != nil && !errors.Is(err, c.kind) {
+				t.Errorf("Expected %v, got %v", c.kind, err)
+			}
+
+			if retryable := blockchain.IsRetryable(err); retryable != c.retryable {
+				t.Errorf("Expected retryable to be %v for %v", c.retryable, err)
+			}
+
+			var rpcErr *blockchain.RPCError
+			if errors.As(err, &rpcErr) && rpcErr.RetryAfter != c.retryAfter {
+				t.Errorf("Expected Retry-After of %s, got %s", c.retryAfter, rpcErr.RetryAfter)
+			}
+
+			// the latest block number surfaces the same errors
+			if _, err := client.GetLatestBlockNumber(context.Background()); err == nil {
+				t.Error("Expected an error for the latest block number, got nil")
+			}
+		})
+	}
+}
+
+func TestIsRetryable_Context(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer server.Close()
+
+	client := blockchain.NewPublicNodeClient(server.URL, log.Default())
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	expired, cancelExpired := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancelExpired()
+
+	for name, ctx := range map[string]context.Context{"cancelled": cancelled, "expired": expired} {
+		t.Run(name, func(t *testing.T) {
+			_, err := client.GetBlockByNumber(ctx, 1)
+			if err == nil {
+				t.Fatal("Expected an error, got nil")
+			}
+
+			if blockchain.IsRetryable(err) {
+				t.Errorf("Expected the %s context not to be retryable, got %v", name, err)
+			}
+		})
+	}
+
+	// a single request timing out is, unlike the deadline of the caller
+	if !blockchain.IsRetryable(fmt.Errorf("endpoint: %w", blockchain.ErrRequestTimeout)) {
+		t.Error("Expected a request timeout to be retryable")
+	}
+
+	if !blockchain.IsRetryable(errors.New("connection reset by peer")) {
+		t.Error("Expected a transport error to be retryable")
+	}
+}
@@ -0,0 +1,148 @@
+package blockchain
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/devshark/tx-parser-go/api"
+)
+
+// TraceClient is implemented by clients that can trace the calls made by the transactions of a block
+type TraceClient interface {
+	// TraceBlockByNumber returns the call tree of every transaction in a block, in the order of the transactions
+	TraceBlockByNumber(ctx context.Context, number int64) ([]TransactionTrace, error)
+}
+
+// TransactionTrace is the call tree of a transaction as returned by debug_traceBlockByNumber
+type TransactionTrace struct {
+	TxHash string    `json:"txHash"` // not reported by older nodes
+	Result CallFrame `json:"result"`
+	Error  string    `json:"error"`
+}
+
+// CallFrame is a call made during the execution of a transaction, as reported by the callTracer.
+// The frame of the transaction itself is the root of the tree.
+type CallFrame struct {
+	Type  string      `json:"type"`
+	From  string      `json:"from"`
+	To    string      `json:"to"`
+	Value string      `json:"value"`
+	Error string      `json:"error"`
+	Calls []CallFrame `json:"calls"`
+}
+
+func (c *publicNodeClient) TraceBlockByNumber(ctx context.Context, number int64) ([]TransactionTrace, error) {
+	return traceBlockByNumber(ctx, c, number)
+}
+
+func (c *webSocketClient) TraceBlockByNumber(ctx context.Context, number int64) ([]TransactionTrace, error) {
+	return traceBlockByNumber(ctx, c, number)
+}
+
+func traceBlockByNumber(ctx context.Context, c rpcCaller, number int64) ([]TransactionTrace, error) {
+	var result []TransactionTrace
+
+	tracer := map[string]any{"tracer": "callTracer"}
+
+	if err := c.call(ctx, "debug_traceBlockByNumber", []any{fmt.Sprintf("0x%x", number), tracer}, &result); err != nil {
+		return nil, err
+	}
+
+	if result == nil {
+		return nil, fmt.Errorf("%w: traces of block %d", ErrBlockNotAvailable, number)
+	}
+
+	return result, nil
+}
+
+// InternalTransactions walks the call trees of the transactions of a block and returns
+// the calls that transferred value, as internal transactions. Calls that reverted,
+// along with the calls they made, did not transfer anything and are left out.
+func InternalTransactions(block *api.Block, traces []TransactionTrace) ([]api.Transaction, error) {
+	if len(traces) != len(block.Transactions) {
+		return nil, fmt.Errorf("got %d traces for the %d transactions of block %d", len(traces), len(block.Transactions), block.Number)
+	}
+
+	internal := []api.Transaction{}
+
+	for i, trace := range traces {
+		parent := block.Transactions[i]
+		if trace.TxHash != "" && !strings.EqualFold(trace.TxHash, parent.Hash) {
+			return nil, fmt.Errorf("trace of transaction %s does not match transaction %s of block %d", trace.TxHash, parent.Hash, block.Number)
+		}
+
+		if trace.Error != "" || trace.Result.Error != "" {
+			continue
+		}
+
+		// the calls share the hash and position of the transaction they were made by
+		template := api.Transaction{
+			Hash:             parent.Hash,
+			Kind:             api.KindInternal,
+			BlockHash:        block.Hash,
+			BlockNumber:      block.Number,
+			Timestamp:        block.Timestamp,
+			TransactionIndex: parent.TransactionIndex,
+		}
+
+		var err error
+
+		// the root frame is the transaction itself
+		for j, call := range trace.Result.Calls {
+			internal, err = appendInternalTransactions(internal, template, call, []int{j})
+			if err != nil {
+				return nil, fmt.Errorf("transaction %s: %w", parent.Hash, err)
+			}
+		}
+	}
+
+	return internal, nil
+}
+
+func appendInternalTransactions(internal []api.Transaction, template api.Transaction, frame CallFrame, traceAddress []int) ([]api.Transaction, error) {
+	if frame.Error != "" {
+		return internal, nil
+	}
+
+	callType := strings.ToLower(frame.Type)
+
+	// a delegate call runs in the context of the caller and reports the value it was called with
+	if frame.Value != "" && callType != "delegatecall" && callType != "staticcall" {
+		value, err := HexToBigInt(frame.Value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse value: %w", err)
+		}
+
+		if value.Sign() > 0 {
+			tx := template
+			tx.From = strings.ToLower(frame.From)
+			tx.To = strings.ToLower(frame.To)
+			tx.Value = value
+			tx.CallType = callType
+			tx.TraceAddress = traceAddress
+
+			// contracts created by contracts are the recipients of their create call
+			if callType == "create" || callType == "create2" {
+				tx.ContractCreation = true
+				tx.ContractAddress = tx.To
+			}
+
+			internal = append(internal, tx)
+		}
+	}
+
+	var err error
+
+	for i, call := range frame.Calls {
+		// copy the address so the calls do not share its backing array
+		address := append(append(make([]int, 0, len(traceAddress)+1), traceAddress...), i)
+
+		internal, err = appendInternalTransactions(internal, template, call, address)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return internal, nil
+}
@@ -0,0 +1,125 @@
+package blockchain_test
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/devshark/tx-parser-go/api"
+	"github.com/devshark/tx-parser-go/app/internal/blockchain"
+)
+
+func TestPublicNodeClientTraceBlockByNumber(t *testing.T) {
+	var params []any
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Method string `json:"method"`
+			Params []any  `json:"params"`
+		}
+
+		json.NewDecoder(r.Body).Decode(&request)
+		params = request.Params
+
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":[
+			{"txHash":"0x1","result":{"type":"CALL","from":"0xa","to":"0xb","value":"0x0","calls":[
+				{"type":"CALL","from":"0xb","to":"0xc","value":"0xde0b6b3a7640000"}
+			]}}
+		]}`))
+	}))
+	defer server.Close()
+
+	client := blockchain.NewPublicNodeClient(server.URL, log.Default()).(blockchain.TraceClient)
+
+	traces, err := client.TraceBlockByNumber(context.Background(), 16)
+	if err != nil {
+		t.Fatalf("TraceBlockByNumber returned an error: %v", err)
+	}
+
+	if !reflect.DeepEqual(params, []any{"0x10", map[string]any{"tracer": "callTracer"}}) {
+		t.Errorf("Expected the block to be traced with the callTracer, got %v", params)
+	}
+
+	if len(traces) != 1 || traces[0].TxHash != "0x1" || len(traces[0].Result.Calls) != 1 {
+		t.Fatalf("Unexpected traces %+v", traces)
+	}
+}
+
+func TestInternalTransactions(t *testing.T) {
+	block := &api.Block{
+		Number: 16,
+		Hash:   "0xb16",
+		Transactions: []api.Transaction{
+			{Hash: "0x1", TransactionIndex: 0},
+			{Hash: "0x2", TransactionIndex: 1},
+		},
+	}
+
+	traces := []blockchain.TransactionTrace{
+		{TxHash: "0x1", Result: blockchain.CallFrame{Type: "CALL", From: "0xa", To: "0xb", Value: "0x0", Calls: []blockchain.CallFrame{
+			// no value moved
+			{Type: "STATICCALL", From: "0xb", To: "0xc"},
+			{Type: "CALL", From: "0xb", To: "0xC", Value: "0x64", Calls: []blockchain.CallFrame{
+				// reports the value of the call it runs in
+				{Type: "DELEGATECALL", From: "0xc", To: "0xd", Value: "0x64"},
+				{Type: "CALL", From: "0xc", To: "0xe", Value: "0x32"},
+			}},
+			// reverted along with its calls
+			{Type: "CALL", From: "0xb", To: "0xf", Value: "0x1", Error: "execution reverted", Calls: []blockchain.CallFrame{
+				{Type: "CALL", From: "0xf", To: "0xa", Value: "0x1"},
+			}},
+		}}},
+		// the whole transaction reverted
+		{TxHash: "0x2", Result: blockchain.CallFrame{Type: "CALL", From: "0xa", To: "0xb", Error: "out of gas", Calls: []blockchain.CallFrame{
+			{Type: "CALL", From: "0xb", To: "0xc", Value: "0x1"},
+		}}},
+	}
+
+	internal, err := blockchain.InternalTransactions(block, traces)
+	if err != nil {
+		t.Fatalf("InternalTransactions returned an error: %v", err)
+	}
+
+	if len(internal) != 2 {
+		t.Fatalf("Expected 2 internal transactions, got %+v", internal)
+	}
+
+	expected := []struct {
+		to           string
+		value        string
+		traceAddress []int
+	}{
+		{"0xc", "100", []int{1}},
+		{"0xe", "50", []int{1, 1}},
+	}
+
+	for i, e := range expected {
+		tx := internal[i]
+
+		if tx.Kind != api.KindInternal || tx.Hash != "0x1" || tx.BlockHash != "0xb16" || tx.BlockNumber != 16 || tx.CallType != "call" {
+			t.Errorf("Unexpected internal transaction %+v", tx)
+		}
+
+		if tx.To != e.to || tx.Value.String() != e.value || !reflect.DeepEqual(tx.TraceAddress, e.traceAddress) {
+			t.Errorf("Expected %s wei to %s at %v, got %s wei to %s at %v", e.value, e.to, e.traceAddress, tx.Value, tx.To, tx.TraceAddress)
+		}
+	}
+
+	if internal[0].Key() == internal[1].Key() || internal[0].Key() == block.Transactions[0].Key() {
+		t.Errorf("Expected the internal transactions to have their own keys, got %s and %s", internal[0].Key(), internal[1].Key())
+	}
+
+	// the traces must match the transactions of the block
+	if _, err := blockchain.InternalTransactions(block, traces[:1]); err == nil {
+		t.Error("Expected an error when the traces do not cover every transaction")
+	}
+
+	traces[1].TxHash = "0x3"
+	if _, err := blockchain.InternalTransactions(block, traces); err == nil {
+		t.Error("Expected an error when a trace belongs to another transaction")
+	}
+}
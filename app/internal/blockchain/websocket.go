@@ -0,0 +1,428 @@
+package blockchain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/devshark/tx-parser-go/api"
+	"github.com/devshark/tx-parser-go/pkg/websocket"
+)
+
+const (
+	minReconnectDelay = 100 * time.Millisecond
+	maxReconnectDelay = 30 * time.Second
+
+	// DefaultPingInterval is how often a websocket connection is pinged to keep it alive
+	DefaultPingInterval = 15 * time.Second
+	// DefaultReadTimeout is how long a websocket connection may stay silent, pongs included, before it is dropped
+	DefaultReadTimeout = 2 * DefaultPingInterval
+)
+
+var ErrConnectionClosed = errors.New("websocket connection closed")
+
+// HeadSubscriber is implemented by clients that can push new chain heads,
+// the channel is closed once the context is cancelled
+type HeadSubscriber interface {
+	SubscribeNewHeads(ctx context.Context) (<-chan int64, error)
+}
+
+// IsWebSocketURL reports whether the node url should be used with NewWebSocketClient
+func IsWebSocketURL(nodeURL string) bool {
+	return strings.HasPrefix(nodeURL, "ws://") || strings.HasPrefix(nodeURL, "wss://")
+}
+
+// webSocketClient is a BlockchainClient talking JSON-RPC over a websocket,
+// which also lets the node push new heads through eth_subscribe
+type webSocketClient struct {
+	nodeURL      string
+	logger       *log.Logger
+	pingInterval time.Duration
+	readTimeout  time.Duration
+	callTimeout  time.Duration
+	nextID       atomic.Int64
+	mu           sync.Mutex // mutex for conn
+	conn         *wsConnection
+}
+
+// wsConnection is a single websocket connection along with its in-flight requests and subscriptions
+type wsConnection struct {
+	conn          *websocket.Conn
+	mu            sync.Mutex // mutex for pending and subscriptions
+	pending       map[int64]chan rpcMessage
+	subscriptions map[string]chan int64
+	closed        chan struct{}
+}
+
+// WebSocketOption customizes the webSocketClient
+type WebSocketOption func(*webSocketClient)
+
+// WithKeepAlive sets how often the connection is pinged, and how long it may stay silent before it is
+// dropped and dialed again, so that a half-open connection whose pongs never arrive does not hang
+func WithKeepAlive(pingInterval, readTimeout time.Duration) WebSocketOption {
+	return func(c *webSocketClient) {
+		if pingInterval > 0 && readTimeout > 0 {
+			c.pingInterval = pingInterval
+			c.readTimeout = readTimeout
+		}
+	}
+}
+
+// WithCallTimeout sets how long a call may wait for its response
+func WithCallTimeout(timeout time.Duration) WebSocketOption {
+	return func(c *webSocketClient) {
+		if timeout > 0 {
+			c.callTimeout = timeout
+		}
+	}
+}
+
+func NewWebSocketClient(nodeURL string, logger *log.Logger, opts ...WebSocketOption) BlockchainClient {
+	client := &webSocketClient{
+		nodeURL:      nodeURL,
+		logger:       logger,
+		pingInterval: DefaultPingInterval,
+		readTimeout:  DefaultReadTimeout,
+		callTimeout:  DefaultRequestTimeout,
+	}
+
+	for _, opt := range opts {
+		opt(client)
+	}
+
+	return client
+}
+
+// GetLatestBlockNumber fetches the latest block number
+func (c *webSocketClient) GetLatestBlockNumber(ctx context.Context) (int64, error) {
+	var result string
+
+	if err := c.call(ctx, "eth_blockNumber", []any{}, &result); err != nil {
+		return 0, err
+	}
+
+	blockNum, err := HexToInt64(result)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse block number: %w", err)
+	}
+
+	return blockNum, nil
+}
+
+// GetBlockByNumber fetches the block with the given number
+func (c *webSocketClient) GetBlockByNumber(ctx context.Context, number int64) (*api.Block, error) {
+	var result *EthBlockResult
+
+	if err := c.call(ctx, "eth_getBlockByNumber", []any{fmt.Sprintf("0x%x", number), true}, &result); err != nil {
+		return nil, err
+	}
+
+	if result == nil {
+		return nil, fmt.Errorf("%w: block %d", ErrBlockNotAvailable, number)
+	}
+
+	return convertBlock(result)
+}
+
+// GetBlockNumberByTag fetches the number of the block with the given tag, such as "safe" or "finalized"
+func (c *webSocketClient) GetBlockNumberByTag(ctx context.Context, tag string) (int64, error) {
+	var result *struct {
+		Number string `json:"number"`
+	}
+
+	if err := c.call(ctx, "eth_getBlockByNumber", []any{tag, false}, &result); err != nil {
+		return 0, err
+	}
+
+	if result == nil || result.Number == "" {
+		return 0, fmt.Errorf("%w: block tag %q", ErrBlockNotAvailable, tag)
+	}
+
+	return HexToInt64(result.Number)
+}
+
+// SubscribeNewHeads pushes the number of every new head, resubscribing whenever the connection
+// is re-established. Heads are dropped if the receiver is behind, only the latest one matters.
+func (c *webSocketClient) SubscribeNewHeads(ctx context.Context) (<-chan int64, error) {
+	heads := make(chan int64, 1)
+
+	go func() {
+		defer close(heads)
+
+		delay := minReconnectDelay
+
+		for {
+			conn, subscriptionID, err := c.subscribeNewHeads(ctx, heads)
+			if err == nil {
+				delay = minReconnectDelay
+
+				select {
+				case <-ctx.Done():
+					// stop the notifications before closing the channel, then on the node
+					conn.unsubscribe(subscriptionID)
+					c.unsubscribeNewHeads(conn, subscriptionID)
+					return
+				case <-conn.closed:
+					c.logger.Print("newHeads subscription lost, reconnecting")
+				}
+			} else if ctx.Err() == nil {
+				c.logger.Printf("failed to subscribe to newHeads, retrying in %s: %v", delay, err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+				delay = min(2*delay, maxReconnectDelay)
+			}
+		}
+	}()
+
+	return heads, nil
+}
+
+// subscribeNewHeads subscribes on the current connection and returns it along with the subscription id
+func (c *webSocketClient) subscribeNewHeads(ctx context.Context, heads chan int64) (*wsConnection, string, error) {
+	conn, err := c.connection(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var subscriptionID string
+
+	if err := c.callOn(ctx, conn, "eth_subscribe", []any{"newHeads"}, &subscriptionID); err != nil {
+		return nil, "", err
+	}
+
+	conn.mu.Lock()
+	conn.subscriptions[subscriptionID] = heads
+	conn.mu.Unlock()
+
+	return conn, subscriptionID, nil
+}
+
+// unsubscribeNewHeads cancels the subscription on the node, once the caller is gone
+func (c *webSocketClient) unsubscribeNewHeads(conn *wsConnection, subscriptionID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.callTimeout)
+	defer cancel()
+
+	var unsubscribed bool
+
+	if err := c.callOn(ctx, conn, "eth_unsubscribe", []any{subscriptionID}, &unsubscribed); err != nil && !errors.Is(err, ErrConnectionClosed) {
+		c.logger.Printf("failed to unsubscribe from newHeads: %v", err)
+	}
+}
+
+// call sends a request on the current connection, dialing a new one if needed
+func (c *webSocketClient) call(ctx context.Context, method string, params []any, result any) error {
+	conn, err := c.connection(ctx)
+	if err != nil {
+		return err
+	}
+
+	return c.callOn(ctx, conn, method, params, result)
+}
+
+// callOn sends a request on the given connection and waits for its response up to the call timeout
+func (c *webSocketClient) callOn(ctx context.Context, conn *wsConnection, method string, params []any, result any) error {
+	id := c.nextID.Add(1)
+
+	reqBody, err := json.Marshal(rpcRequest{JSONRPC: "2.0", Method: method, Params: params, ID: id})
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	response := make(chan rpcMessage, 1)
+
+	conn.mu.Lock()
+	conn.pending[id] = response
+	conn.mu.Unlock()
+
+	defer func() {
+		conn.mu.Lock()
+		delete(conn.pending, id)
+		conn.mu.Unlock()
+	}()
+
+	if err := conn.conn.WriteMessage(reqBody); err != nil {
+		conn.conn.Close()
+		return fmt.Errorf("failed to make request: %w", err)
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, c.callTimeout)
+	defer cancel()
+
+	select {
+	case <-callCtx.Done():
+		// unlike the deadline of the caller, the timeout of a single call is worth retrying
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		return fmt.Errorf("%w after %s", ErrRequestTimeout, c.callTimeout)
+	case <-conn.closed:
+		return ErrConnectionClosed
+	case message := <-response:
+		if message.Error != nil {
+			return newRPCError(message.Error, nil)
+		}
+
+		if err := json.Unmarshal(message.Result, result); err != nil {
+			return fmt.Errorf("failed to unmarshal response: %w", err)
+		}
+
+		return nil
+	}
+}
+
+// connection returns the current connection, dialing a new one if there is none
+func (c *webSocketClient) connection(ctx context.Context) (*wsConnection, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn != nil {
+		return c.conn, nil
+	}
+
+	conn, err := websocket.Dial(ctx, c.nodeURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+
+	// every frame, the pongs of the keepalive included, must arrive within the read timeout
+	conn.SetTimeout(c.readTimeout)
+
+	c.conn = &wsConnection{
+		conn:          conn,
+		pending:       make(map[int64]chan rpcMessage),
+		subscriptions: make(map[string]chan int64),
+		closed:        make(chan struct{}),
+	}
+
+	go c.readLoop(c.conn)
+	go c.keepAlive(c.conn)
+
+	return c.conn, nil
+}
+
+// keepAlive pings the connection every ping interval until it drops, so that the pongs keep an idle
+// connection within the read timeout while a half-open one, whose pongs never arrive, times out
+func (c *webSocketClient) keepAlive(conn *wsConnection) {
+	ticker := time.NewTicker(c.pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-conn.closed:
+			return
+		case <-ticker.C:
+			if err := conn.conn.Ping(); err != nil {
+				conn.conn.Close()
+				return
+			}
+		}
+	}
+}
+
+// readLoop dispatches responses and notifications until the connection drops or stays silent for the read timeout
+func (c *webSocketClient) readLoop(conn *wsConnection) {
+	defer func() {
+		conn.conn.Close()
+
+		c.mu.Lock()
+		if c.conn == conn {
+			c.conn = nil
+		}
+		c.mu.Unlock()
+
+		close(conn.closed)
+	}()
+
+	for {
+		data, err := conn.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var message rpcMessage
+		if err := json.Unmarshal(data, &message); err != nil {
+			c.logger.Printf("failed to unmarshal websocket message: %v", err)
+			continue
+		}
+
+		if message.ID != nil {
+			conn.mu.Lock()
+			response, ok := conn.pending[*message.ID]
+			conn.mu.Unlock()
+
+			if ok {
+				response <- message
+			}
+
+			continue
+		}
+
+		if message.Method == "eth_subscription" {
+			conn.notify(message.Params)
+		}
+	}
+}
+
+// notify forwards a newHeads notification, replacing any head the subscriber has not read yet
+func (conn *wsConnection) notify(params json.RawMessage) {
+	var notification struct {
+		Subscription string `json:"subscription"`
+		Result       struct {
+			Number string `json:"number"`
+		} `json:"result"`
+	}
+
+	if err := json.Unmarshal(params, &notification); err != nil {
+		return
+	}
+
+	number, err := HexToInt64(notification.Result.Number)
+	if err != nil {
+		return
+	}
+
+	// hold the lock while sending so unsubscribe can safely close the channel afterwards
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+
+	heads, ok := conn.subscriptions[notification.Subscription]
+	if !ok {
+		return
+	}
+
+	pushHead(heads, number)
+}
+
+// pushHead sends the head to the subscriber, replacing the one it has not read yet
+func pushHead(heads chan int64, number int64) {
+	for {
+		select {
+		case heads <- number:
+			return
+		default:
+			select {
+			case <-heads:
+			default:
+			}
+		}
+	}
+}
+
+// unsubscribe stops forwarding the notifications of a subscription
+func (conn *wsConnection) unsubscribe(subscriptionID string) {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+
+	delete(conn.subscriptions, subscriptionID)
+}
@@ -0,0 +1,305 @@
+package blockchain_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/devshark/tx-parser-go/app/internal/blockchain"
+	"github.com/devshark/tx-parser-go/pkg/websocket"
+)
+
+// wsNodeStandIn is an in-process websocket JSON-RPC node
+type wsNodeStandIn struct {
+	mu           sync.Mutex
+	conns        []*websocket.Conn
+	subscribes   int
+	unsubscribed []string
+}
+
+func (n *wsNodeStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := websocket.Upgrade(w, r)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	n.mu.Lock()
+	n.conns = append(n.conns, conn)
+	n.mu.Unlock()
+
+	for {
+		data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var request struct {
+			ID     int64  `json:"id"`
+			Method string `json:"method"`
+			Params []any  `json:"params"`
+		}
+		json.Unmarshal(data, &request)
+
+		var result string
+		switch request.Method {
+		case "eth_blockNumber":
+			result = `"0x10"`
+		case "eth_getBlockByNumber":
+			if request.Params[0] == blockchain.TagFinalized {
+				conn.WriteMessage([]byte(fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"error":{"code":-32000,"message":"finalized block not found"}}`, request.ID)))
+				continue
+			}
+
+			number := request.Params[0]
+			if number == blockchain.TagSafe {
+				number = "0x10"
+			}
+
+			result = fmt.Sprintf(`{"number":%q,"hash":"0xabc","parentHash":"0xdef","timestamp":"0x6746c0a3","transactions":[{"hash":"0x1","from":"0xa","to":"0xb","value":"0x56bc75e2d63100000","transactionIndex":"0x0"}]}`, number)
+		case "eth_subscribe":
+			n.mu.Lock()
+			n.subscribes++
+			result = fmt.Sprintf(`"0xsub%d"`, n.subscribes)
+			n.mu.Unlock()
+		case "eth_unsubscribe":
+			n.mu.Lock()
+			n.unsubscribed = append(n.unsubscribed, fmt.Sprint(request.Params[0]))
+			n.mu.Unlock()
+
+			result = "true"
+		default:
+			conn.WriteMessage([]byte(fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"error":{"code":-32601,"message":"method not found"}}`, request.ID)))
+			continue
+		}
+
+		conn.WriteMessage([]byte(fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"result":%s}`, request.ID, result)))
+	}
+}
+
+// pushHead notifies the latest subscription of a new head
+func (n *wsNodeStandIn) pushHead(number int64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	conn := n.conns[len(n.conns)-1]
+	conn.WriteMessage([]byte(fmt.Sprintf(`{"jsonrpc":"2.0","method":"eth_subscription","params":{"subscription":"0xsub%d","result":{"number":"0x%x"}}}`, n.subscribes, number)))
+}
+
+// dropConnections closes every open connection
+func (n *wsNodeStandIn) dropConnections() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for _, conn := range n.conns {
+		conn.Close()
+	}
+}
+
+func (n *wsNodeStandIn) subscribeCount() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.subscribes
+}
+
+func (n *wsNodeStandIn) connectionCount() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return len(n.conns)
+}
+
+// halfOpenNode never answers on its first connection, not even the pings, as a connection
+// the other end dropped without closing would. The next connections are served by the node.
+type halfOpenNode struct {
+	*wsNodeStandIn
+	mu       sync.Mutex
+	accepted int
+	release  chan struct{}
+}
+
+func (n *halfOpenNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n.mu.Lock()
+	n.accepted++
+	first := n.accepted == 1
+	n.mu.Unlock()
+
+	if !first {
+		n.wsNodeStandIn.ServeHTTP(w, r)
+		return
+	}
+
+	conn, err := websocket.Upgrade(w, r)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	<-n.release
+}
+
+func TestWebSocketClientMethods(t *testing.T) {
+	server := httptest.NewServer(&wsNodeStandIn{})
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+	if !blockchain.IsWebSocketURL(url) {
+		t.Fatalf("Expected %s to be a websocket url", url)
+	}
+
+	client := blockchain.NewWebSocketClient(url, log.Default())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	blockNumber, err := client.GetLatestBlockNumber(ctx)
+	if err != nil {
+		t.Fatalf("GetLatestBlockNumber returned an error: %v", err)
+	}
+	if blockNumber != 16 {
+		t.Errorf("Expected block number 16, got %d", blockNumber)
+	}
+
+	block, err := client.GetBlockByNumber(ctx, 21)
+	if err != nil {
+		t.Fatalf("GetBlockByNumber returned an error: %v", err)
+	}
+	if block.Number != 21 || len(block.Transactions) != 1 {
+		t.Fatalf("Expected block 21 with 1 transaction, got %+v", block)
+	}
+
+	// 100 ether does not fit in an int64 of wei
+	if value := block.Transactions[0].Value; value.String() != "100000000000000000000" || value.Ether() != "100" {
+		t.Errorf("Expected a value of 100 ether, got %s wei", value)
+	}
+
+	finalityClient := client.(blockchain.FinalityClient)
+
+	safeBlock, err := finalityClient.GetBlockNumberByTag(ctx, blockchain.TagSafe)
+	if err != nil {
+		t.Fatalf("GetBlockNumberByTag returned an error: %v", err)
+	}
+	if safeBlock != 0x10 {
+		t.Errorf("Expected safe block 16, got %d", safeBlock)
+	}
+
+	// Test rpc errors are surfaced
+	if _, err := finalityClient.GetBlockNumberByTag(ctx, blockchain.TagFinalized); err == nil {
+		t.Error("Expected an error for the finalized tag, got nil")
+	}
+}
+
+func TestWebSocketClientSubscribeNewHeads(t *testing.T) {
+	node := &wsNodeStandIn{}
+	server := httptest.NewServer(node)
+	defer server.Close()
+
+	client := blockchain.NewWebSocketClient("ws"+strings.TrimPrefix(server.URL, "http"), log.Default())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	heads, err := client.(blockchain.HeadSubscriber).SubscribeNewHeads(ctx)
+	if err != nil {
+		t.Fatalf("SubscribeNewHeads returned an error: %v", err)
+	}
+
+	expectHead := func(expected int64, subscribes int) {
+		t.Helper()
+
+		deadline := time.After(2 * time.Second)
+		for node.subscribeCount() < subscribes {
+			select {
+			case <-deadline:
+				t.Fatalf("Expected %d subscriptions, got %d", subscribes, node.subscribeCount())
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+
+		node.pushHead(expected)
+
+		select {
+		case head := <-heads:
+			if head != expected {
+				t.Errorf("Expected head %d, got %d", expected, head)
+			}
+		case <-deadline:
+			t.Fatalf("Expected head %d to be pushed", expected)
+		}
+	}
+
+	expectHead(17, 1)
+
+	// the client reconnects and subscribes again once the connection drops
+	node.dropConnections()
+
+	expectHead(18, 2)
+
+	cancel()
+
+	for range heads {
+	}
+
+	// the subscription is cancelled on the node too
+	node.mu.Lock()
+	defer node.mu.Unlock()
+
+	if len(node.unsubscribed) != 1 || node.unsubscribed[0] != "0xsub2" {
+		t.Errorf("Expected subscription 0xsub2 to be cancelled on the node, got %v", node.unsubscribed)
+	}
+}
+
+func TestWebSocketClientHalfOpenConnection(t *testing.T) {
+	node := &halfOpenNode{wsNodeStandIn: &wsNodeStandIn{}, release: make(chan struct{})}
+	server := httptest.NewServer(node)
+	defer server.Close()
+	defer close(node.release)
+
+	client := blockchain.NewWebSocketClient("ws"+strings.TrimPrefix(server.URL, "http"), log.Default(),
+		blockchain.WithKeepAlive(20*time.Millisecond, 100*time.Millisecond),
+		blockchain.WithCallTimeout(50*time.Millisecond))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// the call gives up instead of hanging on the silent connection
+	if _, err := client.GetLatestBlockNumber(ctx); !errors.Is(err, blockchain.ErrRequestTimeout) {
+		t.Fatalf("Expected ErrRequestTimeout, got %v", err)
+	}
+
+	// the silent connection is dropped after the read timeout and a new one is dialed
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		blockNumber, err := client.GetLatestBlockNumber(ctx)
+		if err == nil {
+			if blockNumber != 16 {
+				t.Errorf("Expected block number 16, got %d", blockNumber)
+			}
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the client to reconnect, got %v", err)
+		}
+	}
+
+	// the pongs keep the new connection alive while it is idle for longer than the read timeout
+	time.Sleep(300 * time.Millisecond)
+
+	if _, err := client.GetLatestBlockNumber(ctx); err != nil {
+		t.Fatalf("GetLatestBlockNumber returned an error: %v", err)
+	}
+
+	if count := node.connectionCount(); count != 1 {
+		t.Errorf("Expected the idle connection to be kept, got %d connections to the node", count)
+	}
+}
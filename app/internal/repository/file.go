@@ -0,0 +1,672 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/devshark/tx-parser-go/api"
+)
+
+const (
+	walFileName      = "wal.log"
+	snapshotFileName = "snapshot.json"
+	// DefaultSnapshotEvery is the number of changes logged between two snapshots
+	DefaultSnapshotEvery = 10000
+)
+
+var ErrStoreClosed = errors.New("file store is closed")
+
+// Operations recorded in the write-ahead log
+const (
+	opSaveTransaction   = "save-transaction"
+	opSaveTokenTransfer = "save-token-transfer"
+	opSaveNFTTransfer   = "save-nft-transfer"
+	opSaveWithdrawal    = "save-withdrawal"
+	opUpdateBlockStatus = "update-block-status"
+	opPromoteStatus     = "promote-status"
+	opSubscribe         = "subscribe"
+	opSaveSubscription  = "save-subscription"
+	opUnsubscribe       = "unsubscribe"
+	opUpdateLastParsed  = "update-last-parsed-block"
+	opAddFailedBlock    = "add-failed-block"
+	opRemoveFailedBlock = "remove-failed-block"
+	opCommitBlock       = "commit-block"
+	opCommitRecords     = "commit-records"
+)
+
+// walEntry is a change recorded in the write-ahead log, only the fields of its operation are set
+type walEntry struct {
+	Seq           uint64                `json:"seq"`
+	Op            string                `json:"op"`
+	Address       string                `json:"address,omitempty"`
+	BlockHash     string                `json:"blockHash,omitempty"`
+	BlockNumber   int64                 `json:"blockNumber,omitempty"`
+	Status        api.TransactionStatus `json:"status,omitempty"`
+	Transaction   *api.Transaction      `json:"transaction,omitempty"`
+	TokenTransfer *api.TokenTransfer    `json:"tokenTransfer,omitempty"`
+	NFTTransfer   *api.NFTTransfer      `json:"nftTransfer,omitempty"`
+	Withdrawal    *api.Withdrawal       `json:"withdrawal,omitempty"`
+	FailedBlock   *api.FailedBlock      `json:"failedBlock,omitempty"`
+	Matches       *BlockMatches         `json:"matches,omitempty"`
+	Subscription  *api.Subscription     `json:"subscription,omitempty"`
+}
+
+// snapshot is the whole state of the store, Seq is the last change of the log it includes
+type snapshot struct {
+	Seq             uint64                         `json:"seq"`
+	LastParsedBlock int64                          `json:"lastParsedBlock"`
+	FailedBlocks    []api.FailedBlock              `json:"failedBlocks"`
+	Subscriptions   []api.Subscription             `json:"subscriptions"`
+	Transactions    map[string][]api.Transaction   `json:"transactions"`
+	TokenTransfers  map[string][]api.TokenTransfer `json:"tokenTransfers"`
+	NFTTransfers    map[string][]api.NFTTransfer   `json:"nftTransfers"`
+	Withdrawals     map[string][]api.Withdrawal    `json:"withdrawals"`
+}
+
+// FileStore persists the repositories to a directory. Every change is appended to a
+// write-ahead log and synced to disk before it is applied in memory, and the whole state
+// is periodically written to a snapshot, which starts a new log. On startup the snapshot
+// is loaded and the changes logged after it are replayed, a change torn by a crash is dropped.
+type FileStore struct {
+	mu            sync.Mutex
+	dir           string
+	wal           *os.File
+	seq           uint64 // last change logged
+	snapshotSeq   uint64 // last change included in the snapshot
+	snapshotEvery int
+	transactions  *FileTransactionRepository
+	subscribers   *FileSubscriberRepository
+	blocks        *FileBlockRepository
+}
+
+// FileStoreOption customizes the FileStore
+type FileStoreOption func(*FileStore)
+
+// WithSnapshotEvery sets the number of changes logged between two snapshots
+func WithSnapshotEvery(changes int) FileStoreOption {
+	return func(s *FileStore) {
+		if changes > 0 {
+			s.snapshotEvery = changes
+		}
+	}
+}
+
+// FileTransactionRepository is the TransactionRepository of a FileStore, reads are served from memory
+type FileTransactionRepository struct {
+	*InMemoryTransactionRepository
+	store *FileStore
+}
+
+// FileSubscriberRepository is the SubscriberRepository of a FileStore, reads are served from memory
+type FileSubscriberRepository struct {
+	*InMemorySubscriberRepository
+	store *FileStore
+}
+
+// FileBlockRepository is the BlockRepository of a FileStore, reads are served from memory
+type FileBlockRepository struct {
+	*InMemoryBlockRepository
+	store *FileStore
+}
+
+// NewFileStore opens the store in the given directory, creating it if needed,
+// and restores the state left by the previous run
+func NewFileStore(dir string, opts ...FileStoreOption) (*FileStore, error) {
+	s := &FileStore{
+		dir:           dir,
+		snapshotEvery: DefaultSnapshotEvery,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.transactions = &FileTransactionRepository{InMemoryTransactionRepository: NewInMemoryTransactionRepository(), store: s}
+	s.subscribers = &FileSubscriberRepository{InMemorySubscriberRepository: NewInMemorySubscriberRepository(), store: s}
+	s.blocks = &FileBlockRepository{InMemoryBlockRepository: NewInMemoryBlockRepository(), store: s}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	if err := s.loadSnapshot(); err != nil {
+		return nil, err
+	}
+
+	if err := s.replay(); err != nil {
+		return nil, err
+	}
+
+	wal, err := os.OpenFile(filepath.Join(dir, walFileName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open write-ahead log: %w", err)
+	}
+
+	s.wal = wal
+
+	return s, nil
+}
+
+func (s *FileStore) Transactions() *FileTransactionRepository {
+	return s.transactions
+}
+
+func (s *FileStore) Subscribers() *FileSubscriberRepository {
+	return s.subscribers
+}
+
+func (s *FileStore) Blocks() *FileBlockRepository {
+	return s.blocks
+}
+
+// Close writes a last snapshot, so that the next start does not replay the log, and closes the log
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.wal == nil {
+		return ErrStoreClosed
+	}
+
+	err := s.snapshot()
+
+	if closeErr := s.wal.Close(); err == nil {
+		err = closeErr
+	}
+
+	s.wal = nil
+
+	return err
+}
+
+// Snapshot writes the whole state to disk and starts a new log
+func (s *FileStore) Snapshot() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.wal == nil {
+		return ErrStoreClosed
+	}
+
+	return s.snapshot()
+}
+
+// record logs a change then applies it, the change is not applied if it could not be logged.
+// The errors of applying it are returned as is, replaying the change fails the same way.
+func (s *FileStore) record(entry walEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.wal == nil {
+		return ErrStoreClosed
+	}
+
+	entry.Seq = s.seq + 1
+
+	line, err := encodeEntry(entry)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.wal.Write(line); err != nil {
+		return fmt.Errorf("failed to write to the write-ahead log: %w", err)
+	}
+
+	if err := s.wal.Sync(); err != nil {
+		return fmt.Errorf("failed to sync the write-ahead log: %w", err)
+	}
+
+	s.seq = entry.Seq
+
+	if err := s.apply(entry); err != nil {
+		return err
+	}
+
+	if s.seq-s.snapshotSeq >= uint64(s.snapshotEvery) {
+		// the change is durable in the log already, a failed snapshot is retried with the next change
+		_ = s.snapshot()
+	}
+
+	return nil
+}
+
+// apply applies a change to the in-memory repositories
+func (s *FileStore) apply(entry walEntry) error {
+	ctx := context.Background()
+
+	switch entry.Op {
+	case opSaveTransaction:
+		return s.transactions.InMemoryTransactionRepository.SaveTransaction(ctx, entry.Address, *entry.Transaction)
+	case opSaveTokenTransfer:
+		return s.transactions.InMemoryTransactionRepository.SaveTokenTransfer(ctx, entry.Address, *entry.TokenTransfer)
+	case opSaveNFTTransfer:
+		return s.transactions.InMemoryTransactionRepository.SaveNFTTransfer(ctx, entry.Address, *entry.NFTTransfer)
+	case opSaveWithdrawal:
+		return s.transactions.InMemoryTransactionRepository.SaveWithdrawal(ctx, entry.Address, *entry.Withdrawal)
+	case opUpdateBlockStatus:
+		return s.transactions.InMemoryTransactionRepository.UpdateBlockStatus(ctx, entry.BlockHash, entry.Status)
+	case opPromoteStatus:
+		return s.transactions.InMemoryTransactionRepository.PromoteStatus(ctx, entry.BlockNumber, entry.Status)
+	case opSubscribe:
+		s.subscribers.subscribe(*entry.Subscription)
+
+		return nil
+	case opSaveSubscription:
+		return s.subscribers.InMemorySubscriberRepository.SaveSubscription(ctx, *entry.Subscription)
+	case opUnsubscribe:
+		return s.subscribers.InMemorySubscriberRepository.Unsubscribe(ctx, entry.Address)
+	case opUpdateLastParsed:
+		return s.blocks.InMemoryBlockRepository.UpdateLastParsedBlock(ctx, entry.BlockNumber)
+	case opAddFailedBlock:
+		return s.blocks.InMemoryBlockRepository.AddFailedBlock(ctx, *entry.FailedBlock)
+	case opRemoveFailedBlock:
+		return s.blocks.InMemoryBlockRepository.RemoveFailedBlock(ctx, entry.BlockNumber)
+	case opCommitBlock:
+		return s.applyCommit(ctx, entry.BlockNumber, *entry.Matches)
+	case opCommitRecords:
+		s.applyRecords(ctx, *entry.Matches)
+
+		return nil
+	default:
+		return fmt.Errorf("unknown operation %q", entry.Op)
+	}
+}
+
+// applyCommit applies a committed block, whose addresses were validated before it was logged
+func (s *FileStore) applyCommit(ctx context.Context, blockNumber int64, matches BlockMatches) error {
+	s.applyRecords(ctx, matches)
+
+	// a block behind the last parsed block leaves it as is
+	if err := s.blocks.InMemoryBlockRepository.UpdateLastParsedBlock(ctx, blockNumber); err != nil && !errors.Is(err, ErrInvalidBlock) {
+		return err
+	}
+
+	return nil
+}
+
+// applyRecords applies the committed records of a block, whose addresses were validated before they were logged
+func (s *FileStore) applyRecords(ctx context.Context, matches BlockMatches) {
+	transactions := s.transactions.InMemoryTransactionRepository
+
+	for _, match := range matches.Transactions {
+		transactions.SaveTransaction(ctx, match.Address, match.Record)
+	}
+
+	for _, match := range matches.TokenTransfers {
+		transactions.SaveTokenTransfer(ctx, match.Address, match.Record)
+	}
+
+	for _, match := range matches.NFTTransfers {
+		transactions.SaveNFTTransfer(ctx, match.Address, match.Record)
+	}
+
+	for _, match := range matches.Withdrawals {
+		transactions.SaveWithdrawal(ctx, match.Address, match.Record)
+	}
+}
+
+// encodeEntry encodes a change as a line of the log: the CRC-32 of the JSON entry, then the entry
+func encodeEntry(entry walEntry) ([]byte, error) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s: %w", entry.Op, err)
+	}
+
+	return fmt.Appendf(nil, "%08x %s\n", crc32.ChecksumIEEE(data), data), nil
+}
+
+// decodeEntry decodes a line of the log, lines torn by a crash fail their checksum
+func decodeEntry(line []byte) (walEntry, error) {
+	var entry walEntry
+
+	checksum, data, ok := bytes.Cut(line, []byte(" "))
+	if !ok || string(checksum) != fmt.Sprintf("%08x", crc32.ChecksumIEEE(data)) {
+		return entry, errors.New("checksum mismatch")
+	}
+
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return entry, err
+	}
+
+	return entry, nil
+}
+
+// replay applies the changes logged after the snapshot. The log is cut after the last
+// intact change, so that a change torn by a crash is not followed by new ones.
+func (s *FileStore) replay() error {
+	path := filepath.Join(s.dir, walFileName)
+
+	file, err := os.OpenFile(path, os.O_RDWR, 0o644)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to open write-ahead log: %w", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+
+	var intact int64
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// a last line without its newline was torn
+			break
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to read write-ahead log: %w", err)
+		}
+
+		entry, err := decodeEntry(bytes.TrimSuffix(line, []byte("\n")))
+		if err != nil {
+			break
+		}
+
+		intact += int64(len(line))
+
+		if entry.Seq <= s.seq {
+			// included in the snapshot already
+			continue
+		}
+
+		s.seq = entry.Seq
+
+		// the changes that failed when they were made fail again, as they did not change anything
+		_ = s.apply(entry)
+	}
+
+	if err := file.Truncate(intact); err != nil {
+		return fmt.Errorf("failed to truncate write-ahead log: %w", err)
+	}
+
+	return file.Sync()
+}
+
+// loadSnapshot restores the state of the snapshot, if any
+func (s *FileStore) loadSnapshot() error {
+	data, err := os.ReadFile(filepath.Join(s.dir, snapshotFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	var state snapshot
+
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("failed to unmarshal snapshot: %w", err)
+	}
+
+	s.seq, s.snapshotSeq = state.Seq, state.Seq
+
+	s.blocks.lastParsedBlock = state.LastParsedBlock
+	for _, failed := range state.FailedBlocks {
+		s.blocks.failedBlocks[failed.Number] = failed
+	}
+
+	for _, subscription := range state.Subscriptions {
+		s.subscribers.subscribers[subscription.Address] = subscription
+	}
+
+	restore(s.transactions.transactions, state.Transactions)
+	restore(s.transactions.tokenTransfers, state.TokenTransfers)
+	restore(s.transactions.nftTransfers, state.NFTTransfers)
+	restore(s.transactions.withdrawals, state.Withdrawals)
+
+	return nil
+}
+
+func restore[T any](records map[string][]T, snapshot map[string][]T) {
+	for address, recs := range snapshot {
+		records[address] = recs
+	}
+}
+
+// snapshot writes the state to a temporary file, synced then renamed over the previous snapshot,
+// and starts a new log. A crash at any point leaves either snapshot along with a log to replay.
+// s.mu must be held.
+func (s *FileStore) snapshot() error {
+	state := snapshot{Seq: s.seq}
+
+	s.blocks.RLock()
+	state.LastParsedBlock = s.blocks.lastParsedBlock
+	s.blocks.RUnlock()
+
+	state.FailedBlocks, _ = s.blocks.GetFailedBlocks(context.Background())
+
+	state.Subscriptions, _ = s.subscribers.GetSubscriptions(context.Background())
+
+	s.transactions.RLock()
+	state.Transactions = s.transactions.transactions
+	state.TokenTransfers = s.transactions.tokenTransfers
+	state.NFTTransfers = s.transactions.nftTransfers
+	state.Withdrawals = s.transactions.withdrawals
+	data, err := json.Marshal(state)
+	s.transactions.RUnlock()
+
+	if err != nil {
+		return fmt.Errorf("failed to marshal snapshot: %w", err)
+	}
+
+	if err := writeFileAtomic(filepath.Join(s.dir, snapshotFileName), data); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	s.snapshotSeq = s.seq
+
+	// the changes of the log are in the snapshot now
+	if err := s.wal.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate write-ahead log: %w", err)
+	}
+
+	return s.wal.Sync()
+}
+
+// writeFileAtomic replaces a file with the given content, so that a crash leaves either the old or the new content
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	// sync the directory so that the rename itself is durable
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+
+	return dir.Sync()
+}
+
+func (r *FileTransactionRepository) SaveTransaction(ctx context.Context, address string, tx api.Transaction) error {
+	cleanAddress, err := ValidateAddress(address)
+	if err != nil {
+		return fmt.Errorf("ValidateAddress: %w", err)
+	}
+
+	return r.store.record(walEntry{Op: opSaveTransaction, Address: cleanAddress, Transaction: &tx})
+}
+
+func (r *FileTransactionRepository) SaveTokenTransfer(ctx context.Context, address string, transfer api.TokenTransfer) error {
+	cleanAddress, err := ValidateAddress(address)
+	if err != nil {
+		return fmt.Errorf("ValidateAddress: %w", err)
+	}
+
+	return r.store.record(walEntry{Op: opSaveTokenTransfer, Address: cleanAddress, TokenTransfer: &transfer})
+}
+
+func (r *FileTransactionRepository) SaveNFTTransfer(ctx context.Context, address string, transfer api.NFTTransfer) error {
+	cleanAddress, err := ValidateAddress(address)
+	if err != nil {
+		return fmt.Errorf("ValidateAddress: %w", err)
+	}
+
+	return r.store.record(walEntry{Op: opSaveNFTTransfer, Address: cleanAddress, NFTTransfer: &transfer})
+}
+
+func (r *FileTransactionRepository) SaveWithdrawal(ctx context.Context, address string, withdrawal api.Withdrawal) error {
+	cleanAddress, err := ValidateAddress(address)
+	if err != nil {
+		return fmt.Errorf("ValidateAddress: %w", err)
+	}
+
+	return r.store.record(walEntry{Op: opSaveWithdrawal, Address: cleanAddress, Withdrawal: &withdrawal})
+}
+
+func (r *FileTransactionRepository) UpdateBlockStatus(ctx context.Context, blockHash string, status api.TransactionStatus) error {
+	if strings.TrimSpace(blockHash) == "" {
+		return ErrEmptyHash
+	}
+
+	return r.store.record(walEntry{Op: opUpdateBlockStatus, BlockHash: blockHash, Status: status})
+}
+
+func (r *FileTransactionRepository) PromoteStatus(ctx context.Context, blockNumber int64, status api.TransactionStatus) error {
+	if _, err := promotedFrom(status); err != nil {
+		return err
+	}
+
+	return r.store.record(walEntry{Op: opPromoteStatus, BlockNumber: blockNumber, Status: status})
+}
+
+func (r *FileSubscriberRepository) Subscribe(ctx context.Context, address string) error {
+	cleanAddress, err := ValidateAddress(address)
+	if err != nil {
+		return fmt.Errorf("ValidateAddress: %w", err)
+	}
+
+	// subscribing again does not change anything, no need to log it
+	if r.exists(cleanAddress) {
+		return nil
+	}
+
+	// the creation time is logged, so that replaying the change restores it
+	subscription := api.Subscription{Address: cleanAddress, CreatedAt: time.Now()}
+
+	return r.store.record(walEntry{Op: opSubscribe, Address: cleanAddress, Subscription: &subscription})
+}
+
+func (r *FileSubscriberRepository) SaveSubscription(ctx context.Context, subscription api.Subscription) error {
+	cleanAddress, err := ValidateAddress(subscription.Address)
+	if err != nil {
+		return fmt.Errorf("ValidateAddress: %w", err)
+	}
+
+	subscription.Address = cleanAddress
+
+	if subscription.CreatedAt.IsZero() {
+		subscription.CreatedAt = time.Now()
+	}
+
+	return r.store.record(walEntry{Op: opSaveSubscription, Address: cleanAddress, Subscription: &subscription})
+}
+
+func (r *FileSubscriberRepository) Unsubscribe(ctx context.Context, address string) error {
+	cleanAddress, err := ValidateAddress(address)
+	if err != nil {
+		return fmt.Errorf("ValidateAddress: %w", err)
+	}
+
+	if !r.exists(cleanAddress) {
+		return ErrNotSubscribed
+	}
+
+	return r.store.record(walEntry{Op: opUnsubscribe, Address: cleanAddress})
+}
+
+// exists reports whether the address has a subscription, expired or not
+func (r *FileSubscriberRepository) exists(address string) bool {
+	r.RLock()
+	defer r.RUnlock()
+
+	_, exists := r.subscribers[address]
+
+	return exists
+}
+
+func (r *FileBlockRepository) UpdateLastParsedBlock(ctx context.Context, blockNumber int64) error {
+	if _, err := ValidateBlock(ctx, blockNumber); err != nil {
+		return err
+	}
+
+	if last, _ := r.GetLastParsedBlock(ctx); blockNumber < last {
+		return ErrInvalidBlock
+	}
+
+	return r.store.record(walEntry{Op: opUpdateLastParsed, BlockNumber: blockNumber})
+}
+
+func (r *FileBlockRepository) AddFailedBlock(ctx context.Context, failed api.FailedBlock) error {
+	if _, err := ValidateBlock(ctx, failed.Number); err != nil {
+		return err
+	}
+
+	return r.store.record(walEntry{Op: opAddFailedBlock, FailedBlock: &failed})
+}
+
+func (r *FileBlockRepository) RemoveFailedBlock(ctx context.Context, blockNumber int64) error {
+	return r.store.record(walEntry{Op: opRemoveFailedBlock, BlockNumber: blockNumber})
+}
+
+// CommitBlock logs the records of the block along with the last parsed block as a single change
+func (r *FileBlockRepository) CommitBlock(ctx context.Context, blockNumber int64, blockHash string, matches BlockMatches) error {
+	return r.commit(ctx, opCommitBlock, blockNumber, blockHash, matches)
+}
+
+// CommitRecords logs the records of the block as a single change, leaving the last parsed block as is
+func (r *FileBlockRepository) CommitRecords(ctx context.Context, blockNumber int64, blockHash string, matches BlockMatches) error {
+	return r.commit(ctx, opCommitRecords, blockNumber, blockHash, matches)
+}
+
+// commit logs the records of the block with the given commit operation
+func (r *FileBlockRepository) commit(ctx context.Context, op string, blockNumber int64, blockHash string, matches BlockMatches) error {
+	if _, err := ValidateBlock(ctx, blockNumber); err != nil {
+		return err
+	}
+
+	matches, err := matches.clean(blockNumber, blockHash)
+	if err != nil {
+		return err
+	}
+
+	return r.store.record(walEntry{Op: op, BlockNumber: blockNumber, BlockHash: blockHash, Matches: &matches})
+}
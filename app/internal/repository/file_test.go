@@ -0,0 +1,317 @@
+package repository_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/devshark/tx-parser-go/api"
+	"github.com/devshark/tx-parser-go/app/internal/repository"
+)
+
+// fillStore makes a change of every kind
+func fillStore(t *testing.T, txRepo repository.TransactionRepository, subRepo repository.SubscriberRepository, blockRepo repository.BlockRepository) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	steps := []error{
+		subRepo.Subscribe(ctx, "0xAbC"),
+		subRepo.SaveSubscription(ctx, api.Subscription{Address: "0xdef", SubscriptionMetadata: api.SubscriptionMetadata{Label: "hot wallet", Tags: []string{"team"}}, CreatedAtBlock: 41}),
+		subRepo.Subscribe(ctx, "0x123"),
+		subRepo.Unsubscribe(ctx, "0x123"),
+		txRepo.SaveTransaction(ctx, "0xabc", api.Transaction{Hash: "0x1", BlockHash: "0xb1", Value: api.NewBigInt(100)}),
+		txRepo.SaveTransaction(ctx, "0xabc", api.Transaction{Hash: "0x2", BlockHash: "0xb2", Value: api.NewBigInt(200)}),
+		txRepo.SaveTokenTransfer(ctx, "0xabc", api.TokenTransfer{TransactionHash: "0x1", BlockHash: "0xb1", Amount: api.NewBigInt(5)}),
+		txRepo.SaveNFTTransfer(ctx, "0xabc", api.NFTTransfer{TransactionHash: "0x1", BlockHash: "0xb1", TokenID: api.NewBigInt(7), Amount: api.NewBigInt(1)}),
+		txRepo.SaveWithdrawal(ctx, "0xabc", api.Withdrawal{Index: 3, BlockHash: "0xb2", Amount: api.NewBigInt(32)}),
+		txRepo.UpdateBlockStatus(ctx, "0xb1", api.StatusConfirmed),
+		txRepo.UpdateBlockStatus(ctx, "0xb2", api.StatusOrphaned),
+		txRepo.SaveWithdrawal(ctx, "0xabc", api.Withdrawal{Index: 4, BlockHash: "0xb3", BlockNumber: 3, Status: api.StatusPending}),
+		txRepo.SaveWithdrawal(ctx, "0xabc", api.Withdrawal{Index: 5, BlockHash: "0xb4", BlockNumber: 4, Status: api.StatusPending}),
+		txRepo.PromoteStatus(ctx, 3, api.StatusConfirmed),
+		blockRepo.UpdateLastParsedBlock(ctx, 42),
+		blockRepo.AddFailedBlock(ctx, api.FailedBlock{Number: 40, Attempts: 2, LastError: "boom"}),
+		blockRepo.AddFailedBlock(ctx, api.FailedBlock{Number: 41}),
+		blockRepo.RemoveFailedBlock(ctx, 41),
+	}
+
+	for i, err := range steps {
+		if err != nil {
+			t.Fatalf("Unexpected error at step %d: %v", i, err)
+		}
+	}
+}
+
+// checkStore checks the state left by fillStore
+func checkStore(t *testing.T, txRepo repository.TransactionRepository, subRepo repository.SubscriberRepository, blockRepo repository.BlockRepository) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	if subscribed, _ := subRepo.IsSubscribed(ctx, "0xabc"); !subscribed {
+		t.Error("Expected 0xabc to be subscribed")
+	}
+
+	subscriptions, _ := subRepo.GetSubscriptions(ctx)
+	if len(subscriptions) != 2 || subscriptions[1].Label != "hot wallet" || !subscriptions[1].HasTag("team") ||
+		subscriptions[1].CreatedAtBlock != 41 || subscriptions[0].CreatedAt.IsZero() {
+		t.Errorf("Expected the subscriptions of 0xabc and 0xdef with their metadata, got %+v", subscriptions)
+	}
+
+	txs, _ := txRepo.GetTransactions(ctx, "0xabc")
+	if len(txs) != 1 || txs[0].Hash != "0x1" || txs[0].Status != api.StatusConfirmed || txs[0].Value.Int64() != 100 {
+		t.Errorf("Expected the confirmed transaction 0x1, got %+v", txs)
+	}
+
+	orphaned, _ := txRepo.QueryTransactions(ctx, "0xabc", api.TransactionQuery{Status: api.StatusOrphaned})
+	if len(orphaned.Transactions) != 1 || orphaned.Transactions[0].Hash != "0x2" {
+		t.Errorf("Expected the orphaned transaction 0x2, got %+v", orphaned.Transactions)
+	}
+
+	tokenTransfers, _ := txRepo.GetTokenTransfers(ctx, "0xabc", "")
+	if len(tokenTransfers) != 1 || tokenTransfers[0].Amount.Int64() != 5 || tokenTransfers[0].Status != api.StatusConfirmed {
+		t.Errorf("Expected the confirmed token transfer of 5, got %+v", tokenTransfers)
+	}
+
+	nftTransfers, _ := txRepo.GetNFTTransfers(ctx, "0xabc", "")
+	if len(nftTransfers) != 1 || nftTransfers[0].TokenID.Int64() != 7 {
+		t.Errorf("Expected the transfer of token 7, got %+v", nftTransfers)
+	}
+
+	withdrawals, _ := txRepo.GetWithdrawals(ctx, "0xabc", "")
+	if len(withdrawals) != 2 || withdrawals[0].Status != api.StatusConfirmed || withdrawals[1].Status != api.StatusPending {
+		t.Errorf("Expected the confirmed withdrawal 4 and the pending withdrawal 5, got %+v", withdrawals)
+	}
+
+	withdrawals, _ = txRepo.GetWithdrawals(ctx, "0xabc", api.StatusOrphaned)
+	if len(withdrawals) != 1 || withdrawals[0].Index != 3 {
+		t.Errorf("Expected the orphaned withdrawal 3, got %+v", withdrawals)
+	}
+
+	if last, _ := blockRepo.GetLastParsedBlock(ctx); last != 42 {
+		t.Errorf("Expected last parsed block to be 42, got %d", last)
+	}
+
+	failedBlocks, _ := blockRepo.GetFailedBlocks(ctx)
+	if len(failedBlocks) != 1 || failedBlocks[0].Number != 40 || failedBlocks[0].Attempts != 2 || failedBlocks[0].LastError != "boom" {
+		t.Errorf("Expected failed block 40, got %+v", failedBlocks)
+	}
+}
+
+func TestFileStore_Restore(t *testing.T) {
+	var _ repository.TransactionRepository = &repository.FileTransactionRepository{}
+	var _ repository.SubscriberRepository = &repository.FileSubscriberRepository{}
+	var _ repository.BlockRepository = &repository.FileBlockRepository{}
+
+	cases := []struct {
+		name  string
+		close bool
+		opts  []repository.FileStoreOption
+	}{
+		{name: "from the log after a crash"},
+		{name: "from the snapshot after a shutdown", close: true},
+		{name: "from the snapshot and the log", opts: []repository.FileStoreOption{repository.WithSnapshotEvery(5)}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			store, err := repository.NewFileStore(dir, c.opts...)
+			if err != nil {
+				t.Fatalf("NewFileStore returned unexpected error: %v", err)
+			}
+
+			fillStore(t, store.Transactions(), store.Subscribers(), store.Blocks())
+			checkStore(t, store.Transactions(), store.Subscribers(), store.Blocks())
+
+			// without Close, as if the process crashed
+			if c.close {
+				if err := store.Close(); err != nil {
+					t.Fatalf("Close returned unexpected error: %v", err)
+				}
+			}
+
+			restored, err := repository.NewFileStore(dir, c.opts...)
+			if err != nil {
+				t.Fatalf("NewFileStore returned unexpected error: %v", err)
+			}
+			defer restored.Close()
+
+			checkStore(t, restored.Transactions(), restored.Subscribers(), restored.Blocks())
+		})
+	}
+}
+
+func TestFileStore_TornWrite(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	store, err := repository.NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore returned unexpected error: %v", err)
+	}
+
+	store.Subscribers().Subscribe(ctx, "0x1")
+	store.Subscribers().Subscribe(ctx, "0x2")
+
+	// the last change was only partly written when the process crashed
+	wal := filepath.Join(dir, "wal.log")
+
+	data, err := os.ReadFile(wal)
+	if err != nil {
+		t.Fatalf("Failed to read the log: %v", err)
+	}
+
+	lines := strings.SplitAfter(string(data), "\n")
+	if err := os.WriteFile(wal, []byte(lines[0]+lines[1][:len(lines[1])/2]), 0o644); err != nil {
+		t.Fatalf("Failed to tear the log: %v", err)
+	}
+
+	restored, err := repository.NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore returned unexpected error: %v", err)
+	}
+
+	if subscribed, _ := restored.Subscribers().IsSubscribed(ctx, "0x1"); !subscribed {
+		t.Error("Expected the intact change to be restored")
+	}
+
+	if subscribed, _ := restored.Subscribers().IsSubscribed(ctx, "0x2"); subscribed {
+		t.Error("Expected the torn change to be dropped")
+	}
+
+	// the changes made after the torn one are restored as well
+	if err := restored.Subscribers().Subscribe(ctx, "0x3"); err != nil {
+		t.Fatalf("Subscribe returned unexpected error: %v", err)
+	}
+
+	again, err := repository.NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore returned unexpected error: %v", err)
+	}
+
+	for address, expected := range map[string]bool{"0x1": true, "0x2": false, "0x3": true} {
+		if subscribed, _ := again.Subscribers().IsSubscribed(ctx, address); subscribed != expected {
+			t.Errorf("Expected the subscription to %s to be %t, got %t", address, expected, subscribed)
+		}
+	}
+}
+
+// TestFileStore_LegacySubscribers restores the subscribers of a store written before subscriptions had metadata
+func TestFileStore_Snapshot(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	store, err := repository.NewFileStore(dir, repository.WithSnapshotEvery(2))
+	if err != nil {
+		t.Fatalf("NewFileStore returned unexpected error: %v", err)
+	}
+
+	for _, number := range []int64{1, 2, 3} {
+		if err := store.Blocks().UpdateLastParsedBlock(ctx, number); err != nil {
+			t.Fatalf("UpdateLastParsedBlock returned unexpected error: %v", err)
+		}
+	}
+
+	// the snapshot taken after the second change started a new log
+	data, _ := os.ReadFile(filepath.Join(dir, "wal.log"))
+	if lines := strings.Count(string(data), "\n"); lines != 1 {
+		t.Errorf("Expected a single change in the log, got %d", lines)
+	}
+
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close returned unexpected error: %v", err)
+	}
+
+	if err := store.Blocks().UpdateLastParsedBlock(ctx, 4); err != repository.ErrStoreClosed {
+		t.Errorf("Expected ErrStoreClosed after Close, got %v", err)
+	}
+
+	restored, err := repository.NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore returned unexpected error: %v", err)
+	}
+	defer restored.Close()
+
+	if last, _ := restored.Blocks().GetLastParsedBlock(ctx); last != 3 {
+		t.Errorf("Expected last parsed block to be 3, got %d", last)
+	}
+
+	// the rejected changes are not logged
+	if err := restored.Blocks().UpdateLastParsedBlock(ctx, 2); err == nil {
+		t.Error("Expected error when updating with lower block number, got nil")
+	}
+
+	entries, _ := os.ReadDir(dir)
+
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+
+	if !reflect.DeepEqual(names, []string{"snapshot.json", "wal.log"}) {
+		t.Errorf("Expected only the snapshot and the log in the directory, got %v", names)
+	}
+}
+
+func TestFileStore_TornCommit(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	store, err := repository.NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore returned unexpected error: %v", err)
+	}
+
+	for number, hash := range []string{"0x1", "0x2"} {
+		matches := repository.BlockMatches{
+			Transactions: []repository.Match[api.Transaction]{
+				{Address: "0xabc", Record: api.Transaction{Hash: hash}},
+				{Address: "0xdef", Record: api.Transaction{Hash: hash}},
+			},
+		}
+
+		if err := store.Blocks().CommitBlock(ctx, int64(number+1), "0xb"+hash[2:], matches); err != nil {
+			t.Fatalf("CommitBlock returned unexpected error: %v", err)
+		}
+	}
+
+	// the process crashed while the second block was written
+	wal := filepath.Join(dir, "wal.log")
+
+	data, err := os.ReadFile(wal)
+	if err != nil {
+		t.Fatalf("Failed to read the log: %v", err)
+	}
+
+	lines := strings.SplitAfter(string(data), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected a single change per block, got %d lines", len(lines)-1)
+	}
+
+	if err := os.WriteFile(wal, []byte(lines[0]+lines[1][:len(lines[1])-10]), 0o644); err != nil {
+		t.Fatalf("Failed to tear the log: %v", err)
+	}
+
+	restored, err := repository.NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore returned unexpected error: %v", err)
+	}
+	defer restored.Close()
+
+	// the first block is restored whole, the second one is not restored at all
+	if last, _ := restored.Blocks().GetLastParsedBlock(ctx); last != 1 {
+		t.Errorf("Expected last parsed block to be 1, got %d", last)
+	}
+
+	for _, address := range []string{"0xabc", "0xdef"} {
+		if txs, _ := restored.Transactions().GetTransactions(ctx, address); len(txs) != 1 || txs[0].BlockHash != "0xb1" {
+			t.Errorf("Expected only the transaction of block 0xb1 for %s, got %+v", address, txs)
+		}
+	}
+}
@@ -3,8 +3,11 @@ package repository
 import (
 	"context"
 	"fmt"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/devshark/tx-parser-go/api"
 )
@@ -19,22 +22,29 @@ type InMemoryRepository struct {
 
 type InMemoryTransactionRepository struct {
 	sync.RWMutex
-	transactions map[string][]api.Transaction
+	transactions   map[string][]api.Transaction
+	tokenTransfers map[string][]api.TokenTransfer
+	nftTransfers   map[string][]api.NFTTransfer
+	withdrawals    map[string][]api.Withdrawal
 }
 
 type InMemorySubscriberRepository struct {
 	sync.RWMutex
-	subscribers map[string]struct{}
+	subscribers map[string]api.Subscription
 }
 
 type InMemoryBlockRepository struct {
 	sync.RWMutex
 	lastParsedBlock int64
+	failedBlocks    map[int64]api.FailedBlock
 }
 
 func NewInMemoryTransactionRepository() *InMemoryTransactionRepository {
 	return &InMemoryTransactionRepository{
-		transactions: make(map[string][]api.Transaction),
+		transactions:   make(map[string][]api.Transaction),
+		tokenTransfers: make(map[string][]api.TokenTransfer),
+		nftTransfers:   make(map[string][]api.NFTTransfer),
+		withdrawals:    make(map[string][]api.Withdrawal),
 	}
 }
 
@@ -47,13 +57,20 @@ func (r *InMemoryTransactionRepository) SaveTransaction(ctx context.Context, add
 		return fmt.Errorf("ValidateAddress: %w", err)
 	}
 
-	// skip if tx hash already exists
+	// skip if the tx already exists, unless it was orphaned and is now included again
 	txs, ok := r.transactions[cleanAddress]
 	if ok {
-		for _, _tx := range txs {
-			if strings.EqualFold(_tx.Hash, tx.Hash) {
-				return nil
+		for i, _tx := range txs {
+			if _tx.Key() != tx.Key() {
+				continue
 			}
+
+			if _tx.Status == api.StatusOrphaned {
+				txs = append(txs[:i:i], txs[i+1:]...)
+				break
+			}
+
+			return nil
 		}
 	}
 
@@ -71,29 +88,357 @@ func (r *InMemoryTransactionRepository) GetTransactions(ctx context.Context, add
 		return nil, fmt.Errorf("ValidateAddress: %w", err)
 	}
 
-	return r.transactions[cleanAddress], nil
+	return selectStatus(r.transactions[cleanAddress], "", func(tx *api.Transaction) api.TransactionStatus { return tx.Status }), nil
+}
+
+// QueryTransactions filters and sorts the transactions of the address on every call
+func (r *InMemoryTransactionRepository) QueryTransactions(ctx context.Context, address string, query api.TransactionQuery) (api.TransactionPage, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	page := api.TransactionPage{Transactions: []api.Transaction{}}
+
+	cleanAddress, err := ValidateAddress(address)
+	if err != nil {
+		return page, fmt.Errorf("ValidateAddress: %w", err)
+	}
+
+	var after *cursor
+	if query.Cursor != "" {
+		decoded, err := decodeCursor(query.Cursor)
+		if err != nil {
+			return page, err
+		}
+
+		after = &decoded
+	}
+
+	selected := []api.Transaction{}
+	for _, tx := range r.transactions[cleanAddress] {
+		if query.Matches(cleanAddress, tx) && (after == nil || after.before(cursorOf(tx))) {
+			selected = append(selected, tx)
+		}
+	}
+
+	sort.Slice(selected, func(i, j int) bool { return cursorOf(selected[i]).before(cursorOf(selected[j])) })
+
+	if query.Limit > 0 && len(selected) > query.Limit {
+		selected = selected[:query.Limit]
+		page.NextCursor = cursorOf(selected[len(selected)-1]).encode()
+	}
+
+	page.Transactions = selected
+
+	return page, nil
+}
+
+func (r *InMemoryTransactionRepository) SaveTokenTransfer(ctx context.Context, address string, transfer api.TokenTransfer) error {
+	r.Lock()
+	defer r.Unlock()
+
+	cleanAddress, err := ValidateAddress(address)
+	if err != nil {
+		return fmt.Errorf("ValidateAddress: %w", err)
+	}
+
+	// skip if the log already exists, unless it was orphaned and is now included again
+	transfers := r.tokenTransfers[cleanAddress]
+	for i, _transfer := range transfers {
+		if !strings.EqualFold(_transfer.TransactionHash, transfer.TransactionHash) || _transfer.LogIndex != transfer.LogIndex {
+			continue
+		}
+
+		if _transfer.Status == api.StatusOrphaned {
+			transfers = append(transfers[:i:i], transfers[i+1:]...)
+			break
+		}
+
+		return nil
+	}
+
+	r.tokenTransfers[cleanAddress] = append(transfers, transfer)
+
+	return nil
+}
+
+func (r *InMemoryTransactionRepository) GetTokenTransfers(ctx context.Context, address string, status api.TransactionStatus) ([]api.TokenTransfer, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	cleanAddress, err := ValidateAddress(address)
+	if err != nil {
+		return nil, fmt.Errorf("ValidateAddress: %w", err)
+	}
+
+	return selectStatus(r.tokenTransfers[cleanAddress], status, func(t *api.TokenTransfer) api.TransactionStatus { return t.Status }), nil
+}
+
+func (r *InMemoryTransactionRepository) SaveNFTTransfer(ctx context.Context, address string, transfer api.NFTTransfer) error {
+	r.Lock()
+	defer r.Unlock()
+
+	cleanAddress, err := ValidateAddress(address)
+	if err != nil {
+		return fmt.Errorf("ValidateAddress: %w", err)
+	}
+
+	// skip if the token of the log already exists, unless it was orphaned and is now included again
+	transfers := r.nftTransfers[cleanAddress]
+	for i, _transfer := range transfers {
+		if !strings.EqualFold(_transfer.TransactionHash, transfer.TransactionHash) ||
+			_transfer.LogIndex != transfer.LogIndex || _transfer.BatchIndex != transfer.BatchIndex {
+			continue
+		}
+
+		if _transfer.Status == api.StatusOrphaned {
+			transfers = append(transfers[:i:i], transfers[i+1:]...)
+			break
+		}
+
+		return nil
+	}
+
+	r.nftTransfers[cleanAddress] = append(transfers, transfer)
+
+	return nil
+}
+
+func (r *InMemoryTransactionRepository) GetNFTTransfers(ctx context.Context, address string, status api.TransactionStatus) ([]api.NFTTransfer, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	cleanAddress, err := ValidateAddress(address)
+	if err != nil {
+		return nil, fmt.Errorf("ValidateAddress: %w", err)
+	}
+
+	return selectStatus(r.nftTransfers[cleanAddress], status, func(t *api.NFTTransfer) api.TransactionStatus { return t.Status }), nil
+}
+
+func (r *InMemoryTransactionRepository) SaveWithdrawal(ctx context.Context, address string, withdrawal api.Withdrawal) error {
+	r.Lock()
+	defer r.Unlock()
+
+	cleanAddress, err := ValidateAddress(address)
+	if err != nil {
+		return fmt.Errorf("ValidateAddress: %w", err)
+	}
+
+	// skip if the withdrawal already exists, unless it was orphaned and is now included again
+	withdrawals := r.withdrawals[cleanAddress]
+	for i, _withdrawal := range withdrawals {
+		if _withdrawal.Index != withdrawal.Index {
+			continue
+		}
+
+		if _withdrawal.Status == api.StatusOrphaned {
+			withdrawals = append(withdrawals[:i:i], withdrawals[i+1:]...)
+			break
+		}
+
+		return nil
+	}
+
+	r.withdrawals[cleanAddress] = append(withdrawals, withdrawal)
+
+	return nil
+}
+
+func (r *InMemoryTransactionRepository) GetWithdrawals(ctx context.Context, address string, status api.TransactionStatus) ([]api.Withdrawal, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	cleanAddress, err := ValidateAddress(address)
+	if err != nil {
+		return nil, fmt.Errorf("ValidateAddress: %w", err)
+	}
+
+	return selectStatus(r.withdrawals[cleanAddress], status, func(w *api.Withdrawal) api.TransactionStatus { return w.Status }), nil
+}
+
+func (r *InMemoryTransactionRepository) UpdateBlockStatus(ctx context.Context, blockHash string, status api.TransactionStatus) error {
+	r.Lock()
+	defer r.Unlock()
+
+	if strings.TrimSpace(blockHash) == "" {
+		return ErrEmptyHash
+	}
+
+	updateBlockStatus(r.transactions, blockHash, func(tx *api.Transaction) (string, *api.TransactionStatus) {
+		return tx.BlockHash, &tx.Status
+	}, status)
+	updateBlockStatus(r.tokenTransfers, blockHash, func(t *api.TokenTransfer) (string, *api.TransactionStatus) {
+		return t.BlockHash, &t.Status
+	}, status)
+	updateBlockStatus(r.nftTransfers, blockHash, func(t *api.NFTTransfer) (string, *api.TransactionStatus) {
+		return t.BlockHash, &t.Status
+	}, status)
+	updateBlockStatus(r.withdrawals, blockHash, func(w *api.Withdrawal) (string, *api.TransactionStatus) {
+		return w.BlockHash, &w.Status
+	}, status)
+
+	return nil
+}
+
+func (r *InMemoryTransactionRepository) PromoteStatus(ctx context.Context, blockNumber int64, status api.TransactionStatus) error {
+	from, err := promotedFrom(status)
+	if err != nil {
+		return err
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	promoteStatus(r.transactions, blockNumber, from, func(tx *api.Transaction) (int64, *api.TransactionStatus) {
+		return tx.BlockNumber, &tx.Status
+	}, status)
+	promoteStatus(r.tokenTransfers, blockNumber, from, func(t *api.TokenTransfer) (int64, *api.TransactionStatus) {
+		return t.BlockNumber, &t.Status
+	}, status)
+	promoteStatus(r.nftTransfers, blockNumber, from, func(t *api.NFTTransfer) (int64, *api.TransactionStatus) {
+		return t.BlockNumber, &t.Status
+	}, status)
+	promoteStatus(r.withdrawals, blockNumber, from, func(w *api.Withdrawal) (int64, *api.TransactionStatus) {
+		return w.BlockNumber, &w.Status
+	}, status)
+
+	return nil
+}
+
+// selectStatus returns the records selected by the status filter, see api.SelectsStatus
+func selectStatus[T any](records []T, filter api.TransactionStatus, statusOf func(*T) api.TransactionStatus) []T {
+	var selected []T
+
+	for i := range records {
+		if api.SelectsStatus(filter, statusOf(&records[i])) {
+			selected = append(selected, records[i])
+		}
+	}
+
+	return selected
+}
+
+// updateBlockStatus sets the status of the records of the given block,
+// fields returns the block hash and a pointer to the status of a record
+func updateBlockStatus[T any](records map[string][]T, blockHash string, fields func(*T) (string, *api.TransactionStatus), status api.TransactionStatus) {
+	for address, recs := range records {
+		var updated []T
+
+		for i := range recs {
+			if hash, _ := fields(&recs[i]); !strings.EqualFold(hash, blockHash) {
+				continue
+			}
+
+			// copy on first write since callers of the getters may still hold the old slice
+			if updated == nil {
+				updated = append([]T(nil), recs...)
+			}
+
+			_, recordStatus := fields(&updated[i])
+			*recordStatus = status
+		}
+
+		if updated != nil {
+			records[address] = updated
+		}
+	}
+}
+
+// promoteStatus sets the status of the records at or below the given block number whose status is one of from,
+// fields returns the block number and a pointer to the status of a record
+func promoteStatus[T any](records map[string][]T, blockNumber int64, from []api.TransactionStatus, fields func(*T) (int64, *api.TransactionStatus), status api.TransactionStatus) {
+	for address, recs := range records {
+		var updated []T
+
+		for i := range recs {
+			if number, recordStatus := fields(&recs[i]); number > blockNumber || !slices.Contains(from, *recordStatus) {
+				continue
+			}
+
+			// copy on first write since callers of the getters may still hold the old slice
+			if updated == nil {
+				updated = append([]T(nil), recs...)
+			}
+
+			_, recordStatus := fields(&updated[i])
+			*recordStatus = status
+		}
+
+		if updated != nil {
+			records[address] = updated
+		}
+	}
 }
 
 func NewInMemorySubscriberRepository() *InMemorySubscriberRepository {
 	return &InMemorySubscriberRepository{
-		subscribers: make(map[string]struct{}),
+		subscribers: make(map[string]api.Subscription),
 	}
 }
 
-// Subscribe creates a new transaction slice for the given address if it doesn't exist; does not overwrite existing address' transactions
+// Subscribe subscribes the address without metadata, created now; an existing subscription is left as is
 func (r *InMemorySubscriberRepository) Subscribe(ctx context.Context, address string) error {
+	cleanAddress, err := ValidateAddress(address)
+	if err != nil {
+		return fmt.Errorf("ValidateAddress: %w", err)
+	}
+
+	r.subscribe(api.Subscription{Address: cleanAddress, CreatedAt: time.Now()})
+
+	return nil
+}
+
+// subscribe adds the subscription unless the address is subscribed already
+func (r *InMemorySubscriberRepository) subscribe(subscription api.Subscription) {
+	r.Lock()
+	defer r.Unlock()
+
+	if _, exists := r.subscribers[subscription.Address]; !exists {
+		r.subscribers[subscription.Address] = subscription
+	}
+}
+
+// SaveSubscription stores the subscription, its creation time defaults to now
+func (r *InMemorySubscriberRepository) SaveSubscription(ctx context.Context, subscription api.Subscription) error {
+	cleanAddress, err := ValidateAddress(subscription.Address)
+	if err != nil {
+		return fmt.Errorf("ValidateAddress: %w", err)
+	}
+
+	subscription.Address = cleanAddress
+	subscription.Tags = slices.Clone(subscription.Tags)
+
+	if subscription.CreatedAt.IsZero() {
+		subscription.CreatedAt = time.Now()
+	}
+
 	r.Lock()
 	defer r.Unlock()
 
+	if existing, exists := r.subscribers[cleanAddress]; exists {
+		subscription.CreatedAtBlock, subscription.CreatedAt = existing.CreatedAtBlock, existing.CreatedAt
+	}
+
+	r.subscribers[cleanAddress] = subscription
+
+	return nil
+}
+
+func (r *InMemorySubscriberRepository) Unsubscribe(ctx context.Context, address string) error {
 	cleanAddress, err := ValidateAddress(address)
 	if err != nil {
 		return fmt.Errorf("ValidateAddress: %w", err)
 	}
 
+	r.Lock()
+	defer r.Unlock()
+
 	if _, exists := r.subscribers[cleanAddress]; !exists {
-		r.subscribers[cleanAddress] = struct{}{}
+		return ErrNotSubscribed
 	}
 
+	delete(r.subscribers, cleanAddress)
+
 	return nil
 }
 
@@ -106,13 +451,51 @@ func (r *InMemorySubscriberRepository) IsSubscribed(ctx context.Context, address
 		return false, fmt.Errorf("ValidateAddress: %w", err)
 	}
 
-	_, exists := r.subscribers[cleanAddress]
+	subscription, exists := r.subscribers[cleanAddress]
+
+	return exists && !subscription.Expired(time.Now()), nil
+}
+
+func (r *InMemorySubscriberRepository) GetSubscription(ctx context.Context, address string) (api.Subscription, error) {
+	cleanAddress, err := ValidateAddress(address)
+	if err != nil {
+		return api.Subscription{}, fmt.Errorf("ValidateAddress: %w", err)
+	}
+
+	r.RLock()
+	defer r.RUnlock()
+
+	subscription, exists := r.subscribers[cleanAddress]
+	if !exists {
+		return api.Subscription{}, ErrNotSubscribed
+	}
+
+	subscription.Tags = slices.Clone(subscription.Tags)
 
-	return exists, nil
+	return subscription, nil
+}
+
+func (r *InMemorySubscriberRepository) GetSubscriptions(ctx context.Context) ([]api.Subscription, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	subscriptions := make([]api.Subscription, 0, len(r.subscribers))
+	for _, subscription := range r.subscribers {
+		subscription.Tags = slices.Clone(subscription.Tags)
+		subscriptions = append(subscriptions, subscription)
+	}
+
+	sort.Slice(subscriptions, func(i, j int) bool {
+		return subscriptions[i].Address < subscriptions[j].Address
+	})
+
+	return subscriptions, nil
 }
 
 func NewInMemoryBlockRepository() *InMemoryBlockRepository {
-	return &InMemoryBlockRepository{}
+	return &InMemoryBlockRepository{
+		failedBlocks: make(map[int64]api.FailedBlock),
+	}
 }
 
 func (r *InMemoryBlockRepository) GetLastParsedBlock(ctx context.Context) (int64, error) {
@@ -140,3 +523,44 @@ func (r *InMemoryBlockRepository) UpdateLastParsedBlock(ctx context.Context, blo
 
 	return nil
 }
+
+func (r *InMemoryBlockRepository) AddFailedBlock(ctx context.Context, failed api.FailedBlock) error {
+	r.Lock()
+	defer r.Unlock()
+
+	if valid, err := ValidateBlock(ctx, failed.Number); err != nil {
+		return err
+	} else if !valid {
+		return ErrInvalidBlock
+	}
+
+	r.failedBlocks[failed.Number] = failed
+
+	return nil
+}
+
+func (r *InMemoryBlockRepository) RemoveFailedBlock(ctx context.Context, blockNumber int64) error {
+	r.Lock()
+	defer r.Unlock()
+
+	delete(r.failedBlocks, blockNumber)
+
+	return nil
+}
+
+// GetFailedBlocks returns the failed blocks ordered by block number
+func (r *InMemoryBlockRepository) GetFailedBlocks(ctx context.Context) ([]api.FailedBlock, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	failedBlocks := make([]api.FailedBlock, 0, len(r.failedBlocks))
+	for _, failed := range r.failedBlocks {
+		failedBlocks = append(failedBlocks, failed)
+	}
+
+	sort.Slice(failedBlocks, func(i, j int) bool {
+		return failedBlocks[i].Number < failedBlocks[j].Number
+	})
+
+	return failedBlocks, nil
+}
@@ -44,7 +44,7 @@ func TestSaveTransaction(t *testing.T) {
 		Hash:  "0x123",
 		From:  "0xabc",
 		To:    "0xdef",
-		Value: 100,
+		Value: api.NewBigInt(100),
 	}
 
 	err := repo.SaveTransaction(ctx, tx.From, tx)
@@ -57,7 +57,7 @@ func TestSaveTransaction(t *testing.T) {
 		Hash:  "0x456",
 		From:  "",
 		To:    "0xdef",
-		Value: 200,
+		Value: api.NewBigInt(200),
 	}
 
 	err = repo.SaveTransaction(ctx, txEmptyFrom.From, txEmptyFrom)
@@ -70,7 +70,7 @@ func TestSaveTransaction(t *testing.T) {
 		Hash:  "0x789",
 		From:  "0xabc",
 		To:    "",
-		Value: 300,
+		Value: api.NewBigInt(300),
 	}
 
 	err = repo.SaveTransaction(ctx, txEmptyTo.To, txEmptyTo)
@@ -84,8 +84,8 @@ func TestGetTransactions(t *testing.T) {
 	ctx := context.Background()
 
 	address := "0xabc"
-	tx1 := api.Transaction{Hash: "0x123", From: address, To: "0xdef", Value: 100}
-	tx2 := api.Transaction{Hash: "0x456", From: "0xghi", To: address, Value: 200}
+	tx1 := api.Transaction{Hash: "0x123", From: address, To: "0xdef", Value: api.NewBigInt(100)}
+	tx2 := api.Transaction{Hash: "0x456", From: "0xghi", To: address, Value: api.NewBigInt(200)}
 
 	repo.SaveTransaction(ctx, address, tx1)
 	repo.SaveTransaction(ctx, address, tx2)
@@ -119,6 +119,238 @@ func TestGetTransactions(t *testing.T) {
 	}
 }
 
+func TestUpdateBlockStatus(t *testing.T) {
+	repo := repository.NewInMemoryTransactionRepository()
+	ctx := context.Background()
+
+	tx1 := api.Transaction{Hash: "0x123", From: "0xabc", To: "0xdef", Value: api.NewBigInt(100), BlockHash: "0xb1", Status: api.StatusPending}
+	tx2 := api.Transaction{Hash: "0x456", From: "0xabc", To: "0xdef", Value: api.NewBigInt(200), BlockHash: "0xb2", Status: api.StatusPending}
+
+	repo.SaveTransaction(ctx, "0xabc", tx1)
+	repo.SaveTransaction(ctx, "0xabc", tx2)
+
+	// Test successful case
+	if err := repo.UpdateBlockStatus(ctx, "0xB1", api.StatusConfirmed); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	txs, _ := repo.GetTransactions(ctx, "0xabc")
+	if txs[0].Status != api.StatusConfirmed || txs[1].Status != api.StatusPending {
+		t.Errorf("Expected only %s to be confirmed, got %+v", tx1.Hash, txs)
+	}
+
+	// Test an orphaned transaction is replaced when included in another block
+	if err := repo.UpdateBlockStatus(ctx, "0xb2", api.StatusOrphaned); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tx2.BlockHash = "0xb3"
+	repo.SaveTransaction(ctx, "0xabc", tx2)
+
+	txs, _ = repo.GetTransactions(ctx, "0xabc")
+	if len(txs) != 2 || !containsTransaction(txs, tx2) {
+		t.Errorf("Expected %s to be replaced by its copy in block 0xb3, got %+v", tx2.Hash, txs)
+	}
+
+	// Test updating with empty block hash
+	if err := repo.UpdateBlockStatus(ctx, "", api.StatusConfirmed); err == nil {
+		t.Fatal("Expected error when updating with empty block hash, got nil")
+	}
+}
+
+func TestPromoteStatus(t *testing.T) {
+	repo := repository.NewInMemoryTransactionRepository()
+	ctx := context.Background()
+
+	repo.SaveTransaction(ctx, "0xabc", api.Transaction{Hash: "0x1", BlockHash: "0xb1", BlockNumber: 1, Status: api.StatusPending})
+	repo.SaveTransaction(ctx, "0xabc", api.Transaction{Hash: "0x2", BlockHash: "0xb2", BlockNumber: 2, Status: api.StatusOrphaned})
+	repo.SaveTransaction(ctx, "0xabc", api.Transaction{Hash: "0x3", BlockHash: "0xb3", BlockNumber: 3, Status: api.StatusPending})
+	repo.SaveTokenTransfer(ctx, "0xabc", api.TokenTransfer{TransactionHash: "0x1", BlockHash: "0xb1", BlockNumber: 1, Status: api.StatusPending})
+
+	if err := repo.PromoteStatus(ctx, 2, api.StatusConfirmed); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// a finalized block is never confirmed again
+	if err := repo.PromoteStatus(ctx, 1, api.StatusFinalized); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if err := repo.PromoteStatus(ctx, 2, api.StatusConfirmed); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	txs, _ := repo.GetTransactions(ctx, "0xabc")
+	if len(txs) != 2 || txs[0].Status != api.StatusFinalized || txs[1].Status != api.StatusPending {
+		t.Errorf("Expected 0x1 finalized and 0x3 still pending, got %+v", txs)
+	}
+
+	if page, _ := repo.QueryTransactions(ctx, "0xabc", api.TransactionQuery{Status: api.StatusOrphaned}); len(page.Transactions) != 1 || page.Transactions[0].Hash != "0x2" {
+		t.Errorf("Expected 0x2 still orphaned, got %+v", page.Transactions)
+	}
+
+	if transfers, _ := repo.GetTokenTransfers(ctx, "0xabc", ""); transfers[0].Status != api.StatusFinalized {
+		t.Errorf("Expected the token transfer to be finalized, got %+v", transfers)
+	}
+
+	// Test promoting to a status that is not further along
+	if err := repo.PromoteStatus(ctx, 3, api.StatusOrphaned); !errors.Is(err, repository.ErrInvalidStatus) {
+		t.Errorf("Expected ErrInvalidStatus, got %v", err)
+	}
+}
+
+func TestSaveInternalTransaction(t *testing.T) {
+	repo := repository.NewInMemoryTransactionRepository()
+	ctx := context.Background()
+
+	external := api.Transaction{Hash: "0x123", Kind: api.KindExternal, From: "0xabc", To: "0xc0ffee"}
+	first := api.Transaction{Hash: "0x123", Kind: api.KindInternal, From: "0xc0ffee", To: "0xabc", TraceAddress: []int{0}}
+	second := api.Transaction{Hash: "0x123", Kind: api.KindInternal, From: "0xc0ffee", To: "0xabc", TraceAddress: []int{0, 1}}
+
+	// the internal transactions share the hash of the external one
+	for _, tx := range []api.Transaction{external, first, second, first} {
+		if err := repo.SaveTransaction(ctx, "0xabc", tx); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	txs, _ := repo.GetTransactions(ctx, "0xabc")
+	if len(txs) != 3 {
+		t.Fatalf("Expected 3 transactions, got %d", len(txs))
+	}
+}
+
+func TestTokenTransfers(t *testing.T) {
+	repo := repository.NewInMemoryTransactionRepository()
+	ctx := context.Background()
+
+	transfer1 := api.TokenTransfer{Contract: "0xc0ffee", From: "0xabc", To: "0xdef", Amount: api.NewBigInt(100), TransactionHash: "0x123", LogIndex: 0, BlockHash: "0xb1", Status: api.StatusPending}
+	transfer2 := api.TokenTransfer{Contract: "0xc0ffee", From: "0xabc", To: "0xdef", Amount: api.NewBigInt(200), TransactionHash: "0x123", LogIndex: 1, BlockHash: "0xb1", Status: api.StatusPending}
+
+	// Test the same log is only stored once
+	for _, transfer := range []api.TokenTransfer{transfer1, transfer2, transfer1} {
+		if err := repo.SaveTokenTransfer(ctx, "0xABC", transfer); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	transfers, err := repo.GetTokenTransfers(ctx, "0xabc", "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(transfers) != 2 {
+		t.Fatalf("Expected 2 token transfers, got %d", len(transfers))
+	}
+
+	// Test the status follows the block
+	if err := repo.UpdateBlockStatus(ctx, "0xb1", api.StatusOrphaned); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Test the orphaned transfers are left out unless asked for
+	if transfers, _ = repo.GetTokenTransfers(ctx, "0xabc", ""); len(transfers) != 0 {
+		t.Errorf("Expected no token transfers, got %+v", transfers)
+	}
+
+	transfers, _ = repo.GetTokenTransfers(ctx, "0xabc", api.StatusOrphaned)
+	if len(transfers) != 2 || transfers[0].Status != api.StatusOrphaned || transfers[1].Status != api.StatusOrphaned {
+		t.Errorf("Expected the token transfers to be orphaned, got %+v", transfers)
+	}
+
+	// Test an orphaned transfer is replaced when included in another block
+	transfer1.BlockHash = "0xb2"
+	repo.SaveTokenTransfer(ctx, "0xabc", transfer1)
+
+	transfers, _ = repo.GetTokenTransfers(ctx, "0xabc", "")
+	if len(transfers) != 1 || transfers[0].BlockHash != "0xb2" {
+		t.Errorf("Expected the orphaned transfer to be replaced, got %+v", transfers)
+	}
+
+	if transfers, _ = repo.GetTokenTransfers(ctx, "0xabc", api.StatusOrphaned); len(transfers) != 1 || transfers[0].LogIndex != 1 {
+		t.Errorf("Expected the other transfer to stay orphaned, got %+v", transfers)
+	}
+
+	// Test saving with empty address
+	if err := repo.SaveTokenTransfer(ctx, "", transfer1); err == nil {
+		t.Fatal("Expected error when saving with empty address, got nil")
+	}
+}
+
+func TestNFTTransfers(t *testing.T) {
+	repo := repository.NewInMemoryTransactionRepository()
+	ctx := context.Background()
+
+	// the tokens of a batch share the transaction hash and log index
+	first := api.NFTTransfer{Standard: api.StandardERC1155, Contract: "0xc0ffee", TokenID: api.NewBigInt(7), Amount: api.NewBigInt(25), From: "0xabc", To: "0xdef", TransactionHash: "0x123", LogIndex: 4, BatchIndex: 0, BlockHash: "0xb1"}
+	second := api.NFTTransfer{Standard: api.StandardERC1155, Contract: "0xc0ffee", TokenID: api.NewBigInt(8), Amount: api.NewBigInt(1), From: "0xabc", To: "0xdef", TransactionHash: "0x123", LogIndex: 4, BatchIndex: 1, BlockHash: "0xb1"}
+
+	for _, transfer := range []api.NFTTransfer{first, second, second} {
+		if err := repo.SaveNFTTransfer(ctx, "0xabc", transfer); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	transfers, err := repo.GetNFTTransfers(ctx, "0xABC", "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(transfers) != 2 {
+		t.Fatalf("Expected 2 NFT transfers, got %d", len(transfers))
+	}
+
+	if err := repo.UpdateBlockStatus(ctx, "0xb1", api.StatusFinalized); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	transfers, _ = repo.GetNFTTransfers(ctx, "0xabc", "")
+	if transfers[0].Status != api.StatusFinalized || transfers[1].Status != api.StatusFinalized {
+		t.Errorf("Expected the NFT transfers to be finalized, got %+v", transfers)
+	}
+
+	if err := repo.SaveNFTTransfer(ctx, " ", first); err == nil {
+		t.Fatal("Expected error when saving with empty address, got nil")
+	}
+}
+
+func TestWithdrawals(t *testing.T) {
+	repo := repository.NewInMemoryTransactionRepository()
+	ctx := context.Background()
+
+	withdrawal := api.Withdrawal{Index: 1, ValidatorIndex: 100, Address: "0xabc", Amount: api.NewBigInt(18587548), BlockHash: "0xb1"}
+
+	for _, w := range []api.Withdrawal{withdrawal, withdrawal} {
+		if err := repo.SaveWithdrawal(ctx, "0xABC", w); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	withdrawals, err := repo.GetWithdrawals(ctx, "0xabc", "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(withdrawals) != 1 {
+		t.Fatalf("Expected 1 withdrawal, got %d", len(withdrawals))
+	}
+
+	// Test an orphaned withdrawal is replaced when included in another block
+	repo.UpdateBlockStatus(ctx, "0xb1", api.StatusOrphaned)
+
+	withdrawal.BlockHash = "0xb2"
+	repo.SaveWithdrawal(ctx, "0xabc", withdrawal)
+
+	withdrawals, _ = repo.GetWithdrawals(ctx, "0xabc", "")
+	if len(withdrawals) != 1 || withdrawals[0].BlockHash != "0xb2" || withdrawals[0].Status == api.StatusOrphaned {
+		t.Errorf("Expected the orphaned withdrawal to be replaced, got %+v", withdrawals)
+	}
+
+	if err := repo.SaveWithdrawal(ctx, "", withdrawal); err == nil {
+		t.Fatal("Expected error when saving with empty address, got nil")
+	}
+}
+
 func TestSubscribe(t *testing.T) {
 	repo := repository.NewInMemorySubscriberRepository()
 	ctx := context.Background()
@@ -239,6 +471,54 @@ func TestUpdateLastParsedBlock(t *testing.T) {
 	}
 }
 
+func TestFailedBlocks(t *testing.T) {
+	repo := repository.NewInMemoryBlockRepository()
+	ctx := context.Background()
+
+	// Test successful case, blocks are returned ordered by number
+	for _, number := range []int64{30, 10, 20} {
+		if err := repo.AddFailedBlock(ctx, api.FailedBlock{Number: number, LastError: "boom"}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	// Test replacing an existing failed block
+	if err := repo.AddFailedBlock(ctx, api.FailedBlock{Number: 20, Attempts: 3}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	failedBlocks, err := repo.GetFailedBlocks(ctx)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(failedBlocks) != 3 {
+		t.Fatalf("Expected 3 failed blocks, got %d", len(failedBlocks))
+	}
+	for i, number := range []int64{10, 20, 30} {
+		if failedBlocks[i].Number != number {
+			t.Errorf("Expected failed block %d at position %d, got %d", number, i, failedBlocks[i].Number)
+		}
+	}
+	if failedBlocks[1].Attempts != 3 {
+		t.Errorf("Expected failed block 20 to have 3 attempts, got %d", failedBlocks[1].Attempts)
+	}
+
+	// Test removing a failed block
+	if err := repo.RemoveFailedBlock(ctx, 20); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	failedBlocks, _ = repo.GetFailedBlocks(ctx)
+	if len(failedBlocks) != 2 {
+		t.Errorf("Expected 2 failed blocks after removal, got %d", len(failedBlocks))
+	}
+
+	// Test adding a negative block number
+	if err := repo.AddFailedBlock(ctx, api.FailedBlock{Number: -1}); err == nil {
+		t.Fatal("Expected error when adding negative block number, got nil")
+	}
+}
+
 // Helper function to check if a slice of transactions contains a specific transaction
 func containsTransaction(txs []api.Transaction, tx api.Transaction) bool {
 	for _, t := range txs {
@@ -264,7 +544,7 @@ func TestConcurrentSaveTransaction(t *testing.T) {
 				Hash:  fmt.Sprintf("0x%d", i),
 				From:  "0xabc",
 				To:    "0xdef",
-				Value: int64(i),
+				Value: api.NewBigInt(int64(i)),
 			}
 			err := repo.SaveTransaction(ctx, tx.From, tx)
 			if err != nil {
@@ -357,7 +637,7 @@ func TestConcurrentGetTransactions(t *testing.T) {
 			Hash:  fmt.Sprintf("0x%d", i),
 			From:  "0xabc",
 			To:    "0xdef",
-			Value: int64(i),
+			Value: api.NewBigInt(int64(i)),
 		}
 		repo.SaveTransaction(ctx, tx.From, tx)
 	}
@@ -397,7 +677,7 @@ func TestConcurrentSaveAndGetTransactions(t *testing.T) {
 				Hash:  fmt.Sprintf("0x%d", i),
 				From:  "0xabc",
 				To:    "0xdef",
-				Value: int64(i),
+				Value: api.NewBigInt(int64(i)),
 			}
 			err := repo.SaveTransaction(ctx, tx.From, tx)
 			if err != nil {
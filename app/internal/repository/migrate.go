@@ -0,0 +1,234 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// dialect holds what differs between the databases the SQL repositories support
+type dialect struct {
+	name   string
+	driver string
+	// AutoIncrement is the type of an auto-incremented primary key, used by the migrations
+	AutoIncrement string
+	// placeholder returns the n-th bind parameter of a query, starting at 1
+	placeholder func(n int) string
+}
+
+var (
+	dialectSQLite = dialect{
+		name:          "sqlite",
+		driver:        "sqlite",
+		AutoIncrement: "INTEGER PRIMARY KEY AUTOINCREMENT",
+		placeholder:   func(int) string { return "?" },
+	}
+	dialectPostgres = dialect{
+		name:          "postgres",
+		driver:        "postgres",
+		AutoIncrement: "BIGSERIAL PRIMARY KEY",
+		placeholder:   func(n int) string { return "$" + strconv.Itoa(n) },
+	}
+)
+
+// JSONText returns the expression of a field of a JSON column as text, used by the migrations
+func (d dialect) JSONText(column string, path ...string) string {
+	if d.name == dialectPostgres.name {
+		return fmt.Sprintf("(%s::json #>> '{%s}')", column, strings.Join(path, ","))
+	}
+
+	return fmt.Sprintf("json_extract(%s, '$.%s')", column, strings.Join(path, "."))
+}
+
+// PadValue returns the expression of a decimal amount zero-padded to valueDigits, used by the migrations
+func (d dialect) PadValue(expression string) string {
+	if d.name == dialectPostgres.name {
+		return fmt.Sprintf("lpad(coalesce(%s, '0'), %d, '0')", expression, valueDigits)
+	}
+
+	return fmt.Sprintf("substr('%s' || coalesce(%s, '0'), -%d, %d)", strings.Repeat("0", valueDigits), expression, valueDigits, valueDigits)
+}
+
+// UnixTime returns the expression of an RFC 3339 time in seconds since the epoch, used by the migrations
+func (d dialect) UnixTime(expression string) string {
+	if d.name == dialectPostgres.name {
+		return fmt.Sprintf("coalesce(extract(epoch from (%s)::timestamptz)::bigint, 0)", expression)
+	}
+
+	return fmt.Sprintf("coalesce(CAST(strftime('%%s', %s) AS BIGINT), 0)", expression)
+}
+
+// rebind replaces the ? bind parameters of a query with the ones of the dialect
+func (d dialect) rebind(query string) string {
+	var b strings.Builder
+
+	n := 0
+	for _, r := range query {
+		if r != '?' {
+			b.WriteRune(r)
+			continue
+		}
+
+		n++
+		b.WriteString(d.placeholder(n))
+	}
+
+	return b.String()
+}
+
+// migration is a versioned schema change, the files of the migrations directory are named <version>_<name>.sql
+type migration struct {
+	version    int64
+	name       string
+	statements []string
+}
+
+// loadMigrations reads the embedded migrations in version order, rendered for the dialect
+func loadMigrations(d dialect) ([]migration, error) {
+	entries, err := migrations.ReadDir("migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	loaded := make([]migration, 0, len(entries))
+
+	for _, entry := range entries {
+		prefix, name, ok := strings.Cut(strings.TrimSuffix(entry.Name(), ".sql"), "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s is not named <version>_<name>.sql", entry.Name())
+		}
+
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s is not named <version>_<name>.sql", entry.Name())
+		}
+
+		source, err := migrations.ReadFile(path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		tmpl, err := template.New(entry.Name()).Parse(string(source))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse migration %s: %w", entry.Name(), err)
+		}
+
+		var rendered bytes.Buffer
+		if err := tmpl.Execute(&rendered, d); err != nil {
+			return nil, fmt.Errorf("failed to render migration %s: %w", entry.Name(), err)
+		}
+
+		loaded = append(loaded, migration{version: version, name: name, statements: splitStatements(rendered.String())})
+	}
+
+	sort.Slice(loaded, func(i, j int) bool { return loaded[i].version < loaded[j].version })
+
+	return loaded, nil
+}
+
+// splitStatements splits a migration into its statements, which end with a semicolon at the end of a line
+func splitStatements(source string) []string {
+	statements := []string{}
+
+	var current strings.Builder
+
+	for _, line := range strings.Split(source, "\n") {
+		if trimmed := strings.TrimSpace(line); trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+
+		current.WriteString(line)
+		current.WriteString("\n")
+
+		if strings.HasSuffix(strings.TrimSpace(line), ";") {
+			statements = append(statements, strings.TrimSpace(current.String()))
+			current.Reset()
+		}
+	}
+
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+
+	return statements
+}
+
+// migrate applies the migrations that were not applied yet, each in a transaction along with its version
+func migrate(ctx context.Context, db *sql.DB, d dialect) error {
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY,
+		name TEXT NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	applied := map[int64]bool{}
+
+	rows, err := db.QueryContext(ctx, "SELECT version FROM schema_migrations")
+	if err != nil {
+		return fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+
+	for rows.Next() {
+		var version int64
+		if err := rows.Scan(&version); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to read schema_migrations: %w", err)
+		}
+
+		applied[version] = true
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+
+	loaded, err := loadMigrations(d)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range loaded {
+		if applied[m.version] {
+			continue
+		}
+
+		if err := applyMigration(ctx, db, d, m); err != nil {
+			return fmt.Errorf("migration %d_%s: %w", m.version, m.name, err)
+		}
+	}
+
+	return nil
+}
+
+func applyMigration(ctx context.Context, db *sql.DB, d dialect, m migration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, statement := range m.statements {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, d.rebind("INSERT INTO schema_migrations (version, name) VALUES (?, ?)"), m.version, m.name); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,84 @@
+CREATE TABLE subscribers (
+	address TEXT PRIMARY KEY
+);
+
+-- the progress of the parser, such as the last parsed block
+CREATE TABLE parser_state (
+	name TEXT PRIMARY KEY,
+	value BIGINT NOT NULL
+);
+
+CREATE TABLE failed_blocks (
+	number BIGINT PRIMARY KEY,
+	data TEXT NOT NULL
+);
+
+-- the records of subscribed addresses share the same layout: the columns they are looked up by,
+-- their status which moves along with the block, and the record itself in JSON
+CREATE TABLE transactions (
+	id {{.AutoIncrement}},
+	address TEXT NOT NULL,
+	record_key TEXT NOT NULL,
+	hash TEXT NOT NULL,
+	block_hash TEXT NOT NULL,
+	block_number BIGINT NOT NULL,
+	status TEXT NOT NULL,
+	data TEXT NOT NULL,
+	UNIQUE (address, record_key)
+);
+
+CREATE INDEX transactions_address_idx ON transactions (address, id);
+CREATE INDEX transactions_hash_idx ON transactions (hash);
+CREATE INDEX transactions_block_hash_idx ON transactions (block_hash);
+CREATE INDEX transactions_block_number_idx ON transactions (block_number);
+
+CREATE TABLE token_transfers (
+	id {{.AutoIncrement}},
+	address TEXT NOT NULL,
+	record_key TEXT NOT NULL,
+	hash TEXT NOT NULL,
+	block_hash TEXT NOT NULL,
+	block_number BIGINT NOT NULL,
+	status TEXT NOT NULL,
+	data TEXT NOT NULL,
+	UNIQUE (address, record_key)
+);
+
+CREATE INDEX token_transfers_address_idx ON token_transfers (address, id);
+CREATE INDEX token_transfers_hash_idx ON token_transfers (hash);
+CREATE INDEX token_transfers_block_hash_idx ON token_transfers (block_hash);
+CREATE INDEX token_transfers_block_number_idx ON token_transfers (block_number);
+
+CREATE TABLE nft_transfers (
+	id {{.AutoIncrement}},
+	address TEXT NOT NULL,
+	record_key TEXT NOT NULL,
+	hash TEXT NOT NULL,
+	block_hash TEXT NOT NULL,
+	block_number BIGINT NOT NULL,
+	status TEXT NOT NULL,
+	data TEXT NOT NULL,
+	UNIQUE (address, record_key)
+);
+
+CREATE INDEX nft_transfers_address_idx ON nft_transfers (address, id);
+CREATE INDEX nft_transfers_hash_idx ON nft_transfers (hash);
+CREATE INDEX nft_transfers_block_hash_idx ON nft_transfers (block_hash);
+CREATE INDEX nft_transfers_block_number_idx ON nft_transfers (block_number);
+
+-- withdrawals have no transaction, their hash is empty
+CREATE TABLE withdrawals (
+	id {{.AutoIncrement}},
+	address TEXT NOT NULL,
+	record_key TEXT NOT NULL,
+	hash TEXT NOT NULL,
+	block_hash TEXT NOT NULL,
+	block_number BIGINT NOT NULL,
+	status TEXT NOT NULL,
+	data TEXT NOT NULL,
+	UNIQUE (address, record_key)
+);
+
+CREATE INDEX withdrawals_address_idx ON withdrawals (address, id);
+CREATE INDEX withdrawals_block_hash_idx ON withdrawals (block_hash);
+CREATE INDEX withdrawals_block_number_idx ON withdrawals (block_number);
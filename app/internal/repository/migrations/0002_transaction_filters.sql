@@ -0,0 +1,21 @@
+-- the columns the transactions are filtered by, the value is zero-padded to the 78 digits
+-- of the largest uint256 so that comparing it as text compares the amounts
+ALTER TABLE transactions ADD COLUMN from_address TEXT NOT NULL DEFAULT '';
+ALTER TABLE transactions ADD COLUMN to_address TEXT NOT NULL DEFAULT '';
+ALTER TABLE transactions ADD COLUMN value TEXT NOT NULL DEFAULT '';
+ALTER TABLE transactions ADD COLUMN block_time BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE transactions ADD COLUMN transaction_index BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE transactions ADD COLUMN kind TEXT NOT NULL DEFAULT '';
+ALTER TABLE transactions ADD COLUMN failed BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE transactions SET
+	from_address = lower(coalesce({{.JSONText "data" "from"}}, '')),
+	to_address = lower(coalesce({{.JSONText "data" "to"}}, '')),
+	value = {{.PadValue (.JSONText "data" "value")}},
+	block_time = {{.UnixTime (.JSONText "data" "timestamp")}},
+	transaction_index = coalesce(CAST({{.JSONText "data" "transactionIndex"}} AS BIGINT), 0),
+	kind = coalesce({{.JSONText "data" "kind"}}, ''),
+	failed = coalesce({{.JSONText "data" "receipt" "status"}} = 'failed', FALSE);
+
+-- the order of the pages of transactions
+CREATE INDEX transactions_page_idx ON transactions (address, block_number, transaction_index, record_key);
@@ -0,0 +1,3 @@
+-- the subscription in JSON: its label, tags, expiry and when it was created,
+-- the subscriptions made before have none
+ALTER TABLE subscribers ADD COLUMN data TEXT NOT NULL DEFAULT '{}';
@@ -2,7 +2,10 @@ package repository
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
+	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/devshark/tx-parser-go/api"
@@ -12,22 +15,288 @@ var (
 	ErrEmptyAddress  = errors.New("address cannot be empty")
 	ErrNegativeBlock = errors.New("block number cannot be negative")
 	ErrInvalidBlock  = errors.New("block number is not valid")
+	ErrEmptyHash     = errors.New("block hash cannot be empty")
+	ErrInvalidCursor = errors.New("cursor is not valid")
+	ErrInvalidStatus = errors.New("status is not valid")
+	ErrNotSubscribed = errors.New("address is not subscribed")
 )
 
 // Repository interface for data storage
 type BlockRepository interface {
 	GetLastParsedBlock(ctx context.Context) (int64, error)
 	UpdateLastParsedBlock(ctx context.Context, blockNumber int64) error
+	// AddFailedBlock records a block that could not be parsed, replacing any previous record of it
+	AddFailedBlock(ctx context.Context, failed api.FailedBlock) error
+	RemoveFailedBlock(ctx context.Context, blockNumber int64) error
+	GetFailedBlocks(ctx context.Context) ([]api.FailedBlock, error)
 }
 
 type SubscriberRepository interface {
+	// Subscribe subscribes the address without metadata, an existing subscription is left as is
 	Subscribe(ctx context.Context, address string) error
+	// SaveSubscription subscribes the address with the metadata of the subscription, or replaces
+	// the metadata of an existing subscription, which keeps the block and time it was created at
+	SaveSubscription(ctx context.Context, subscription api.Subscription) error
+	// Unsubscribe stops watching the address, the records stored for it are kept
+	Unsubscribe(ctx context.Context, address string) error
+	// IsSubscribed reports whether the address is watched, which it no longer is once its subscription expired
 	IsSubscribed(ctx context.Context, address string) (bool, error)
+	// GetSubscription returns the subscription of the address, even expired, or ErrNotSubscribed if there is none
+	GetSubscription(ctx context.Context, address string) (api.Subscription, error)
+	// GetSubscriptions returns every subscription, expired ones included, ordered by address
+	GetSubscriptions(ctx context.Context) ([]api.Subscription, error)
 }
 
 type TransactionRepository interface {
 	SaveTransaction(ctx context.Context, address string, tx api.Transaction) error
+	// GetTransactions returns the transactions of the address but the orphaned ones,
+	// which QueryTransactions returns when asked for their status
 	GetTransactions(ctx context.Context, address string) ([]api.Transaction, error)
+	// QueryTransactions returns a page of the transactions of an address selected by the query,
+	// ordered by block number, transaction index and key
+	QueryTransactions(ctx context.Context, address string, query api.TransactionQuery) (api.TransactionPage, error)
+	// SaveTokenTransfer stores a token transfer once per transaction hash and log index
+	SaveTokenTransfer(ctx context.Context, address string, transfer api.TokenTransfer) error
+	// GetTokenTransfers returns the token transfers of the address with the given status,
+	// or every one but the orphaned ones if the status is empty, as do the other getters of records
+	GetTokenTransfers(ctx context.Context, address string, status api.TransactionStatus) ([]api.TokenTransfer, error)
+	// SaveNFTTransfer stores an NFT transfer once per transaction hash, log index and batch index
+	SaveNFTTransfer(ctx context.Context, address string, transfer api.NFTTransfer) error
+	GetNFTTransfers(ctx context.Context, address string, status api.TransactionStatus) ([]api.NFTTransfer, error)
+	// SaveWithdrawal stores a withdrawal once per withdrawal index
+	SaveWithdrawal(ctx context.Context, address string, withdrawal api.Withdrawal) error
+	GetWithdrawals(ctx context.Context, address string, status api.TransactionStatus) ([]api.Withdrawal, error)
+	// UpdateBlockStatus sets the status of every record of the given block
+	UpdateBlockStatus(ctx context.Context, blockHash string, status api.TransactionStatus) error
+	// PromoteStatus moves the records at or below the given block number forward to the given status,
+	// confirmed or finalized. Orphaned records and the ones further along are left as is.
+	PromoteStatus(ctx context.Context, blockNumber int64, status api.TransactionStatus) error
+}
+
+// promotedFrom returns the statuses of the records that PromoteStatus moves forward to the given status
+func promotedFrom(status api.TransactionStatus) ([]api.TransactionStatus, error) {
+	switch status {
+	case api.StatusConfirmed:
+		return []api.TransactionStatus{api.StatusPending}, nil
+	case api.StatusFinalized:
+		return []api.TransactionStatus{api.StatusPending, api.StatusConfirmed}, nil
+	default:
+		return nil, fmt.Errorf("%w: cannot promote records to %q", ErrInvalidStatus, status)
+	}
+}
+
+// BlockCommitter stores the records of a block and moves the last parsed block to it as a single unit,
+// so that a crash never leaves a block partly stored. The records go to the transaction repository
+// of the same store. The last parsed block only moves forward, it is left as is when a block behind it
+// is committed again, e.g. after a reorganization.
+type BlockCommitter interface {
+	CommitBlock(ctx context.Context, blockNumber int64, blockHash string, matches BlockMatches) error
+}
+
+// RecordCommitter stores the records of a block as a single unit without moving the last parsed block,
+// for the blocks scanned apart from the parser such as by a backfill. The records go to the transaction
+// repository of the same store.
+type RecordCommitter interface {
+	CommitRecords(ctx context.Context, blockNumber int64, blockHash string, matches BlockMatches) error
+}
+
+// Match is a record to store for a subscribed address
+type Match[T any] struct {
+	Address string `json:"address"`
+	Record  T      `json:"record"`
+}
+
+// BlockMatches are the records of a block to store for subscribed addresses
+type BlockMatches struct {
+	Transactions   []Match[api.Transaction]   `json:"transactions,omitempty"`
+	TokenTransfers []Match[api.TokenTransfer] `json:"tokenTransfers,omitempty"`
+	NFTTransfers   []Match[api.NFTTransfer]   `json:"nftTransfers,omitempty"`
+	Withdrawals    []Match[api.Withdrawal]    `json:"withdrawals,omitempty"`
+}
+
+// clean validates the addresses of the matches and sets the block number and hash of the records missing them,
+// so that every record of the block is found by its hash on a reorganization and by its number as it is finalized
+func (m BlockMatches) clean(blockNumber int64, blockHash string) (BlockMatches, error) {
+	var (
+		cleaned BlockMatches
+		err     error
+	)
+
+	if cleaned.Transactions, err = cleanMatches(m.Transactions, blockNumber, blockHash, func(tx *api.Transaction) (*int64, *string) {
+		return &tx.BlockNumber, &tx.BlockHash
+	}); err != nil {
+		return cleaned, err
+	}
+
+	if cleaned.TokenTransfers, err = cleanMatches(m.TokenTransfers, blockNumber, blockHash, func(t *api.TokenTransfer) (*int64, *string) {
+		return &t.BlockNumber, &t.BlockHash
+	}); err != nil {
+		return cleaned, err
+	}
+
+	if cleaned.NFTTransfers, err = cleanMatches(m.NFTTransfers, blockNumber, blockHash, func(t *api.NFTTransfer) (*int64, *string) {
+		return &t.BlockNumber, &t.BlockHash
+	}); err != nil {
+		return cleaned, err
+	}
+
+	if cleaned.Withdrawals, err = cleanMatches(m.Withdrawals, blockNumber, blockHash, func(w *api.Withdrawal) (*int64, *string) {
+		return &w.BlockNumber, &w.BlockHash
+	}); err != nil {
+		return cleaned, err
+	}
+
+	return cleaned, nil
+}
+
+func cleanMatches[T any](matches []Match[T], blockNumber int64, blockHash string, blockOf func(*T) (*int64, *string)) ([]Match[T], error) {
+	if len(matches) == 0 {
+		return nil, nil
+	}
+
+	cleaned := make([]Match[T], len(matches))
+
+	for i, match := range matches {
+		cleanAddress, err := ValidateAddress(match.Address)
+		if err != nil {
+			return nil, fmt.Errorf("ValidateAddress: %w", err)
+		}
+
+		number, hash := blockOf(&match.Record)
+		if *number == 0 {
+			*number = blockNumber
+		}
+
+		if *hash == "" {
+			*hash = blockHash
+		}
+
+		cleaned[i] = Match[T]{Address: cleanAddress, Record: match.Record}
+	}
+
+	return cleaned, nil
+}
+
+// CommitBlock commits a block with the BlockCommitter of blockRepo if it has one. Otherwise the
+// records are saved one by one before the last parsed block is moved, which is only atomic
+// for repositories that lose everything on a crash anyway, such as the in-memory ones.
+func CommitBlock(ctx context.Context, txRepo TransactionRepository, blockRepo BlockRepository, blockNumber int64, blockHash string, matches BlockMatches) error {
+	if committer, ok := blockRepo.(BlockCommitter); ok {
+		return committer.CommitBlock(ctx, blockNumber, blockHash, matches)
+	}
+
+	if err := saveMatches(ctx, txRepo, blockNumber, blockHash, matches); err != nil {
+		return err
+	}
+
+	// a block behind the last parsed block leaves it as is
+	if err := blockRepo.UpdateLastParsedBlock(ctx, blockNumber); err != nil && !errors.Is(err, ErrInvalidBlock) {
+		return err
+	}
+
+	return nil
+}
+
+// CommitRecords commits the records of a block with the RecordCommitter of blockRepo if it has one,
+// otherwise they are saved one by one. The last parsed block is left as is either way.
+func CommitRecords(ctx context.Context, txRepo TransactionRepository, blockRepo BlockRepository, blockNumber int64, blockHash string, matches BlockMatches) error {
+	if committer, ok := blockRepo.(RecordCommitter); ok {
+		return committer.CommitRecords(ctx, blockNumber, blockHash, matches)
+	}
+
+	return saveMatches(ctx, txRepo, blockNumber, blockHash, matches)
+}
+
+// saveMatches saves the records of a block one by one
+func saveMatches(ctx context.Context, txRepo TransactionRepository, blockNumber int64, blockHash string, matches BlockMatches) error {
+	if _, err := ValidateBlock(ctx, blockNumber); err != nil {
+		return err
+	}
+
+	matches, err := matches.clean(blockNumber, blockHash)
+	if err != nil {
+		return err
+	}
+
+	for _, match := range matches.Transactions {
+		if err := txRepo.SaveTransaction(ctx, match.Address, match.Record); err != nil {
+			return err
+		}
+	}
+
+	for _, match := range matches.TokenTransfers {
+		if err := txRepo.SaveTokenTransfer(ctx, match.Address, match.Record); err != nil {
+			return err
+		}
+	}
+
+	for _, match := range matches.NFTTransfers {
+		if err := txRepo.SaveNFTTransfer(ctx, match.Address, match.Record); err != nil {
+			return err
+		}
+	}
+
+	for _, match := range matches.Withdrawals {
+		if err := txRepo.SaveWithdrawal(ctx, match.Address, match.Record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// cursor is the position of a transaction in the order of QueryTransactions
+type cursor struct {
+	blockNumber      int64
+	transactionIndex uint
+	key              string
+}
+
+func cursorOf(tx api.Transaction) cursor {
+	return cursor{blockNumber: tx.BlockNumber, transactionIndex: tx.TransactionIndex, key: tx.Key()}
+}
+
+// encode returns the cursor as the opaque string handed out to clients
+func (c cursor) encode() string {
+	return base64.RawURLEncoding.EncodeToString(fmt.Appendf(nil, "%d:%d:%s", c.blockNumber, c.transactionIndex, c.key))
+}
+
+func decodeCursor(encoded string) (cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return cursor{}, ErrInvalidCursor
+	}
+
+	// the key comes last since the keys of internal transactions contain a colon
+	parts := strings.SplitN(string(data), ":", 3)
+	if len(parts) != 3 || parts[2] == "" {
+		return cursor{}, ErrInvalidCursor
+	}
+
+	blockNumber, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return cursor{}, ErrInvalidCursor
+	}
+
+	transactionIndex, err := strconv.ParseUint(parts[1], 10, 0)
+	if err != nil {
+		return cursor{}, ErrInvalidCursor
+	}
+
+	return cursor{blockNumber: blockNumber, transactionIndex: uint(transactionIndex), key: parts[2]}, nil
+}
+
+// before reports whether the cursor comes before the other one
+func (c cursor) before(other cursor) bool {
+	if c.blockNumber != other.blockNumber {
+		return c.blockNumber < other.blockNumber
+	}
+
+	if c.transactionIndex != other.transactionIndex {
+		return c.transactionIndex < other.transactionIndex
+	}
+
+	return c.key < other.key
 }
 
 func CleanAddress(address string) string {
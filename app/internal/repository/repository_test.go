@@ -0,0 +1,538 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/devshark/tx-parser-go/api"
+	"github.com/devshark/tx-parser-go/app/internal/repository"
+)
+
+func TestCommitBlock(t *testing.T) {
+	var _ repository.BlockCommitter = &repository.FileBlockRepository{}
+	var _ repository.BlockCommitter = &repository.SQLBlockRepository{}
+	var _ repository.RecordCommitter = &repository.FileBlockRepository{}
+	var _ repository.RecordCommitter = &repository.SQLBlockRepository{}
+
+	ctx := context.Background()
+
+	backends := map[string]func(t *testing.T) (repository.TransactionRepository, repository.BlockRepository){
+		"memory": func(t *testing.T) (repository.TransactionRepository, repository.BlockRepository) {
+			return repository.NewInMemoryTransactionRepository(), repository.NewInMemoryBlockRepository()
+		},
+		"file": func(t *testing.T) (repository.TransactionRepository, repository.BlockRepository) {
+			store, err := repository.NewFileStore(t.TempDir())
+			if err != nil {
+				t.Fatalf("NewFileStore returned unexpected error: %v", err)
+			}
+			t.Cleanup(func() { store.Close() })
+
+			return store.Transactions(), store.Blocks()
+		},
+		"sql": func(t *testing.T) (repository.TransactionRepository, repository.BlockRepository) {
+			store, err := repository.NewSQLStore(ctx, "sqlite://"+filepath.Join(t.TempDir(), "parser.db"))
+			if err != nil {
+				t.Fatalf("NewSQLStore returned unexpected error: %v", err)
+			}
+			t.Cleanup(func() { store.Close() })
+
+			return store.Transactions(), store.Blocks()
+		},
+	}
+
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			txRepo, blockRepo := open(t)
+
+			matches := repository.BlockMatches{
+				Transactions: []repository.Match[api.Transaction]{
+					{Address: "0xABC", Record: api.Transaction{Hash: "0x1", Value: api.NewBigInt(100)}},
+					{Address: "0xdef", Record: api.Transaction{Hash: "0x1", Value: api.NewBigInt(100)}},
+				},
+				TokenTransfers: []repository.Match[api.TokenTransfer]{
+					{Address: "0xabc", Record: api.TokenTransfer{TransactionHash: "0x1", Amount: api.NewBigInt(5)}},
+				},
+				NFTTransfers: []repository.Match[api.NFTTransfer]{
+					{Address: "0xabc", Record: api.NFTTransfer{TransactionHash: "0x1", TokenID: api.NewBigInt(7)}},
+				},
+				Withdrawals: []repository.Match[api.Withdrawal]{
+					{Address: "0xabc", Record: api.Withdrawal{Index: 3, BlockHash: "0xb1", Amount: api.NewBigInt(32)}},
+				},
+			}
+
+			if err := repository.CommitBlock(ctx, txRepo, blockRepo, 10, "0xb1", matches); err != nil {
+				t.Fatalf("CommitBlock returned unexpected error: %v", err)
+			}
+
+			if last, _ := blockRepo.GetLastParsedBlock(ctx); last != 10 {
+				t.Errorf("Expected last parsed block to be 10, got %d", last)
+			}
+
+			// the records are stored for every address, with the hash of their block
+			for _, address := range []string{"0xabc", "0xdef"} {
+				txs, _ := txRepo.GetTransactions(ctx, address)
+				if len(txs) != 1 || txs[0].Hash != "0x1" || txs[0].BlockHash != "0xb1" {
+					t.Errorf("Expected transaction 0x1 of block 0xb1 for %s, got %+v", address, txs)
+				}
+			}
+
+			tokenTransfers, _ := txRepo.GetTokenTransfers(ctx, "0xabc", "")
+			nftTransfers, _ := txRepo.GetNFTTransfers(ctx, "0xabc", "")
+			withdrawals, _ := txRepo.GetWithdrawals(ctx, "0xabc", "")
+
+			if len(tokenTransfers) != 1 || len(nftTransfers) != 1 || len(withdrawals) != 1 {
+				t.Errorf("Expected a token transfer, an NFT transfer and a withdrawal, got %+v, %+v and %+v", tokenTransfers, nftTransfers, withdrawals)
+			}
+
+			// a block behind the last parsed block, e.g. after a reorganization, leaves it as is
+			canonical := repository.BlockMatches{
+				Transactions: []repository.Match[api.Transaction]{{Address: "0xabc", Record: api.Transaction{Hash: "0x2"}}},
+			}
+
+			if err := repository.CommitBlock(ctx, txRepo, blockRepo, 9, "0xa9", canonical); err != nil {
+				t.Fatalf("CommitBlock returned unexpected error: %v", err)
+			}
+
+			if last, _ := blockRepo.GetLastParsedBlock(ctx); last != 10 {
+				t.Errorf("Expected last parsed block to stay 10, got %d", last)
+			}
+
+			if txs, _ := txRepo.GetTransactions(ctx, "0xabc"); len(txs) != 2 || txs[1].BlockHash != "0xa9" {
+				t.Errorf("Expected the transaction of the canonical block to be stored, got %+v", txs)
+			}
+
+			// nothing of an invalid block is stored
+			invalid := repository.BlockMatches{
+				Transactions: []repository.Match[api.Transaction]{
+					{Address: "0xabc", Record: api.Transaction{Hash: "0x3"}},
+					{Address: " ", Record: api.Transaction{Hash: "0x3"}},
+				},
+			}
+
+			if err := repository.CommitBlock(ctx, txRepo, blockRepo, 11, "0xb2", invalid); !errors.Is(err, repository.ErrEmptyAddress) {
+				t.Errorf("Expected ErrEmptyAddress, got %v", err)
+			}
+
+			if err := repository.CommitBlock(ctx, txRepo, blockRepo, -1, "0xb2", repository.BlockMatches{}); !errors.Is(err, repository.ErrNegativeBlock) {
+				t.Errorf("Expected ErrNegativeBlock, got %v", err)
+			}
+
+			if last, _ := blockRepo.GetLastParsedBlock(ctx); last != 10 {
+				t.Errorf("Expected last parsed block to stay 10, got %d", last)
+			}
+
+			if txs, _ := txRepo.GetTransactions(ctx, "0xabc"); len(txs) != 2 {
+				t.Errorf("Expected the transactions of the invalid block not to be stored, got %+v", txs)
+			}
+
+			// the records of a block ahead, e.g. from a backfill, leave the last parsed block as is
+			backfilled := repository.BlockMatches{
+				Transactions: []repository.Match[api.Transaction]{{Address: "0xabc", Record: api.Transaction{Hash: "0x4"}}},
+			}
+
+			if err := repository.CommitRecords(ctx, txRepo, blockRepo, 12, "0xb3", backfilled); err != nil {
+				t.Fatalf("CommitRecords returned unexpected error: %v", err)
+			}
+
+			if last, _ := blockRepo.GetLastParsedBlock(ctx); last != 10 {
+				t.Errorf("Expected last parsed block to stay 10, got %d", last)
+			}
+
+			if txs, _ := txRepo.GetTransactions(ctx, "0xabc"); len(txs) != 3 || txs[2].BlockNumber != 12 || txs[2].BlockHash != "0xb3" {
+				t.Errorf("Expected the backfilled transaction of block 12, got %+v", txs)
+			}
+		})
+	}
+}
+
+func TestQueryTransactions(t *testing.T) {
+	ctx := context.Background()
+
+	backends := map[string]func(t *testing.T) repository.TransactionRepository{
+		"memory": func(t *testing.T) repository.TransactionRepository {
+			return repository.NewInMemoryTransactionRepository()
+		},
+		"file": func(t *testing.T) repository.TransactionRepository {
+			store, err := repository.NewFileStore(t.TempDir())
+			if err != nil {
+				t.Fatalf("NewFileStore returned unexpected error: %v", err)
+			}
+			t.Cleanup(func() { store.Close() })
+
+			return store.Transactions()
+		},
+		"sql": func(t *testing.T) repository.TransactionRepository {
+			store, err := repository.NewSQLStore(ctx, "sqlite://"+filepath.Join(t.TempDir(), "parser.db"))
+			if err != nil {
+				t.Fatalf("NewSQLStore returned unexpected error: %v", err)
+			}
+			t.Cleanup(func() { store.Close() })
+
+			return store.Transactions()
+		},
+	}
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	failed := &api.Receipt{Status: api.ReceiptFailed}
+
+	// saved out of order, the same block holds several transactions
+	txs := []api.Transaction{
+		{Hash: "0x5", BlockNumber: 3, TransactionIndex: 0, Timestamp: start.Add(3 * time.Minute), From: "0xabc", To: "0xdef", Value: api.NewBigInt(500), Status: api.StatusFinalized},
+		{Hash: "0x1", BlockNumber: 1, TransactionIndex: 0, Timestamp: start.Add(time.Minute), From: "0xdef", To: "0xabc", Value: api.NewBigInt(100), Status: api.StatusConfirmed},
+		{Hash: "0x3", BlockNumber: 2, TransactionIndex: 2, Timestamp: start.Add(2 * time.Minute), From: "0x123", To: "0xabc", Value: api.NewBigInt(300), Status: api.StatusPending, Receipt: failed},
+		{Hash: "0x2", BlockNumber: 2, TransactionIndex: 1, Timestamp: start.Add(2 * time.Minute), From: "0xabc", To: "0x123", Value: api.NewBigInt(200), Status: api.StatusPending},
+		{Hash: "0x4", BlockNumber: 2, TransactionIndex: 1, Timestamp: start.Add(2 * time.Minute), From: "0xcontract", To: "0xabc", Value: api.NewBigInt(1000), Status: api.StatusPending, Kind: api.KindInternal, TraceAddress: []int{0}},
+		{Hash: "0x6", BlockNumber: 4, TransactionIndex: 0, Timestamp: start.Add(4 * time.Minute), From: "0xabc", To: "0xdef", Value: api.NewBigInt(600), Status: api.StatusOrphaned},
+	}
+
+	bigInt := func(v int64) *api.BigInt {
+		b := api.NewBigInt(v)
+		return &b
+	}
+
+	tests := []struct {
+		name     string
+		query    api.TransactionQuery
+		expected []string
+	}{
+		{"everything in block order", api.TransactionQuery{}, []string{"0x1", "0x2", "0x4", "0x3", "0x5"}},
+		{"block range", api.TransactionQuery{FromBlock: 2, ToBlock: 2}, []string{"0x2", "0x4", "0x3"}},
+		{"time range", api.TransactionQuery{FromTime: start.Add(2 * time.Minute), ToTime: start.Add(3 * time.Minute)}, []string{"0x2", "0x4", "0x3", "0x5"}},
+		{"received", api.TransactionQuery{Direction: api.DirectionIn}, []string{"0x1", "0x4", "0x3"}},
+		{"sent", api.TransactionQuery{Direction: api.DirectionOut}, []string{"0x2", "0x5"}},
+		{"value range", api.TransactionQuery{MinValue: bigInt(200), MaxValue: bigInt(500)}, []string{"0x2", "0x3", "0x5"}},
+		{"counterparty", api.TransactionQuery{Counterparty: "0x123"}, []string{"0x2", "0x3"}},
+		{"status", api.TransactionQuery{Status: api.StatusPending}, []string{"0x2", "0x4", "0x3"}},
+		{"orphaned", api.TransactionQuery{Status: api.StatusOrphaned}, []string{"0x6"}},
+		{"confirmed", api.TransactionQuery{Confirmed: true}, []string{"0x1", "0x5"}},
+		{"exclude failed", api.TransactionQuery{ExcludeFailed: true}, []string{"0x1", "0x2", "0x4", "0x5"}},
+		{"kind", api.TransactionQuery{Kind: api.KindInternal}, []string{"0x4"}},
+	}
+
+	hashes := func(txs []api.Transaction) []string {
+		result := make([]string, 0, len(txs))
+		for _, tx := range txs {
+			result = append(result, tx.Hash)
+		}
+
+		return result
+	}
+
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			txRepo := open(t)
+
+			for _, tx := range txs {
+				if err := txRepo.SaveTransaction(ctx, "0xabc", tx); err != nil {
+					t.Fatalf("SaveTransaction returned unexpected error: %v", err)
+				}
+			}
+
+			for _, test := range tests {
+				page, err := txRepo.QueryTransactions(ctx, "0xABC", test.query)
+				if err != nil {
+					t.Fatalf("%s: QueryTransactions returned unexpected error: %v", test.name, err)
+				}
+
+				if got := hashes(page.Transactions); !slices.Equal(got, test.expected) || page.NextCursor != "" {
+					t.Errorf("%s: expected %v, got %v with cursor %q", test.name, test.expected, got, page.NextCursor)
+				}
+			}
+
+			// the pages follow each other until the last one
+			var paged []string
+
+			query := api.TransactionQuery{Limit: 2}
+
+			for pages := 0; ; pages++ {
+				if pages > len(txs) {
+					t.Fatalf("Expected the pages to end, got %v so far", paged)
+				}
+
+				page, err := txRepo.QueryTransactions(ctx, "0xabc", query)
+				if err != nil {
+					t.Fatalf("QueryTransactions returned unexpected error: %v", err)
+				}
+
+				if len(page.Transactions) > query.Limit {
+					t.Errorf("Expected at most %d transactions, got %d", query.Limit, len(page.Transactions))
+				}
+
+				paged = append(paged, hashes(page.Transactions)...)
+
+				if page.NextCursor == "" {
+					break
+				}
+
+				query.Cursor = page.NextCursor
+			}
+
+			if expected := tests[0].expected; !slices.Equal(paged, expected) {
+				t.Errorf("Expected the pages to hold %v, got %v", expected, paged)
+			}
+
+			// a page is filtered the same way
+			page, _ := txRepo.QueryTransactions(ctx, "0xabc", api.TransactionQuery{Direction: api.DirectionIn, Limit: 2})
+			if got := hashes(page.Transactions); !slices.Equal(got, []string{"0x1", "0x4"}) || page.NextCursor == "" {
+				t.Errorf("Expected the first page of received transactions with a cursor, got %v with cursor %q", got, page.NextCursor)
+			}
+
+			if page, _ := txRepo.QueryTransactions(ctx, "0xnobody", api.TransactionQuery{}); page.Transactions == nil || len(page.Transactions) != 0 {
+				t.Errorf("Expected an empty page for an unknown address, got %+v", page)
+			}
+
+			if _, err := txRepo.QueryTransactions(ctx, "0xabc", api.TransactionQuery{Cursor: "not a cursor"}); !errors.Is(err, repository.ErrInvalidCursor) {
+				t.Errorf("Expected ErrInvalidCursor, got %v", err)
+			}
+		})
+	}
+}
+
+func TestOrphanedRecords(t *testing.T) {
+	ctx := context.Background()
+
+	backends := map[string]func(t *testing.T) repository.TransactionRepository{
+		"memory": func(t *testing.T) repository.TransactionRepository {
+			return repository.NewInMemoryTransactionRepository()
+		},
+		"file": func(t *testing.T) repository.TransactionRepository {
+			store, err := repository.NewFileStore(t.TempDir())
+			if err != nil {
+				t.Fatalf("NewFileStore returned unexpected error: %v", err)
+			}
+			t.Cleanup(func() { store.Close() })
+
+			return store.Transactions()
+		},
+		"sql": func(t *testing.T) repository.TransactionRepository {
+			store, err := repository.NewSQLStore(ctx, "sqlite://"+filepath.Join(t.TempDir(), "parser.db"))
+			if err != nil {
+				t.Fatalf("NewSQLStore returned unexpected error: %v", err)
+			}
+			t.Cleanup(func() { store.Close() })
+
+			return store.Transactions()
+		},
+	}
+
+	// the records of block 0xb1 are orphaned by a reorganization, those of block 0xb2 are not
+	tests := []struct {
+		name     string
+		status   api.TransactionStatus
+		expected []string
+	}{
+		{"everything but the orphaned records", "", []string{"0xb2"}},
+		{"orphaned", api.StatusOrphaned, []string{"0xb1"}},
+		{"pending", api.StatusPending, []string{"0xb2"}},
+		{"confirmed", api.StatusConfirmed, []string{}},
+	}
+
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			txRepo := open(t)
+
+			for i, blockHash := range []string{"0xb1", "0xb2"} {
+				txHash := fmt.Sprintf("0x%d", i+1)
+
+				txRepo.SaveTransaction(ctx, "0xabc", api.Transaction{Hash: txHash, From: "0xabc", BlockHash: blockHash, BlockNumber: int64(i + 1), Status: api.StatusPending})
+				txRepo.SaveTokenTransfer(ctx, "0xabc", api.TokenTransfer{TransactionHash: txHash, From: "0xabc", Amount: api.NewBigInt(5), BlockHash: blockHash, BlockNumber: int64(i + 1), Status: api.StatusPending})
+				txRepo.SaveNFTTransfer(ctx, "0xabc", api.NFTTransfer{TransactionHash: txHash, From: "0xabc", TokenID: api.NewBigInt(7), BlockHash: blockHash, BlockNumber: int64(i + 1), Status: api.StatusPending})
+				txRepo.SaveWithdrawal(ctx, "0xabc", api.Withdrawal{Index: uint64(i + 1), Address: "0xabc", Amount: api.NewBigInt(32), BlockHash: blockHash, BlockNumber: int64(i + 1), Status: api.StatusPending})
+			}
+
+			if err := txRepo.UpdateBlockStatus(ctx, "0xb1", api.StatusOrphaned); err != nil {
+				t.Fatalf("UpdateBlockStatus returned unexpected error: %v", err)
+			}
+
+			if txs, _ := txRepo.GetTransactions(ctx, "0xabc"); len(txs) != 1 || txs[0].BlockHash != "0xb2" {
+				t.Errorf("Expected only the transaction of block 0xb2, got %+v", txs)
+			}
+
+			for _, test := range tests {
+				tokenTransfers, err := txRepo.GetTokenTransfers(ctx, "0xabc", test.status)
+				if err != nil {
+					t.Fatalf("GetTokenTransfers returned unexpected error: %v", err)
+				}
+
+				nftTransfers, err := txRepo.GetNFTTransfers(ctx, "0xabc", test.status)
+				if err != nil {
+					t.Fatalf("GetNFTTransfers returned unexpected error: %v", err)
+				}
+
+				withdrawals, err := txRepo.GetWithdrawals(ctx, "0xabc", test.status)
+				if err != nil {
+					t.Fatalf("GetWithdrawals returned unexpected error: %v", err)
+				}
+
+				got := map[string][]string{"token transfers": {}, "NFT transfers": {}, "withdrawals": {}}
+				for _, transfer := range tokenTransfers {
+					got["token transfers"] = append(got["token transfers"], transfer.BlockHash)
+				}
+				for _, transfer := range nftTransfers {
+					got["NFT transfers"] = append(got["NFT transfers"], transfer.BlockHash)
+				}
+				for _, withdrawal := range withdrawals {
+					got["withdrawals"] = append(got["withdrawals"], withdrawal.BlockHash)
+				}
+
+				for kind, blockHashes := range got {
+					if !slices.Equal(blockHashes, test.expected) {
+						t.Errorf("%s: expected the %s of blocks %v, got %v", test.name, kind, test.expected, blockHashes)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSubscriptions(t *testing.T) {
+	ctx := context.Background()
+
+	// open opens the store of the backend in the given directory, reopening it restores what was stored
+	backends := map[string]func(t *testing.T, dir string) (repository.SubscriberRepository, func()){
+		"memory": func(t *testing.T, dir string) (repository.SubscriberRepository, func()) {
+			return repository.NewInMemorySubscriberRepository(), func() {}
+		},
+		"file": func(t *testing.T, dir string) (repository.SubscriberRepository, func()) {
+			store, err := repository.NewFileStore(dir)
+			if err != nil {
+				t.Fatalf("NewFileStore returned unexpected error: %v", err)
+			}
+
+			return store.Subscribers(), func() { store.Close() }
+		},
+		"sql": func(t *testing.T, dir string) (repository.SubscriberRepository, func()) {
+			store, err := repository.NewSQLStore(ctx, "sqlite://"+filepath.Join(dir, "parser.db"))
+			if err != nil {
+				t.Fatalf("NewSQLStore returned unexpected error: %v", err)
+			}
+
+			return store.Subscribers(), func() { store.Close() }
+		},
+	}
+
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	expired := time.Now().Add(-time.Minute)
+	later := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			subRepo, closeStore := open(t, dir)
+
+			if err := subRepo.Subscribe(ctx, "0xAAA"); err != nil {
+				t.Fatalf("Subscribe returned unexpected error: %v", err)
+			}
+
+			subscriptions := []api.Subscription{
+				{
+					Address:              "0xBBB",
+					SubscriptionMetadata: api.SubscriptionMetadata{Label: "treasury", Tags: []string{"team", "cold"}, ExpiresAt: &later},
+					CreatedAtBlock:       100,
+					CreatedAt:            created,
+				},
+				{
+					Address:              "0xccc",
+					SubscriptionMetadata: api.SubscriptionMetadata{Label: "old", ExpiresAt: &expired},
+				},
+			}
+
+			for _, subscription := range subscriptions {
+				if err := subRepo.SaveSubscription(ctx, subscription); err != nil {
+					t.Fatalf("SaveSubscription returned unexpected error: %v", err)
+				}
+			}
+
+			// saving again replaces the metadata but keeps when it was created
+			if err := subRepo.SaveSubscription(ctx, api.Subscription{
+				Address:              "0xbbb",
+				SubscriptionMetadata: api.SubscriptionMetadata{Label: "treasury", Tags: []string{"team"}, ExpiresAt: &later},
+				CreatedAtBlock:       200,
+			}); err != nil {
+				t.Fatalf("SaveSubscription returned unexpected error: %v", err)
+			}
+
+			// subscribing again leaves the metadata as is
+			if err := subRepo.Subscribe(ctx, "0xbbb"); err != nil {
+				t.Fatalf("Subscribe returned unexpected error: %v", err)
+			}
+
+			if err := subRepo.SaveSubscription(ctx, api.Subscription{Address: " "}); !errors.Is(err, repository.ErrEmptyAddress) {
+				t.Errorf("Expected ErrEmptyAddress, got %v", err)
+			}
+
+			if err := subRepo.Subscribe(ctx, "0xddd"); err != nil {
+				t.Fatalf("Subscribe returned unexpected error: %v", err)
+			}
+
+			if err := subRepo.Unsubscribe(ctx, "0xDDD"); err != nil {
+				t.Errorf("Unsubscribe returned unexpected error: %v", err)
+			}
+
+			if err := subRepo.Unsubscribe(ctx, "0xddd"); !errors.Is(err, repository.ErrNotSubscribed) {
+				t.Errorf("Expected ErrNotSubscribed, got %v", err)
+			}
+
+			check := func(t *testing.T, subRepo repository.SubscriberRepository) {
+				// an expired subscription is listed but no longer watched
+				for address, expected := range map[string]bool{"0xaaa": true, "0xbbb": true, "0xccc": false, "0xddd": false} {
+					if subscribed, _ := subRepo.IsSubscribed(ctx, address); subscribed != expected {
+						t.Errorf("Expected the subscription to %s to be %t, got %t", address, expected, subscribed)
+					}
+				}
+
+				subscriptions, err := subRepo.GetSubscriptions(ctx)
+				if err != nil {
+					t.Fatalf("GetSubscriptions returned unexpected error: %v", err)
+				}
+
+				if len(subscriptions) != 3 || subscriptions[0].Address != "0xaaa" || subscriptions[2].Address != "0xccc" {
+					t.Fatalf("Expected the subscriptions of 0xaaa, 0xbbb and 0xccc, got %+v", subscriptions)
+				}
+
+				if subscriptions[0].CreatedAt.IsZero() || subscriptions[0].Label != "" {
+					t.Errorf("Expected 0xaaa to be created now without metadata, got %+v", subscriptions[0])
+				}
+
+				treasury := subscriptions[1]
+				if treasury.Label != "treasury" || !slices.Equal(treasury.Tags, []string{"team"}) || treasury.ExpiresAt == nil || !treasury.ExpiresAt.Equal(later) {
+					t.Errorf("Expected the metadata of 0xbbb to be replaced, got %+v", treasury)
+				}
+
+				if treasury.CreatedAtBlock != 100 || !treasury.CreatedAt.Equal(created) {
+					t.Errorf("Expected 0xbbb to be created at block 100 on %v, got %+v", created, treasury)
+				}
+
+				if !subscriptions[2].Expired(time.Now()) {
+					t.Errorf("Expected 0xccc to have expired, got %+v", subscriptions[2])
+				}
+
+				if subscription, err := subRepo.GetSubscription(ctx, "0xCCC"); err != nil || subscription.Label != "old" {
+					t.Errorf("Expected the expired subscription of 0xccc, got %+v, %v", subscription, err)
+				}
+
+				if _, err := subRepo.GetSubscription(ctx, "0xddd"); !errors.Is(err, repository.ErrNotSubscribed) {
+					t.Errorf("Expected ErrNotSubscribed, got %v", err)
+				}
+			}
+
+			check(t, subRepo)
+
+			closeStore()
+
+			if name == "memory" {
+				return
+			}
+
+			reopened, closeStore := open(t, dir)
+			defer closeStore()
+
+			check(t, reopened)
+		})
+	}
+}
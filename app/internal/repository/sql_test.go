@@ -0,0 +1,122 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/devshark/tx-parser-go/api"
+	"github.com/devshark/tx-parser-go/app/internal/repository"
+)
+
+// sqlDatabaseURLs are the databases the SQL tests run against, a Postgres database
+// is tested when TEST_DATABASE_URL is set, the tables it holds are dropped
+func sqlDatabaseURLs(t *testing.T) map[string]string {
+	urls := map[string]string{"sqlite": "sqlite://" + filepath.Join(t.TempDir(), "parser.db")}
+
+	if databaseURL := os.Getenv("TEST_DATABASE_URL"); databaseURL != "" {
+		urls["postgres"] = databaseURL
+	}
+
+	return urls
+}
+
+func TestSQLStore(t *testing.T) {
+	var _ repository.TransactionRepository = &repository.SQLTransactionRepository{}
+	var _ repository.SubscriberRepository = &repository.SQLSubscriberRepository{}
+	var _ repository.BlockRepository = &repository.SQLBlockRepository{}
+
+	ctx := context.Background()
+
+	for name, databaseURL := range sqlDatabaseURLs(t) {
+		t.Run(name, func(t *testing.T) {
+			store, err := repository.NewSQLStore(ctx, databaseURL)
+			if err != nil {
+				t.Fatalf("NewSQLStore returned unexpected error: %v", err)
+			}
+
+			fillStore(t, store.Transactions(), store.Subscribers(), store.Blocks())
+
+			if err := store.Close(); err != nil {
+				t.Fatalf("Close returned unexpected error: %v", err)
+			}
+
+			// the migrations are applied once, the records are there after a restart
+			restored, err := repository.NewSQLStore(ctx, databaseURL)
+			if err != nil {
+				t.Fatalf("NewSQLStore returned unexpected error: %v", err)
+			}
+			defer restored.Close()
+
+			checkStore(t, restored.Transactions(), restored.Subscribers(), restored.Blocks())
+
+			if err := restored.Blocks().UpdateLastParsedBlock(ctx, 41); !errors.Is(err, repository.ErrInvalidBlock) {
+				t.Errorf("Expected ErrInvalidBlock when updating with lower block number, got %v", err)
+			}
+
+			if err := restored.Blocks().UpdateLastParsedBlock(ctx, 42); err != nil {
+				t.Errorf("Expected the same block to be accepted again, got %v", err)
+			}
+
+			if _, err := restored.Transactions().GetTransactions(ctx, " "); !errors.Is(err, repository.ErrEmptyAddress) {
+				t.Errorf("Expected ErrEmptyAddress, got %v", err)
+			}
+		})
+	}
+}
+
+func TestSQLStore_Orphaned(t *testing.T) {
+	ctx := context.Background()
+
+	for name, databaseURL := range sqlDatabaseURLs(t) {
+		t.Run(name, func(t *testing.T) {
+			store, err := repository.NewSQLStore(ctx, databaseURL)
+			if err != nil {
+				t.Fatalf("NewSQLStore returned unexpected error: %v", err)
+			}
+			defer store.Close()
+
+			repo := store.Transactions()
+
+			repo.SaveTransaction(ctx, "0xabc", api.Transaction{Hash: "0x1", BlockHash: "0xA1"})
+			repo.SaveTransaction(ctx, "0xabc", api.Transaction{Hash: "0x2", BlockHash: "0xa2"})
+
+			// saved once per hash
+			repo.SaveTransaction(ctx, "0xabc", api.Transaction{Hash: "0x1", BlockHash: "0xa1"})
+
+			// the block hash is matched regardless of its case
+			if err := repo.UpdateBlockStatus(ctx, "0xa1", api.StatusOrphaned); err != nil {
+				t.Fatalf("UpdateBlockStatus returned unexpected error: %v", err)
+			}
+
+			// the transaction included again in another block replaces its orphaned copy
+			repo.SaveTransaction(ctx, "0xabc", api.Transaction{Hash: "0x1", BlockHash: "0xb1"})
+
+			txs, _ := repo.GetTransactions(ctx, "0xabc")
+			if len(txs) != 2 || txs[0].Hash != "0x2" || txs[1].Hash != "0x1" || txs[1].BlockHash != "0xb1" || txs[1].Status != "" {
+				t.Errorf("Expected 0x2 then 0x1 included again in 0xb1, got %+v", txs)
+			}
+
+			// internal transactions share the hash of their transaction
+			repo.SaveTransaction(ctx, "0xabc", api.Transaction{Hash: "0x2", BlockHash: "0xa2", Kind: api.KindInternal, TraceAddress: []int{0}})
+
+			if txs, _ := repo.GetTransactions(ctx, "0xabc"); len(txs) != 3 {
+				t.Errorf("Expected the internal transaction to be saved along its transaction, got %d transactions", len(txs))
+			}
+
+			if txs, _ := repo.GetTransactions(ctx, "0xother"); txs != nil {
+				t.Errorf("Expected no transactions for another address, got %+v", txs)
+			}
+		})
+	}
+}
+
+func TestNewSQLStore_UnsupportedDatabase(t *testing.T) {
+	for _, databaseURL := range []string{"mysql://localhost/parser", "sqlite://", "parser.db"} {
+		if _, err := repository.NewSQLStore(context.Background(), databaseURL); !errors.Is(err, repository.ErrUnsupportedDatabase) {
+			t.Errorf("Expected ErrUnsupportedDatabase for %q, got %v", databaseURL, err)
+		}
+	}
+}
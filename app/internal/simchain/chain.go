@@ -0,0 +1,567 @@
+// Package simchain simulates an Ethereum chain in memory, to develop and test against
+// without a real node. The Chain mines blocks of synthetic transactions between chosen
+// addresses, can reorganize its head and inject latency and errors, and is served over
+// JSON-RPC by Handler.
+package simchain
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/devshark/tx-parser-go/api"
+	"github.com/devshark/tx-parser-go/app/internal/blockchain"
+)
+
+const (
+	// DefaultChainID is the chain id of the simulated transactions
+	DefaultChainID = 1337
+	// DefaultTransactionsPerBlock is the number of synthetic transactions mined in each block
+	DefaultTransactionsPerBlock = 5
+	// DefaultBlockInterval is the time between the timestamps of consecutive blocks
+	DefaultBlockInterval = 12 * time.Second
+	// DefaultSafeDepth and DefaultFinalizedDepth are the distances from the head of the "safe" and "finalized" blocks
+	DefaultSafeDepth      = 32
+	DefaultFinalizedDepth = 64
+)
+
+// genesisTime is the timestamp of block 0, blocks are spaced by the block interval from it
+var genesisTime = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+
+// Chain is a simulated chain, it implements blockchain.BlockchainClient along with the
+// optional FinalityClient, ReceiptClient and LogClient interfaces
+type Chain struct {
+	mu             sync.RWMutex
+	blocks         []*api.Block // by number, from block 0 to the head
+	receipts       map[string]*api.Receipt
+	pending        []api.Transaction
+	nonces         map[string]uint64
+	forks          int // number of reorgs so far, part of the hashes so that replaced blocks differ
+	rand           *rand.Rand
+	addresses      []string
+	token          string
+	txsPerBlock    int
+	chainID        int64
+	blockInterval  time.Duration
+	safeDepth      int64
+	finalizedDepth int64
+	startBlock     int64
+	faults         faults
+}
+
+// Option customizes the Chain
+type Option func(*Chain)
+
+// WithAddresses sets the addresses the synthetic transactions are sent between
+func WithAddresses(addresses ...string) Option {
+	return func(c *Chain) {
+		c.addresses = nil
+		for _, address := range addresses {
+			if address = strings.ToLower(strings.TrimSpace(address)); address != "" {
+				c.addresses = append(c.addresses, address)
+			}
+		}
+	}
+}
+
+// WithTransactionsPerBlock sets the number of synthetic transactions mined in each block
+func WithTransactionsPerBlock(count int) Option {
+	return func(c *Chain) {
+		if count >= 0 {
+			c.txsPerBlock = count
+		}
+	}
+}
+
+// WithToken makes every other synthetic transaction an ERC-20 transfer of the token at the given address
+func WithToken(contract string) Option {
+	return func(c *Chain) {
+		c.token = strings.ToLower(contract)
+	}
+}
+
+// WithSeed seeds the generation of the synthetic transactions, chains with the same seed and options are the same
+func WithSeed(seed int64) Option {
+	return func(c *Chain) {
+		c.rand = rand.New(rand.NewSource(seed))
+	}
+}
+
+// WithStartBlock mines empty blocks up to the given number, so that the chain does not start at genesis
+func WithStartBlock(number int64) Option {
+	return func(c *Chain) {
+		c.startBlock = max(number, 0)
+	}
+}
+
+// WithBlockInterval sets the time between the timestamps of consecutive blocks
+func WithBlockInterval(interval time.Duration) Option {
+	return func(c *Chain) {
+		if interval > 0 {
+			c.blockInterval = interval
+		}
+	}
+}
+
+// WithFinalityDepths sets the distances from the head of the "safe" and "finalized" blocks
+func WithFinalityDepths(safe, finalized int64) Option {
+	return func(c *Chain) {
+		c.safeDepth = safe
+		c.finalizedDepth = finalized
+	}
+}
+
+// New creates a Chain holding only its genesis block, unless started at another block
+func New(opts ...Option) *Chain {
+	c := &Chain{
+		receipts:       make(map[string]*api.Receipt),
+		nonces:         make(map[string]uint64),
+		rand:           rand.New(rand.NewSource(1)),
+		addresses:      []string{Address("alice"), Address("bob"), Address("carol")},
+		txsPerBlock:    DefaultTransactionsPerBlock,
+		chainID:        DefaultChainID,
+		blockInterval:  DefaultBlockInterval,
+		safeDepth:      DefaultSafeDepth,
+		finalizedDepth: DefaultFinalizedDepth,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	for int64(len(c.blocks)) <= c.startBlock {
+		c.appendBlock(nil, false)
+	}
+
+	return c
+}
+
+// Address derives a deterministic address from a name, for readable tests
+func Address(name string) string {
+	return "0x" + hashOf("address", name)[:40]
+}
+
+// Head returns the number of the latest block
+func (c *Chain) Head() int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return int64(len(c.blocks) - 1)
+}
+
+// Block returns the block with the given number, or nil if it is not mined yet.
+// The block is shared with the chain and must not be modified.
+func (c *Chain) Block(number int64) *api.Block {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if number < 0 || number >= int64(len(c.blocks)) {
+		return nil
+	}
+
+	return c.blocks[number]
+}
+
+// AddTransaction queues a transaction to be mined in the next block, along with the synthetic ones.
+// Only From, To, Value and Input are kept, the rest is filled in when it is mined.
+func (c *Chain) AddTransaction(tx api.Transaction) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.pending = append(c.pending, api.Transaction{
+		From:  strings.ToLower(tx.From),
+		To:    strings.ToLower(tx.To),
+		Value: tx.Value,
+		Input: tx.Input,
+	})
+}
+
+// Mine mines count blocks at once, the head jumps by count as seen from a client
+func (c *Chain) Mine(count int) []*api.Block {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	mined := make([]*api.Block, 0, count)
+	for range count {
+		pending := c.pending
+		c.pending = nil
+
+		mined = append(mined, c.appendBlock(pending, true))
+	}
+
+	return mined
+}
+
+// Reorg replaces the last depth blocks with as many new ones, on a fork with different hashes
+// and transactions, and returns the new blocks. Genesis cannot be replaced.
+func (c *Chain) Reorg(depth int) ([]*api.Block, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if depth < 1 || depth >= len(c.blocks) {
+		return nil, fmt.Errorf("cannot reorg %d blocks of a chain of %d blocks", depth, len(c.blocks))
+	}
+
+	for _, block := range c.blocks[len(c.blocks)-depth:] {
+		for _, tx := range block.Transactions {
+			delete(c.receipts, strings.ToLower(tx.Hash))
+		}
+	}
+
+	c.blocks = c.blocks[:len(c.blocks)-depth]
+	c.forks++
+
+	mined := make([]*api.Block, 0, depth)
+	for range depth {
+		mined = append(mined, c.appendBlock(nil, true))
+	}
+
+	return mined, nil
+}
+
+// appendBlock mines the next block with the given transactions, along with synthetic ones if
+// synthetic is set, c.mu must be held
+func (c *Chain) appendBlock(pending []api.Transaction, synthetic bool) *api.Block {
+	number := int64(len(c.blocks))
+
+	block := &api.Block{
+		Number: number,
+		Hash:   "0x" + hashOf("block", number, c.forks),
+		Nonce:  "0x0000000000000000",
+		// in local time, as the clients of a node parse them
+		Timestamp: time.Unix(genesisTime+number*int64(c.blockInterval/time.Second), 0),
+	}
+
+	if number > 0 {
+		block.ParentHash = c.blocks[number-1].Hash
+	} else {
+		block.ParentHash = "0x" + strings.Repeat("0", 64)
+	}
+
+	txs := pending
+	if synthetic {
+		txs = append(txs, c.syntheticTransactions()...)
+	}
+
+	block.Transactions = make([]api.Transaction, 0, len(txs))
+	for i, tx := range txs {
+		block.Transactions = append(block.Transactions, c.mineTransaction(block, uint(i), tx))
+	}
+
+	c.blocks = append(c.blocks, block)
+
+	return block
+}
+
+// syntheticTransactions generates the transactions of a block between the chosen addresses
+func (c *Chain) syntheticTransactions() []api.Transaction {
+	if len(c.addresses) < 2 {
+		return nil
+	}
+
+	txs := make([]api.Transaction, 0, c.txsPerBlock)
+	for i := range c.txsPerBlock {
+		from := c.addresses[c.rand.Intn(len(c.addresses))]
+		to := c.addresses[c.rand.Intn(len(c.addresses)-1)]
+		if to == from {
+			to = c.addresses[len(c.addresses)-1]
+		}
+
+		// up to 10 ether, in gwei so that the values stay readable
+		amount := new(big.Int).Mul(big.NewInt(c.rand.Int63n(10_000_000_000)+1), big.NewInt(1_000_000_000))
+
+		if c.token != "" && i%2 == 1 {
+			txs = append(txs, api.Transaction{From: from, To: c.token, Value: api.NewBigInt(0), Input: transferInput(to, amount)})
+			continue
+		}
+
+		txs = append(txs, api.Transaction{From: from, To: to, Value: api.BigIntFromBig(amount), Input: "0x"})
+	}
+
+	return txs
+}
+
+// mineTransaction fills in a transaction included in the block at the given index, and records its receipt
+func (c *Chain) mineTransaction(block *api.Block, index uint, tx api.Transaction) api.Transaction {
+	gasPrice := api.NewBigInt(1_000_000_000)
+
+	tx.Hash = "0x" + hashOf("tx", block.Hash, index)
+	tx.Kind = api.KindExternal
+	tx.Type = api.TxTypeLegacy
+	tx.ChainID = c.chainID
+	tx.Nonce = fmt.Sprintf("0x%x", c.nonces[tx.From])
+	tx.Gas = 21000
+	tx.GasPrice = gasPrice
+	tx.BlockHash = block.Hash
+	tx.BlockNumber = block.Number
+	tx.Timestamp = block.Timestamp
+	tx.TransactionIndex = index
+	tx.ContractCreation = tx.To == ""
+
+	if tx.Input == "" {
+		tx.Input = "0x"
+	}
+
+	c.nonces[tx.From]++
+
+	receipt := &api.Receipt{
+		TransactionHash:   tx.Hash,
+		Status:            api.ReceiptSuccess,
+		GasUsed:           tx.Gas,
+		EffectiveGasPrice: gasPrice,
+		Fee:               api.BigIntFromBig(new(big.Int).Mul(big.NewInt(int64(tx.Gas)), gasPrice.Big())),
+		Logs:              []api.Log{},
+	}
+
+	if tx.ContractCreation {
+		receipt.ContractAddress = "0x" + hashOf("contract", tx.From, tx.Nonce)[:40]
+	}
+
+	if to, amount, ok := decodeTransferInput(tx.Input); ok {
+		// the log index counts the logs of the block, the transactions before this one are already mined
+		var logIndex uint
+		for _, previous := range block.Transactions {
+			logIndex += uint(len(c.receipts[strings.ToLower(previous.Hash)].Logs))
+		}
+
+		receipt.Logs = append(receipt.Logs, api.Log{
+			Address:         tx.To,
+			Topics:          []string{blockchain.TransferEventTopic, blockchain.AddressTopic(tx.From), blockchain.AddressTopic(to)},
+			Data:            fmt.Sprintf("0x%064x", amount),
+			LogIndex:        logIndex,
+			TransactionHash: tx.Hash,
+			BlockHash:       block.Hash,
+			BlockNumber:     block.Number,
+		})
+	}
+
+	c.receipts[strings.ToLower(tx.Hash)] = receipt
+
+	return tx
+}
+
+// GetLatestBlockNumber returns the number of the head
+func (c *Chain) GetLatestBlockNumber(ctx context.Context) (int64, error) {
+	if err := c.faults.inject(ctx, MethodBlockNumber); err != nil {
+		return 0, err
+	}
+
+	return c.Head(), nil
+}
+
+// GetBlockByNumber returns a copy of the block with the given number,
+// blocks that are not mined yet are not available
+func (c *Chain) GetBlockByNumber(ctx context.Context, number int64) (*api.Block, error) {
+	if err := c.faults.inject(ctx, MethodGetBlockByNumber); err != nil {
+		return nil, err
+	}
+
+	block := c.Block(number)
+	if block == nil {
+		return nil, fmt.Errorf("%w: block %d", blockchain.ErrBlockNotAvailable, number)
+	}
+
+	return copyBlock(block), nil
+}
+
+// copyBlock copies a block along with its transactions, so that callers can modify it
+func copyBlock(block *api.Block) *api.Block {
+	copied := *block
+	copied.Transactions = append([]api.Transaction(nil), block.Transactions...)
+
+	return &copied
+}
+
+// GetBlocksByRange returns copies of the blocks from..to, those that are not mined yet are reported through a *blockchain.BatchError
+func (c *Chain) GetBlocksByRange(ctx context.Context, from, to int64) ([]*api.Block, error) {
+	if err := c.faults.inject(ctx, MethodGetBlockByNumber); err != nil {
+		return nil, err
+	}
+
+	blocks := make([]*api.Block, 0, to-from+1)
+	failed := &blockchain.BatchError{Errors: map[int64]error{}}
+
+	for number := from; number <= to; number++ {
+		block := c.Block(number)
+		if block == nil {
+			failed.Errors[number] = fmt.Errorf("%w: block %d", blockchain.ErrBlockNotAvailable, number)
+			blocks = append(blocks, nil)
+
+			continue
+		}
+
+		blocks = append(blocks, copyBlock(block))
+	}
+
+	if len(failed.Errors) > 0 {
+		return blocks, failed
+	}
+
+	return blocks, nil
+}
+
+// GetBlockNumberByTag returns the number of the "latest", "safe" or "finalized" block
+func (c *Chain) GetBlockNumberByTag(ctx context.Context, tag string) (int64, error) {
+	if err := c.faults.inject(ctx, MethodGetBlockByNumber); err != nil {
+		return 0, err
+	}
+
+	return c.tagNumber(tag)
+}
+
+func (c *Chain) tagNumber(tag string) (int64, error) {
+	head := c.Head()
+
+	switch tag {
+	case blockchain.TagLatest, "pending":
+		return head, nil
+	case blockchain.TagSafe:
+		return max(head-c.safeDepth, 0), nil
+	case blockchain.TagFinalized:
+		return max(head-c.finalizedDepth, 0), nil
+	case "earliest":
+		return 0, nil
+	default:
+		return 0, fmt.Errorf("unknown block tag %q", tag)
+	}
+}
+
+// GetBlockReceipts returns the receipts of the transactions of a block, in their order
+func (c *Chain) GetBlockReceipts(ctx context.Context, number int64) ([]*api.Receipt, error) {
+	if err := c.faults.inject(ctx, MethodGetBlockReceipts); err != nil {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if number < 0 || number >= int64(len(c.blocks)) {
+		return nil, fmt.Errorf("%w: receipts of block %d", blockchain.ErrBlockNotAvailable, number)
+	}
+
+	receipts := make([]*api.Receipt, 0, len(c.blocks[number].Transactions))
+	for _, tx := range c.blocks[number].Transactions {
+		receipts = append(receipts, c.receipts[strings.ToLower(tx.Hash)])
+	}
+
+	return receipts, nil
+}
+
+// GetTransactionReceipt returns the receipt of a transaction of the canonical chain
+func (c *Chain) GetTransactionReceipt(ctx context.Context, hash string) (*api.Receipt, error) {
+	if err := c.faults.inject(ctx, MethodGetTransactionReceipt); err != nil {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	receipt, ok := c.receipts[strings.ToLower(hash)]
+	if !ok {
+		return nil, fmt.Errorf("%w: receipt of transaction %s", blockchain.ErrBlockNotAvailable, hash)
+	}
+
+	return receipt, nil
+}
+
+// GetLogs returns the logs of the canonical chain matching the filter
+func (c *Chain) GetLogs(ctx context.Context, filter blockchain.LogFilter) ([]api.Log, error) {
+	if err := c.faults.inject(ctx, MethodGetLogs); err != nil {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	from, to := filter.FromBlock, min(filter.ToBlock, int64(len(c.blocks)-1))
+	if filter.BlockHash != "" {
+		from, to = 0, -1
+
+		for _, block := range c.blocks {
+			if strings.EqualFold(block.Hash, filter.BlockHash) {
+				from, to = block.Number, block.Number
+				break
+			}
+		}
+
+		if to < 0 {
+			return nil, fmt.Errorf("%w: block %s", blockchain.ErrBlockNotAvailable, filter.BlockHash)
+		}
+	}
+
+	logs := []api.Log{}
+
+	for number := max(from, 0); number <= to; number++ {
+		for _, tx := range c.blocks[number].Transactions {
+			for _, l := range c.receipts[strings.ToLower(tx.Hash)].Logs {
+				if matchesFilter(l, filter) {
+					logs = append(logs, l)
+				}
+			}
+		}
+	}
+
+	return logs, nil
+}
+
+func matchesFilter(l api.Log, filter blockchain.LogFilter) bool {
+	if len(filter.Addresses) > 0 && !containsFold(filter.Addresses, l.Address) {
+		return false
+	}
+
+	for i, values := range filter.Topics {
+		if len(values) == 0 {
+			continue
+		}
+
+		if i >= len(l.Topics) || !containsFold(values, l.Topics[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// transferInput encodes the call of transfer(address,uint256)
+func transferInput(to string, amount *big.Int) string {
+	return fmt.Sprintf("0xa9059cbb%s%064x", strings.TrimPrefix(blockchain.AddressTopic(to), "0x"), amount)
+}
+
+func decodeTransferInput(input string) (string, *big.Int, bool) {
+	data, ok := strings.CutPrefix(input, "0xa9059cbb")
+	if !ok || len(data) != 128 {
+		return "", nil, false
+	}
+
+	to, ok := blockchain.TopicAddress(data[:64])
+	if !ok {
+		return "", nil, false
+	}
+
+	amount, ok := new(big.Int).SetString(data[64:], 16)
+
+	return to, amount, ok
+}
+
+// hashOf derives a deterministic 32 bytes hash from the given values
+func hashOf(values ...any) string {
+	sum := sha256.Sum256([]byte(fmt.Sprint(values...)))
+
+	return hex.EncodeToString(sum[:])
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+
+	return false
+}
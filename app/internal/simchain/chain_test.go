@@ -0,0 +1,200 @@
+package simchain_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/devshark/tx-parser-go/api"
+	"github.com/devshark/tx-parser-go/app/internal/blockchain"
+	"github.com/devshark/tx-parser-go/app/internal/simchain"
+)
+
+func TestChain_Mine(t *testing.T) {
+	alice, bob := simchain.Address("alice"), simchain.Address("bob")
+
+	chain := simchain.New(
+		simchain.WithAddresses(alice, bob),
+		simchain.WithTransactionsPerBlock(3),
+		simchain.WithStartBlock(10),
+	)
+
+	if head := chain.Head(); head != 10 {
+		t.Fatalf("Expected the chain to start at block 10, got %d", head)
+	}
+
+	if txs := chain.Block(10).Transactions; len(txs) != 0 {
+		t.Errorf("Expected the start block to be empty, got %d transactions", len(txs))
+	}
+
+	mined := chain.Mine(2)
+	if len(mined) != 2 || chain.Head() != 12 {
+		t.Fatalf("Expected 2 blocks to be mined up to block 12, got %d up to block %d", len(mined), chain.Head())
+	}
+
+	for _, block := range mined {
+		if parent := chain.Block(block.Number - 1); block.ParentHash != parent.Hash {
+			t.Errorf("Expected block %d to extend %s, got %s", block.Number, parent.Hash, block.ParentHash)
+		}
+
+		if len(block.Transactions) != 3 {
+			t.Errorf("Expected 3 transactions in block %d, got %d", block.Number, len(block.Transactions))
+		}
+
+		for i, tx := range block.Transactions {
+			if tx.From == tx.To || (tx.From != alice && tx.From != bob) || (tx.To != alice && tx.To != bob) {
+				t.Errorf("Expected transaction %d of block %d between alice and bob, got %s to %s", i, block.Number, tx.From, tx.To)
+			}
+
+			if tx.BlockHash != block.Hash || tx.TransactionIndex != uint(i) || tx.Value.Sign() <= 0 {
+				t.Errorf("Unexpected transaction %d of block %d: %+v", i, block.Number, tx)
+			}
+		}
+	}
+
+	// chains with the same options are the same
+	same := simchain.New(
+		simchain.WithAddresses(alice, bob),
+		simchain.WithTransactionsPerBlock(3),
+		simchain.WithStartBlock(10),
+	)
+	same.Mine(2)
+
+	if !reflect.DeepEqual(same.Block(12), chain.Block(12)) {
+		t.Errorf("Expected the chains to be the same, got %+v and %+v", same.Block(12), chain.Block(12))
+	}
+}
+
+func TestChain_AddTransaction(t *testing.T) {
+	chain := simchain.New(simchain.WithTransactionsPerBlock(0))
+
+	chain.AddTransaction(api.Transaction{From: simchain.Address("alice"), To: ""})
+	block := chain.Mine(1)[0]
+
+	if len(block.Transactions) != 1 || !block.Transactions[0].ContractCreation {
+		t.Fatalf("Expected a single contract deployment, got %+v", block.Transactions)
+	}
+
+	receipt, err := chain.GetTransactionReceipt(context.Background(), block.Transactions[0].Hash)
+	if err != nil {
+		t.Fatalf("GetTransactionReceipt returned unexpected error: %v", err)
+	}
+
+	if !receipt.Succeeded() || receipt.ContractAddress == "" {
+		t.Errorf("Expected a successful deployment with a contract address, got %+v", receipt)
+	}
+
+	if txs := chain.Mine(1)[0].Transactions; len(txs) != 0 {
+		t.Errorf("Expected the added transaction to be mined once, got %d transactions in the next block", len(txs))
+	}
+}
+
+func TestChain_Reorg(t *testing.T) {
+	ctx := context.Background()
+
+	chain := simchain.New()
+	replaced := chain.Mine(3)
+
+	fork, err := chain.Reorg(2)
+	if err != nil {
+		t.Fatalf("Reorg returned unexpected error: %v", err)
+	}
+
+	if chain.Head() != 3 || len(fork) != 2 {
+		t.Fatalf("Expected 2 blocks replaced up to block 3, got %d up to block %d", len(fork), chain.Head())
+	}
+
+	if fork[0].ParentHash != replaced[0].Hash {
+		t.Errorf("Expected the fork to extend block 1 %s, got %s", replaced[0].Hash, fork[0].ParentHash)
+	}
+
+	for i, block := range fork {
+		if block.Hash == replaced[i+1].Hash {
+			t.Errorf("Expected block %d to be replaced, got the same hash %s", block.Number, block.Hash)
+		}
+	}
+
+	// the transactions of the replaced blocks are gone
+	if _, err := chain.GetTransactionReceipt(ctx, replaced[2].Transactions[0].Hash); !errors.Is(err, blockchain.ErrBlockNotAvailable) {
+		t.Errorf("Expected the receipt of a replaced transaction to be unavailable, got %v", err)
+	}
+
+	if _, err := chain.Reorg(4); err == nil {
+		t.Error("Expected genesis not to be replaced")
+	}
+}
+
+func TestChain_Faults(t *testing.T) {
+	ctx := context.Background()
+
+	chain := simchain.New()
+	chain.Mine(1)
+
+	chain.FailNext(simchain.MethodGetBlockByNumber, 2, blockchain.ErrBlockNotAvailable)
+
+	if _, err := chain.GetLatestBlockNumber(ctx); err != nil {
+		t.Errorf("Expected the failures to target GetBlockByNumber only, got %v", err)
+	}
+
+	for range 2 {
+		if _, err := chain.GetBlockByNumber(ctx, 1); !errors.Is(err, blockchain.ErrBlockNotAvailable) {
+			t.Errorf("Expected the block not to be available, got %v", err)
+		}
+	}
+
+	if _, err := chain.GetBlockByNumber(ctx, 1); err != nil {
+		t.Errorf("Expected the block to be available after the failures, got %v", err)
+	}
+
+	chain.SetErrorRate(1)
+
+	if _, err := chain.GetLatestBlockNumber(ctx); !errors.Is(err, blockchain.ErrServerError) {
+		t.Errorf("Expected a server error, got %v", err)
+	}
+
+	chain.SetErrorRate(0)
+	chain.SetLatency(time.Second)
+
+	timeout, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+
+	if _, err := chain.GetLatestBlockNumber(timeout); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the slow call to time out, got %v", err)
+	}
+}
+
+func TestChain_Logs(t *testing.T) {
+	ctx := context.Background()
+	token := simchain.Address("token")
+
+	chain := simchain.New(simchain.WithToken(token), simchain.WithTransactionsPerBlock(4))
+	block := chain.Mine(1)[0]
+
+	logs, err := chain.GetLogs(ctx, blockchain.LogFilter{BlockHash: block.Hash, Topics: [][]string{{blockchain.TransferEventTopic}}})
+	if err != nil {
+		t.Fatalf("GetLogs returned unexpected error: %v", err)
+	}
+
+	// every other transaction is a token transfer
+	if len(logs) != 2 {
+		t.Fatalf("Expected 2 transfer logs, got %d", len(logs))
+	}
+
+	for i, l := range logs {
+		transfer, ok := blockchain.DecodeTokenTransfer(l)
+		if !ok {
+			t.Fatalf("Expected log %d to be a token transfer, got %+v", i, l)
+		}
+
+		tx := block.Transactions[2*i+1]
+		if transfer.Contract != token || transfer.From != tx.From || transfer.TransactionHash != tx.Hash || transfer.LogIndex != uint(i) {
+			t.Errorf("Unexpected transfer %d: %+v", i, transfer)
+		}
+	}
+
+	if logs, _ := chain.GetLogs(ctx, blockchain.LogFilter{FromBlock: 0, ToBlock: 1, Addresses: []string{simchain.Address("other")}}); len(logs) != 0 {
+		t.Errorf("Expected no logs of another contract, got %d", len(logs))
+	}
+}
@@ -0,0 +1,109 @@
+package simchain
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/devshark/tx-parser-go/app/internal/blockchain"
+)
+
+// JSON-RPC methods served by the chain, to target the faults
+const (
+	MethodBlockNumber           = "eth_blockNumber"
+	MethodGetBlockByNumber      = "eth_getBlockByNumber"
+	MethodGetBlockReceipts      = "eth_getBlockReceipts"
+	MethodGetTransactionReceipt = "eth_getTransactionReceipt"
+	MethodGetLogs               = "eth_getLogs"
+	// AnyMethod targets every method
+	AnyMethod = ""
+)
+
+// faults are the latency and errors injected in the calls made to the chain
+type faults struct {
+	mu        sync.Mutex
+	latency   time.Duration
+	errorRate float64
+	rand      *rand.Rand
+	failNext  []failure
+}
+
+// failure makes the next count calls of a method fail with err
+type failure struct {
+	method string
+	count  int
+	err    error
+}
+
+// SetLatency delays every call by the given duration, to simulate a slow node
+func (c *Chain) SetLatency(latency time.Duration) {
+	c.faults.mu.Lock()
+	defer c.faults.mu.Unlock()
+
+	c.faults.latency = latency
+}
+
+// SetErrorRate makes the given share of the calls, between 0 and 1, fail with a blockchain.ErrServerError
+func (c *Chain) SetErrorRate(rate float64) {
+	c.faults.mu.Lock()
+	defer c.faults.mu.Unlock()
+
+	c.faults.errorRate = rate
+	if c.faults.rand == nil {
+		c.faults.rand = rand.New(rand.NewSource(1))
+	}
+}
+
+// FailNext makes the next count calls of a method, or of any method, fail with err.
+// Wrap the errors of the blockchain package, such as blockchain.ErrRateLimited,
+// so that they are served with the matching JSON-RPC error.
+func (c *Chain) FailNext(method string, count int, err error) {
+	c.faults.mu.Lock()
+	defer c.faults.mu.Unlock()
+
+	c.faults.failNext = append(c.faults.failNext, failure{method: method, count: count, err: err})
+}
+
+// inject waits for the latency and returns the error the call should fail with, if any
+func (f *faults) inject(ctx context.Context, method string) error {
+	f.mu.Lock()
+	latency := f.latency
+	err := f.nextError(method)
+	f.mu.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(latency):
+		}
+	}
+
+	return err
+}
+
+// nextError consumes the first failure targeting the method, f.mu must be held
+func (f *faults) nextError(method string) error {
+	for i := range f.failNext {
+		failure := &f.failNext[i]
+		if failure.method != AnyMethod && failure.method != method {
+			continue
+		}
+
+		err := failure.err
+
+		if failure.count--; failure.count <= 0 {
+			f.failNext = append(f.failNext[:i], f.failNext[i+1:]...)
+		}
+
+		return err
+	}
+
+	if f.errorRate > 0 && f.rand.Float64() < f.errorRate {
+		return fmt.Errorf("%w: simulated failure of %s", blockchain.ErrServerError, method)
+	}
+
+	return nil
+}
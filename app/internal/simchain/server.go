@@ -0,0 +1,467 @@
+package simchain
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/devshark/tx-parser-go/api"
+	"github.com/devshark/tx-parser-go/app/internal/blockchain"
+)
+
+// JSON-RPC error codes, as answered by geth
+const (
+	codeParseError     = -32700
+	codeInvalidParams  = -32602
+	codeMethodNotFound = -32601
+	codeInternalError  = -32603
+	codeServerError    = -32000
+	codeLimitExceeded  = -32005
+)
+
+type rpcRequest struct {
+	JSONRPC string            `json:"jsonrpc"`
+	ID      json.RawMessage   `json:"id"`
+	Method  string            `json:"method"`
+	Params  []json.RawMessage `json:"params"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// MarshalJSON answers null results explicitly, as nodes do for the blocks they do not have
+func (r rpcResponse) MarshalJSON() ([]byte, error) {
+	type response rpcResponse
+
+	if r.Error != nil || r.Result != nil {
+		return json.Marshal(response(r))
+	}
+
+	return json.Marshal(struct {
+		JSONRPC string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id"`
+		Result  any             `json:"result"`
+	}{r.JSONRPC, r.ID, nil})
+}
+
+// Handler serves the chain over JSON-RPC, as an Ethereum node would, single requests and batches alike.
+// The injected faults apply to each request, a rate limited single request is answered with 429.
+func (c *Chain) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "failed to read request", http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		body = bytes.TrimSpace(body)
+
+		if len(body) > 0 && body[0] == '[' {
+			var requests []rpcRequest
+			if err := json.Unmarshal(body, &requests); err != nil {
+				writeJSON(w, http.StatusOK, rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{Code: codeParseError, Message: err.Error()}})
+				return
+			}
+
+			responses := make([]rpcResponse, 0, len(requests))
+			for _, request := range requests {
+				responses = append(responses, c.serve(r.Context(), request))
+			}
+
+			writeJSON(w, http.StatusOK, responses)
+
+			return
+		}
+
+		var request rpcRequest
+		if err := json.Unmarshal(body, &request); err != nil {
+			writeJSON(w, http.StatusOK, rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{Code: codeParseError, Message: err.Error()}})
+			return
+		}
+
+		response := c.serve(r.Context(), request)
+
+		status := http.StatusOK
+		if response.Error != nil && response.Error.Code == codeLimitExceeded {
+			status = http.StatusTooManyRequests
+		}
+
+		writeJSON(w, status, response)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// serve answers a single request
+func (c *Chain) serve(ctx context.Context, request rpcRequest) rpcResponse {
+	response := rpcResponse{JSONRPC: "2.0", ID: request.ID}
+
+	result, err := c.dispatch(ctx, request.Method, request.Params)
+	if err != nil {
+		response.Error = toRPCError(err)
+		return response
+	}
+
+	response.Result = result
+
+	return response
+}
+
+// invalidParamsError is answered with codeInvalidParams
+type invalidParamsError struct {
+	err error
+}
+
+func (e invalidParamsError) Error() string {
+	return e.err.Error()
+}
+
+func (c *Chain) dispatch(ctx context.Context, method string, params []json.RawMessage) (any, error) {
+	switch method {
+	case MethodBlockNumber:
+		number, err := c.GetLatestBlockNumber(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return hexInt(number), nil
+
+	case MethodGetBlockByNumber:
+		number, err := c.blockParam(params)
+		if err != nil {
+			return nil, err
+		}
+
+		block, err := c.GetBlockByNumber(ctx, number)
+		if errors.Is(err, blockchain.ErrBlockNotAvailable) {
+			return nil, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		return encodeBlock(block), nil
+
+	case MethodGetBlockReceipts:
+		number, err := c.blockParam(params)
+		if err != nil {
+			return nil, err
+		}
+
+		receipts, err := c.GetBlockReceipts(ctx, number)
+		if errors.Is(err, blockchain.ErrBlockNotAvailable) {
+			return nil, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		// the block may have been replaced since, answer as if it was not available yet
+		block := c.Block(number)
+		if block == nil || len(block.Transactions) != len(receipts) {
+			return nil, nil
+		}
+
+		encoded := make([]blockchain.EthReceipt, 0, len(receipts))
+		for i, receipt := range receipts {
+			encoded = append(encoded, encodeReceipt(block.Transactions[i], receipt))
+		}
+
+		return encoded, nil
+
+	case MethodGetTransactionReceipt:
+		var hash string
+		if err := decodeParam(params, 0, &hash); err != nil {
+			return nil, err
+		}
+
+		receipt, err := c.GetTransactionReceipt(ctx, hash)
+		if errors.Is(err, blockchain.ErrBlockNotAvailable) {
+			return nil, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		tx, _ := c.transaction(hash)
+
+		return encodeReceipt(tx, receipt), nil
+
+	case MethodGetLogs:
+		filter, err := c.logFilterParam(params)
+		if err != nil {
+			return nil, err
+		}
+
+		logs, err := c.GetLogs(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+
+		encoded := make([]blockchain.EthLog, 0, len(logs))
+		for _, l := range logs {
+			encoded = append(encoded, c.encodeLog(l))
+		}
+
+		return encoded, nil
+
+	default:
+		return nil, fmt.Errorf("%w: the method %s does not exist/is not available", blockchain.ErrMethodNotFound, method)
+	}
+}
+
+// blockParam decodes the first parameter, a block number or tag
+func (c *Chain) blockParam(params []json.RawMessage) (int64, error) {
+	var param string
+	if err := decodeParam(params, 0, &param); err != nil {
+		return 0, err
+	}
+
+	return c.blockNumber(param)
+}
+
+// blockNumber parses a block number or resolves a block tag
+func (c *Chain) blockNumber(param string) (int64, error) {
+	if !strings.HasPrefix(param, "0x") {
+		number, err := c.tagNumber(param)
+		if err != nil {
+			return 0, invalidParamsError{err}
+		}
+
+		return number, nil
+	}
+
+	number, err := blockchain.HexToInt64(param)
+	if err != nil {
+		return 0, invalidParamsError{err}
+	}
+
+	return number, nil
+}
+
+func (c *Chain) logFilterParam(params []json.RawMessage) (blockchain.LogFilter, error) {
+	var param struct {
+		FromBlock string          `json:"fromBlock"`
+		ToBlock   string          `json:"toBlock"`
+		BlockHash string          `json:"blockHash"`
+		Address   json.RawMessage `json:"address"`
+		Topics    []any           `json:"topics"`
+	}
+
+	if err := decodeParam(params, 0, &param); err != nil {
+		return blockchain.LogFilter{}, err
+	}
+
+	filter := blockchain.LogFilter{BlockHash: param.BlockHash, FromBlock: c.Head(), ToBlock: c.Head()}
+
+	var err error
+
+	if param.FromBlock != "" {
+		if filter.FromBlock, err = c.blockNumber(param.FromBlock); err != nil {
+			return blockchain.LogFilter{}, err
+		}
+	}
+
+	if param.ToBlock != "" {
+		if filter.ToBlock, err = c.blockNumber(param.ToBlock); err != nil {
+			return blockchain.LogFilter{}, err
+		}
+	}
+
+	// the address is either a single address or a list of them
+	if len(param.Address) > 0 && string(param.Address) != "null" {
+		var address string
+		if json.Unmarshal(param.Address, &address) == nil {
+			filter.Addresses = []string{address}
+		} else if err := json.Unmarshal(param.Address, &filter.Addresses); err != nil {
+			return blockchain.LogFilter{}, invalidParamsError{fmt.Errorf("invalid address: %w", err)}
+		}
+	}
+
+	// each topic is either null, a single value or a list of values
+	for _, topic := range param.Topics {
+		switch topic := topic.(type) {
+		case nil:
+			filter.Topics = append(filter.Topics, nil)
+		case string:
+			filter.Topics = append(filter.Topics, []string{topic})
+		case []any:
+			values := make([]string, 0, len(topic))
+			for _, value := range topic {
+				if value, ok := value.(string); ok {
+					values = append(values, value)
+				}
+			}
+
+			filter.Topics = append(filter.Topics, values)
+		default:
+			return blockchain.LogFilter{}, invalidParamsError{fmt.Errorf("invalid topic %v", topic)}
+		}
+	}
+
+	return filter, nil
+}
+
+func decodeParam(params []json.RawMessage, index int, value any) error {
+	if index >= len(params) {
+		return invalidParamsError{fmt.Errorf("missing value for required argument %d", index)}
+	}
+
+	if err := json.Unmarshal(params[index], value); err != nil {
+		return invalidParamsError{fmt.Errorf("invalid argument %d: %w", index, err)}
+	}
+
+	return nil
+}
+
+// toRPCError answers an error with the code a node would use for it
+func toRPCError(err error) *rpcError {
+	var invalidParams invalidParamsError
+
+	switch {
+	case errors.As(err, &invalidParams):
+		return &rpcError{Code: codeInvalidParams, Message: err.Error()}
+	case errors.Is(err, blockchain.ErrRateLimited):
+		return &rpcError{Code: codeLimitExceeded, Message: "rate limit exceeded"}
+	case errors.Is(err, blockchain.ErrMethodNotFound):
+		return &rpcError{Code: codeMethodNotFound, Message: err.Error()}
+	case errors.Is(err, blockchain.ErrBlockNotAvailable):
+		return &rpcError{Code: codeServerError, Message: "unknown block"}
+	default:
+		return &rpcError{Code: codeInternalError, Message: err.Error()}
+	}
+}
+
+// transaction finds a transaction of the canonical chain by hash
+func (c *Chain) transaction(hash string) (api.Transaction, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, block := range c.blocks {
+		for _, tx := range block.Transactions {
+			if strings.EqualFold(tx.Hash, hash) {
+				return tx, true
+			}
+		}
+	}
+
+	return api.Transaction{}, false
+}
+
+func encodeBlock(block *api.Block) blockchain.EthBlockResult {
+	txs := make([]blockchain.EthTransaction, 0, len(block.Transactions))
+	for _, tx := range block.Transactions {
+		txs = append(txs, blockchain.EthTransaction{
+			Type:             hexInt(int64(tx.Type)),
+			ChainID:          hexInt(tx.ChainID),
+			Nonce:            tx.Nonce,
+			Gas:              hexInt(int64(tx.Gas)),
+			To:               tx.To,
+			Value:            hexBig(tx.Value),
+			Input:            tx.Input,
+			R:                "0x0",
+			S:                "0x0",
+			V:                "0x0",
+			Hash:             tx.Hash,
+			BlockHash:        tx.BlockHash,
+			BlockNumber:      hexInt(tx.BlockNumber),
+			TransactionIndex: hexInt(int64(tx.TransactionIndex)),
+			From:             tx.From,
+			GasPrice:         hexBig(tx.GasPrice),
+		})
+	}
+
+	return blockchain.EthBlockResult{
+		Hash:         block.Hash,
+		ParentHash:   block.ParentHash,
+		Number:       hexInt(block.Number),
+		Nonce:        block.Nonce,
+		Timestamp:    hexInt(block.Timestamp.Unix()),
+		Uncles:       []any{},
+		Transactions: txs,
+		Withdrawals:  []blockchain.EthWithdrawal{},
+	}
+}
+
+func encodeReceipt(tx api.Transaction, receipt *api.Receipt) blockchain.EthReceipt {
+	status := "0x1"
+	if receipt.Status == api.ReceiptFailed {
+		status = "0x0"
+	}
+
+	encoded := blockchain.EthReceipt{
+		TransactionHash:   receipt.TransactionHash,
+		TransactionIndex:  hexInt(int64(tx.TransactionIndex)),
+		BlockHash:         tx.BlockHash,
+		BlockNumber:       hexInt(tx.BlockNumber),
+		From:              tx.From,
+		To:                tx.To,
+		GasUsed:           hexInt(int64(receipt.GasUsed)),
+		EffectiveGasPrice: hexBig(receipt.EffectiveGasPrice),
+		ContractAddress:   receipt.ContractAddress,
+		Status:            status,
+		Type:              hexInt(int64(tx.Type)),
+		Logs:              make([]blockchain.EthLog, 0, len(receipt.Logs)),
+	}
+
+	for _, l := range receipt.Logs {
+		encoded.Logs = append(encoded.Logs, encodeLog(tx, l))
+	}
+
+	return encoded
+}
+
+// encodeLog encodes a log returned by eth_getLogs, along with the position of its transaction
+func (c *Chain) encodeLog(l api.Log) blockchain.EthLog {
+	tx, _ := c.transaction(l.TransactionHash)
+
+	return encodeLog(tx, l)
+}
+
+func encodeLog(tx api.Transaction, l api.Log) blockchain.EthLog {
+	return blockchain.EthLog{
+		Address:          l.Address,
+		Topics:           l.Topics,
+		Data:             l.Data,
+		BlockNumber:      hexInt(l.BlockNumber),
+		BlockHash:        l.BlockHash,
+		TransactionHash:  l.TransactionHash,
+		TransactionIndex: hexInt(int64(tx.TransactionIndex)),
+		LogIndex:         hexInt(int64(l.LogIndex)),
+	}
+}
+
+func hexInt(value int64) string {
+	return fmt.Sprintf("0x%x", value)
+}
+
+func hexBig(value api.BigInt) string {
+	return fmt.Sprintf("0x%x", value.Big())
+}
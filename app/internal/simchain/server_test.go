@@ -0,0 +1,97 @@
+package simchain_test
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/devshark/tx-parser-go/app/internal/blockchain"
+	"github.com/devshark/tx-parser-go/app/internal/simchain"
+)
+
+func TestHandler(t *testing.T) {
+	ctx := context.Background()
+	token := simchain.Address("token")
+
+	chain := simchain.New(simchain.WithToken(token), simchain.WithFinalityDepths(1, 2))
+	chain.Mine(3)
+
+	server := httptest.NewServer(chain.Handler())
+	defer server.Close()
+
+	client := blockchain.NewPublicNodeClient(server.URL, log.Default())
+
+	head, err := client.GetLatestBlockNumber(ctx)
+	if err != nil || head != 3 {
+		t.Fatalf("Expected the head at block 3, got %d, %v", head, err)
+	}
+
+	// the blocks served over JSON-RPC are the blocks of the chain
+	block, err := client.GetBlockByNumber(ctx, 2)
+	if err != nil {
+		t.Fatalf("GetBlockByNumber returned unexpected error: %v", err)
+	}
+
+	expected, _ := chain.GetBlockByNumber(ctx, 2)
+	expected.Withdrawals = block.Withdrawals
+
+	if !reflect.DeepEqual(block, expected) {
+		t.Errorf("Expected block %+v, got %+v", expected, block)
+	}
+
+	if _, err := client.GetBlockByNumber(ctx, 4); !errors.Is(err, blockchain.ErrBlockNotAvailable) {
+		t.Errorf("Expected block 4 not to be available, got %v", err)
+	}
+
+	blocks, err := client.(blockchain.RangeClient).GetBlocksByRange(ctx, 1, 3)
+	if err != nil || len(blocks) != 3 || blocks[2].Hash != chain.Block(3).Hash {
+		t.Errorf("Expected blocks 1 to 3 in a batch, got %d blocks, %v", len(blocks), err)
+	}
+
+	if finalized, err := client.(blockchain.FinalityClient).GetBlockNumberByTag(ctx, blockchain.TagFinalized); err != nil || finalized != 1 {
+		t.Errorf("Expected block 1 to be finalized, got %d, %v", finalized, err)
+	}
+
+	receipts, err := client.(blockchain.ReceiptClient).GetBlockReceipts(ctx, 2)
+	if err != nil {
+		t.Fatalf("GetBlockReceipts returned unexpected error: %v", err)
+	}
+
+	for i, receipt := range receipts {
+		expected, _ := chain.GetTransactionReceipt(ctx, block.Transactions[i].Hash)
+		if !reflect.DeepEqual(receipt, expected) {
+			t.Errorf("Expected receipt %+v, got %+v", expected, receipt)
+		}
+	}
+
+	filter := blockchain.LogFilter{FromBlock: 1, ToBlock: 3, Addresses: []string{token}, Topics: [][]string{{blockchain.TransferEventTopic}}}
+
+	logs, err := client.(blockchain.LogClient).GetLogs(ctx, filter)
+	if err != nil {
+		t.Fatalf("GetLogs returned unexpected error: %v", err)
+	}
+
+	if expected, _ := chain.GetLogs(ctx, filter); len(logs) == 0 || !reflect.DeepEqual(logs, expected) {
+		t.Errorf("Expected logs %+v, got %+v", expected, logs)
+	}
+
+	// the injected faults are answered with the errors of a node
+	chain.FailNext(simchain.MethodBlockNumber, 1, blockchain.ErrRateLimited)
+
+	if _, err := client.GetLatestBlockNumber(ctx); !errors.Is(err, blockchain.ErrRateLimited) {
+		t.Errorf("Expected the call to be rate limited, got %v", err)
+	}
+
+	chain.FailNext(simchain.AnyMethod, 1, errors.New("boom"))
+
+	if _, err := client.GetBlockByNumber(ctx, 1); !errors.Is(err, blockchain.ErrServerError) {
+		t.Errorf("Expected a server error, got %v", err)
+	}
+
+	if _, err := client.(blockchain.TraceClient).TraceBlockByNumber(ctx, 1); !errors.Is(err, blockchain.ErrMethodNotFound) {
+		t.Errorf("Expected tracing not to be supported, got %v", err)
+	}
+}
@@ -0,0 +1,487 @@
+package worker
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/devshark/tx-parser-go/api"
+	"github.com/devshark/tx-parser-go/app/internal/blockchain"
+	"github.com/devshark/tx-parser-go/app/internal/repository"
+	"github.com/devshark/tx-parser-go/pkg/retry"
+)
+
+const (
+	// DefaultBackfillQueueSize is the number of backfill jobs that can wait to be processed
+	DefaultBackfillQueueSize = 100
+	// DefaultBackfillJobTTL is how long a completed or failed job can still be looked up
+	DefaultBackfillJobTTL = time.Hour
+)
+
+var (
+	ErrBackfillQueueFull = errors.New("backfill queue is full")
+	ErrInvalidFromBlock  = errors.New("from block is not valid")
+	// errStaleBlock is returned for a scanned block that is not the one the live worker parsed at its height
+	errStaleBlock = errors.New("block does not match the parsed chain")
+)
+
+// Backfiller scans past blocks for the transactions of newly subscribed addresses,
+// alongside the ParserWorker which only covers the blocks after the checkpoint.
+// The jobs are kept in memory only: the queued and running ones are lost on a restart,
+// and have to be requested again, the finished ones are evicted after the job TTL.
+// Scanning blocks again is harmless, their records are stored once.
+type Backfiller struct {
+	blockchain      blockchain.BlockchainClient
+	transactionRepo repository.TransactionRepository
+	blockRepo       repository.BlockRepository
+	logger          *log.Logger
+	// confirmationDepth sets the status of the backfilled transactions, like the ParserWorker does
+	confirmationDepth int64
+	batchSize         int
+	tokenTransfers    bool
+	nftTransfers      bool
+	// internalTransactions traces the blocks for the value transferred by contracts
+	internalTransactions bool
+	// parser is the live worker whose checkpoint and headers bound and check the scans
+	parser *ParserWorker
+	jobTTL time.Duration
+	mu     sync.RWMutex // mutex for jobs
+	jobs   map[string]*api.BackfillJob
+	queue  chan string
+}
+
+// NewBackfiller creates a new Backfiller with required arguments
+func NewBackfiller(
+	blockchain blockchain.BlockchainClient,
+	transactionRepo repository.TransactionRepository,
+	blockRepo repository.BlockRepository) *Backfiller {
+	return &Backfiller{
+		blockchain:      blockchain,
+		transactionRepo: transactionRepo,
+		blockRepo:       blockRepo,
+		logger:          log.Default(),
+
+		confirmationDepth: DefaultConfirmationDepth,
+		batchSize:         DefaultBatchSize,
+		jobTTL:            DefaultBackfillJobTTL,
+		jobs:              make(map[string]*api.BackfillJob),
+		queue:             make(chan string, DefaultBackfillQueueSize),
+	}
+}
+
+func (b *Backfiller) WithCustomLogger(logger *log.Logger) *Backfiller {
+	b.logger = logger
+
+	return b
+}
+
+// WithConfirmationDepth sets the number of blocks needed to consider a block confirmed
+func (b *Backfiller) WithConfirmationDepth(depth int64) *Backfiller {
+	b.confirmationDepth = depth
+
+	return b
+}
+
+// WithTokenTransfers enables backfilling the ERC-20 transfers of the address too
+func (b *Backfiller) WithTokenTransfers(enabled bool) *Backfiller {
+	b.tokenTransfers = enabled
+
+	return b
+}
+
+// WithInternalTransactions enables backfilling the internal transactions of the address too
+func (b *Backfiller) WithInternalTransactions(enabled bool) *Backfiller {
+	b.internalTransactions = enabled
+
+	return b
+}
+
+// WithNFTTransfers enables backfilling the ERC-721 and ERC-1155 transfers of the address too
+func (b *Backfiller) WithNFTTransfers(enabled bool) *Backfiller {
+	b.nftTransfers = enabled
+
+	return b
+}
+
+// WithParserWorker stops the scans at the checkpoint of the live worker, which parses the blocks after it
+// for the new subscription, and runs the scanned blocks through its reorg check
+func (b *Backfiller) WithParserWorker(parser *ParserWorker) *Backfiller {
+	b.parser = parser
+
+	return b
+}
+
+// WithJobTTL sets how long a completed or failed job is kept before it is evicted
+func (b *Backfiller) WithJobTTL(ttl time.Duration) *Backfiller {
+	b.jobTTL = ttl
+
+	return b
+}
+
+// Enqueue creates a job that scans from the given block up to the current head, or up to the checkpoint
+// of the live worker when it is set. Otherwise it overlaps the blocks the ParserWorker parses in the meantime,
+// so that none is missed while the new subscription takes effect.
+func (b *Backfiller) Enqueue(ctx context.Context, address string, fromBlock int64) (api.BackfillJob, error) {
+	cleanAddress, err := repository.ValidateAddress(address)
+	if err != nil {
+		return api.BackfillJob{}, fmt.Errorf("ValidateAddress: %w", err)
+	}
+
+	if fromBlock < 0 {
+		return api.BackfillJob{}, ErrInvalidFromBlock
+	}
+
+	toBlock, err := b.blockchain.GetLatestBlockNumber(ctx)
+	if err != nil {
+		return api.BackfillJob{}, fmt.Errorf("failed to get latest block number: %w", err)
+	}
+
+	now := time.Now()
+	job := &api.BackfillJob{
+		ID:           newJobID(),
+		Address:      cleanAddress,
+		FromBlock:    fromBlock,
+		ToBlock:      toBlock,
+		CurrentBlock: fromBlock - 1,
+		Status:       api.BackfillQueued,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.evictJobs(now)
+
+	select {
+	case b.queue <- job.ID:
+	default:
+		return api.BackfillJob{}, ErrBackfillQueueFull
+	}
+
+	b.jobs[job.ID] = job
+
+	return *job, nil
+}
+
+// GetJob returns a copy of the job with the given id
+func (b *Backfiller) GetJob(id string) (api.BackfillJob, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	job, ok := b.jobs[id]
+	if !ok {
+		return api.BackfillJob{}, false
+	}
+
+	return *job, true
+}
+
+// WithBatchSize sets the number of blocks fetched in a single batch
+func (b *Backfiller) WithBatchSize(size int) *Backfiller {
+	if size < 1 {
+		size = 1
+	}
+
+	b.batchSize = size
+
+	return b
+}
+
+// Run processes the queued jobs one at a time until the context is cancelled
+func (b *Backfiller) Run(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case id := <-b.queue:
+			b.runJob(ctx, id)
+		}
+	}
+}
+
+// runJob scans the job's block range, recording its progress after every block
+func (b *Backfiller) runJob(ctx context.Context, id string) {
+	job, ok := b.GetJob(id)
+	if !ok {
+		return
+	}
+
+	b.updateJob(id, func(job *api.BackfillJob) { job.Status = api.BackfillRunning })
+
+	// the live worker parses the blocks after its checkpoint for the new subscription
+	if b.parser != nil {
+		checkpoint, err := b.parser.checkpoint(ctx)
+		if err != nil {
+			b.logger.Printf("backfill %s could not get the checkpoint: %v", id, err)
+
+			b.updateJob(id, func(job *api.BackfillJob) {
+				job.Status = api.BackfillFailed
+				job.Error = err.Error()
+			})
+
+			return
+		}
+
+		// before the worker parsed its first block, the whole range is scanned
+		if checkpoint > 0 && checkpoint < job.ToBlock {
+			job.ToBlock = checkpoint
+			b.updateJob(id, func(job *api.BackfillJob) { job.ToBlock = checkpoint })
+		}
+	}
+
+	b.logger.Printf("backfilling %s from block %d to %d", job.Address, job.FromBlock, job.ToBlock)
+
+	// the ParserWorker keeps moving the statuses of the recent blocks from there
+	head, err := b.blockchain.GetLatestBlockNumber(ctx)
+	if err != nil {
+		b.logger.Printf("backfill %s could not get the latest block: %v", id, err)
+
+		head = job.ToBlock
+	}
+
+	for start := job.FromBlock; start <= job.ToBlock; start += int64(b.batchSize) {
+		end := min(start+int64(b.batchSize)-1, job.ToBlock)
+		prefetched, err := b.prefetchBlocks(ctx, start, end)
+		if err != nil {
+			b.logger.Printf("backfill %s failed to fetch blocks %d to %d: %v", id, start, end, err)
+
+			b.updateJob(id, func(job *api.BackfillJob) {
+				job.Status = api.BackfillFailed
+				job.Error = err.Error()
+			})
+
+			return
+		}
+
+		for blockNum := start; blockNum <= end; blockNum++ {
+			status := blockStatus(blockNum, finality{head: head}, b.confirmationDepth)
+
+			var block *api.Block
+			if i := blockNum - start; i < int64(len(prefetched)) {
+				block = prefetched[i]
+			}
+
+			action := func() error {
+				err := b.scanBlock(ctx, job.Address, blockNum, block, status)
+				if errors.Is(err, errStaleBlock) {
+					// the node may have served the block of a fork, fetch it again
+					block = nil
+				}
+
+				return err
+			}
+			if err := retry.Retry(ctx, action, retry.DefaultMaxAttempts); err != nil {
+				b.logger.Printf("backfill %s failed at block %d: %v", id, blockNum, err)
+
+				b.updateJob(id, func(job *api.BackfillJob) {
+					job.Status = api.BackfillFailed
+					job.Error = err.Error()
+				})
+
+				return
+			}
+
+			b.updateJob(id, func(job *api.BackfillJob) { job.CurrentBlock = blockNum })
+		}
+	}
+
+	b.updateJob(id, func(job *api.BackfillJob) { job.Status = api.BackfillCompleted })
+}
+
+// prefetchBlocks fetches the blocks in a single batch if the client supports it,
+// the blocks that failed individually are nil and left to scanBlock. A batch that keeps
+// failing as a whole is returned as an error rather than fetched again block by block.
+func (b *Backfiller) prefetchBlocks(ctx context.Context, from, to int64) ([]*api.Block, error) {
+	client, ok := b.blockchain.(blockchain.RangeClient)
+	if !ok || from == to {
+		return nil, nil
+	}
+
+	var blocks []*api.Block
+
+	action := func() error {
+		var err error
+		blocks, err = client.GetBlocksByRange(ctx, from, to)
+
+		var batchErr *blockchain.BatchError
+		if errors.As(err, &batchErr) {
+			return nil
+		}
+
+		if err != nil && !blockchain.IsRetryable(err) {
+			return retry.Permanent(err)
+		}
+
+		return err
+	}
+
+	if err := retry.Retry(ctx, action, retry.DefaultMaxAttempts); err != nil {
+		if ctx.Err() != nil || blockchain.IsRetryable(err) {
+			return nil, err
+		}
+
+		// e.g. the node does not support batches, scanBlock fetches the blocks one by one
+		return nil, nil
+	}
+
+	return blocks, nil
+}
+
+// scanBlock commits the block's records that involve the address as a single unit, fetching the block
+// if it was not prefetched. It leaves the checkpoint to the ParserWorker, and the records already saved
+// by the ParserWorker or an earlier scan are skipped by the repository. With the live worker set,
+// a block that does not match the header it recorded at that height is refused with errStaleBlock.
+func (b *Backfiller) scanBlock(ctx context.Context, address string, blockNum int64, block *api.Block, status api.TransactionStatus) error {
+	if block == nil {
+		var err error
+
+		block, err = b.blockchain.GetBlockByNumber(ctx, blockNum)
+		if err != nil && !blockchain.IsRetryable(err) {
+			return retry.Permanent(err)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if block == nil {
+		return fmt.Errorf("%w: block %d", blockchain.ErrBlockNotAvailable, blockNum)
+	}
+
+	involves := func(from, to string) bool {
+		return strings.EqualFold(from, address) || strings.EqualFold(to, address)
+	}
+
+	matched := []*api.Transaction{}
+
+	for _, tx := range block.Transactions {
+		if !involves(tx.From, tx.To) {
+			continue
+		}
+
+		tx.Status = status
+		matched = append(matched, &tx)
+	}
+
+	if err := attachReceipts(ctx, b.blockchain, block.Number, matched); err != nil {
+		return err
+	}
+
+	matches := repository.BlockMatches{}
+
+	for _, tx := range matched {
+		matches.Transactions = append(matches.Transactions, repository.Match[api.Transaction]{Address: address, Record: *tx})
+	}
+
+	for _, withdrawal := range block.Withdrawals {
+		if !strings.EqualFold(withdrawal.Address, address) {
+			continue
+		}
+
+		withdrawal.Status = status
+		matches.Withdrawals = append(matches.Withdrawals, repository.Match[api.Withdrawal]{Address: address, Record: withdrawal})
+	}
+
+	if b.internalTransactions {
+		internal, err := blockInternalTransactions(ctx, b.blockchain, block, status)
+		if err != nil {
+			return err
+		}
+
+		for _, tx := range internal {
+			if involves(tx.From, tx.To) {
+				matches.Transactions = append(matches.Transactions, repository.Match[api.Transaction]{Address: address, Record: tx})
+			}
+		}
+	}
+
+	transfers, err := blockTransfers(ctx, b.blockchain, block, status, b.tokenTransfers, b.nftTransfers)
+	if err != nil {
+		return err
+	}
+
+	for _, transfer := range transfers.tokens {
+		if involves(transfer.From, transfer.To) {
+			matches.TokenTransfers = append(matches.TokenTransfers, repository.Match[api.TokenTransfer]{Address: address, Record: transfer})
+		}
+	}
+
+	for _, transfer := range transfers.nfts {
+		if involves(transfer.From, transfer.To) {
+			matches.NFTTransfers = append(matches.NFTTransfers, repository.Match[api.NFTTransfer]{Address: address, Record: transfer})
+		}
+	}
+
+	if b.parser != nil {
+		return b.parser.commitBackfill(ctx, block, matches)
+	}
+
+	if err := repository.CommitRecords(ctx, b.transactionRepo, b.blockRepo, block.Number, block.Hash, matches); err != nil {
+		return fmt.Errorf("CommitRecords: %w", err)
+	}
+
+	return nil
+}
+
+// checkpoint returns the last parsed block. It is read under chainMu, so every block parsed
+// after it is matched against the subscriptions made before the call.
+func (p *ParserWorker) checkpoint(ctx context.Context) (int64, error) {
+	p.chainMu.Lock()
+	defer p.chainMu.Unlock()
+
+	return p.blockRepo.GetLastParsedBlock(ctx)
+}
+
+// commitBackfill commits the records a backfill matched in a block, unless the block differs from the header
+// recorded at its height, e.g. fetched from a fork the worker has since rolled back, whose records would
+// otherwise be revived after being orphaned. The blocks older than the headers are committed as they are.
+func (p *ParserWorker) commitBackfill(ctx context.Context, block *api.Block, matches repository.BlockMatches) error {
+	p.chainMu.Lock()
+	defer p.chainMu.Unlock()
+
+	if hash, ok := p.headers.get(block.Number); ok && !strings.EqualFold(hash, block.Hash) {
+		return fmt.Errorf("%w: block %d is %s, parsed %s", errStaleBlock, block.Number, block.Hash, hash)
+	}
+
+	if err := repository.CommitRecords(ctx, p.transactionRepo, p.blockRepo, block.Number, block.Hash, matches); err != nil {
+		return fmt.Errorf("CommitRecords: %w", err)
+	}
+
+	return nil
+}
+
+// evictJobs removes the completed and failed jobs that were last updated longer than the job TTL ago,
+// it must be called with the lock held
+func (b *Backfiller) evictJobs(now time.Time) {
+	for id, job := range b.jobs {
+		finished := job.Status == api.BackfillCompleted || job.Status == api.BackfillFailed
+		if finished && now.Sub(job.UpdatedAt) > b.jobTTL {
+			delete(b.jobs, id)
+		}
+	}
+}
+
+func (b *Backfiller) updateJob(id string, update func(job *api.BackfillJob)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if job, ok := b.jobs[id]; ok {
+		update(job)
+		job.UpdatedAt = time.Now()
+	}
+}
+
+func newJobID() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,255 @@
+package worker_test
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/devshark/tx-parser-go/api"
+	"github.com/devshark/tx-parser-go/app/internal/repository"
+	"github.com/devshark/tx-parser-go/app/worker"
+)
+
+func TestBackfiller_Run(t *testing.T) {
+	mockBC := &MockBlockchainClient{
+		initialBlockNumber: 5,
+		latestBlockNumber:  5,
+		blocks: map[int64]*api.Block{
+			1: {Number: 1, Transactions: []api.Transaction{{From: "0x9", To: "0x2", Hash: "0x111"}}},
+			2: {Number: 2, Transactions: []api.Transaction{{From: "0x2", To: "0x9", Hash: "0x222"}}},
+			3: {Number: 3, Transactions: []api.Transaction{{From: "0x2", To: "0x3", Hash: "0x333"}}},
+			4: {Number: 4, Transactions: []api.Transaction{{From: "0x9", To: "0x3", Hash: "0x444"}}},
+			5: {Number: 5, Transactions: []api.Transaction{{From: "0x9", To: "0x3", Hash: "0x555"}}},
+		},
+	}
+
+	mockTxRepo := repository.NewInMemoryTransactionRepository()
+	mockBlockRepo := repository.NewInMemoryBlockRepository()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// the live worker already parsed block 4 for the address, and block 5 is in flight
+	mockBlockRepo.UpdateLastParsedBlock(ctx, 4)
+	mockTxRepo.SaveTransaction(ctx, "0x9", mockBC.blocks[4].Transactions[0])
+
+	backfiller := worker.NewBackfiller(mockBC, mockTxRepo, mockBlockRepo)
+
+	job, err := backfiller.Enqueue(ctx, "0X9", 2)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if job.Status != api.BackfillQueued || job.ToBlock != 5 || job.Address != "0x9" {
+		t.Errorf("Unexpected job: %+v", job)
+	}
+
+	go backfiller.Run(ctx)
+
+	if !waitFor(time.Second, func() bool {
+		job, _ := backfiller.GetJob(job.ID)
+		return job.Status == api.BackfillCompleted
+	}) {
+		t.Fatal("Expected backfill job to complete")
+	}
+
+	if job, _ := backfiller.GetJob(job.ID); job.CurrentBlock != 5 {
+		t.Errorf("Expected current block to be 5, got %d", job.CurrentBlock)
+	}
+
+	if last, _ := mockBlockRepo.GetLastParsedBlock(ctx); last != 4 {
+		t.Errorf("Expected the last parsed block to be left to the live worker, got %d", last)
+	}
+
+	// block 1 is before the requested range, and the transaction of block 4 is only stored once
+	txs, _ := mockTxRepo.GetTransactions(ctx, "0x9")
+	hashes := make([]string, 0, len(txs))
+	for _, tx := range txs {
+		hashes = append(hashes, tx.Hash)
+	}
+	sort.Strings(hashes)
+
+	if len(hashes) != 3 || hashes[0] != "0x222" || hashes[1] != "0x444" || hashes[2] != "0x555" {
+		t.Errorf("Expected transactions [0x222 0x444 0x555], got %v", hashes)
+	}
+}
+
+func TestBackfiller_ParserWorker(t *testing.T) {
+	blocks := make(map[int64]*api.Block)
+	for i := int64(1); i <= 7; i++ {
+		blocks[i] = &api.Block{
+			Number:       i,
+			Hash:         fmt.Sprintf("0xa%d", i),
+			ParentHash:   fmt.Sprintf("0xa%d", i-1),
+			Transactions: []api.Transaction{{From: "0x9", To: "0x2", Hash: fmt.Sprintf("0x%d00", i)}},
+		}
+	}
+	canonical := blocks[4]
+
+	mockBC := &MockBlockchainClient{
+		initialBlockNumber: 0,
+		latestBlockNumber:  5,
+		blocks:             blocks,
+	}
+
+	mockTxRepo := repository.NewInMemoryTransactionRepository()
+	mockSubRepo := repository.NewInMemorySubscriberRepository()
+	mockBlockRepo := repository.NewInMemoryBlockRepository()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// the live worker parses blocks 1 to 5 before the address is subscribed
+	parser := worker.NewParserWorker(mockBC, mockTxRepo, mockSubRepo, mockBlockRepo)
+
+	parserCtx, stopParser := context.WithCancel(ctx)
+	go parser.Run(parserCtx, 50*time.Millisecond)
+
+	if !waitFor(time.Second, func() bool {
+		last, _ := mockBlockRepo.GetLastParsedBlock(ctx)
+		return last == 5
+	}) {
+		t.Fatal("Expected block 5 to be parsed")
+	}
+	stopParser()
+
+	// the node serves a fork of block 4 that the worker has not parsed, and blocks 6 and 7 it has not reached
+	mockBC.reorg(7, map[int64]*api.Block{4: {
+		Number:       4,
+		Hash:         "0xb4",
+		ParentHash:   "0xa3",
+		Transactions: []api.Transaction{{From: "0x9", To: "0x2", Hash: "0x4b4"}},
+	}})
+
+	backfiller := worker.NewBackfiller(mockBC, mockTxRepo, mockBlockRepo).WithParserWorker(parser)
+
+	go backfiller.Run(ctx)
+
+	job, err := backfiller.Enqueue(ctx, "0x9", 1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !waitFor(3*time.Second, func() bool {
+		job, _ := backfiller.GetJob(job.ID)
+		return job.Status == api.BackfillFailed
+	}) {
+		t.Fatal("Expected the backfill job to fail on the fork of block 4")
+	}
+
+	// the scan stops at the checkpoint, the worker parses blocks 6 and 7 for the new subscription
+	if job, _ := backfiller.GetJob(job.ID); job.ToBlock != 5 || job.CurrentBlock != 3 {
+		t.Errorf("Expected the job to stop at block 3 of blocks up to 5, got %+v", job)
+	}
+
+	hashes := func() []string {
+		txs, _ := mockTxRepo.GetTransactions(ctx, "0x9")
+		result := make([]string, 0, len(txs))
+		for _, tx := range txs {
+			result = append(result, tx.Hash)
+		}
+		sort.Strings(result)
+		return result
+	}
+
+	if result := hashes(); fmt.Sprint(result) != "[0x100 0x200 0x300]" {
+		t.Errorf("Expected transactions [0x100 0x200 0x300], got %v", result)
+	}
+
+	// once the node serves the parsed block 4 again, the rest of the range is scanned
+	mockBC.reorg(7, map[int64]*api.Block{4: canonical})
+
+	job, err = backfiller.Enqueue(ctx, "0x9", 4)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !waitFor(time.Second, func() bool {
+		job, _ := backfiller.GetJob(job.ID)
+		return job.Status == api.BackfillCompleted
+	}) {
+		job, _ := backfiller.GetJob(job.ID)
+		t.Fatalf("Expected the backfill job to complete, got %+v", job)
+	}
+
+	if result := hashes(); fmt.Sprint(result) != "[0x100 0x200 0x300 0x400 0x500]" {
+		t.Errorf("Expected transactions [0x100 0x200 0x300 0x400 0x500], got %v", result)
+	}
+}
+
+func TestBackfiller_JobTTL(t *testing.T) {
+	mockBC := &MockBlockchainClient{
+		initialBlockNumber: 1,
+		latestBlockNumber:  1,
+		blocks:             map[int64]*api.Block{1: {Number: 1}},
+	}
+
+	backfiller := worker.NewBackfiller(mockBC, repository.NewInMemoryTransactionRepository(), repository.NewInMemoryBlockRepository()).
+		WithJobTTL(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	go backfiller.Run(ctx)
+
+	finished, _ := backfiller.Enqueue(ctx, "0x9", 1)
+
+	if !waitFor(time.Second, func() bool {
+		job, _ := backfiller.GetJob(finished.ID)
+		return job.Status == api.BackfillCompleted
+	}) {
+		t.Fatal("Expected the backfill job to complete")
+	}
+
+	// a finished job is kept for the TTL, and evicted by the next job enqueued after it
+	if _, ok := backfiller.GetJob(finished.ID); !ok {
+		t.Fatal("Expected the finished job to be kept")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	next, _ := backfiller.Enqueue(ctx, "0x9", 1)
+
+	if _, ok := backfiller.GetJob(finished.ID); ok {
+		t.Error("Expected the finished job to be evicted")
+	}
+
+	if _, ok := backfiller.GetJob(next.ID); !ok {
+		t.Error("Expected the new job to be kept")
+	}
+}
+
+func TestBackfiller_Enqueue(t *testing.T) {
+	backfiller := worker.NewBackfiller(
+		&MockBlockchainClient{},
+		repository.NewInMemoryTransactionRepository(),
+		repository.NewInMemoryBlockRepository())
+	ctx := context.Background()
+
+	// Test enqueueing with empty address
+	if _, err := backfiller.Enqueue(ctx, "", 1); err == nil {
+		t.Fatal("Expected error when enqueueing with empty address, got nil")
+	}
+
+	// Test enqueueing with negative block number
+	if _, err := backfiller.Enqueue(ctx, "0x9", -1); err == nil {
+		t.Fatal("Expected error when enqueueing with negative block number, got nil")
+	}
+
+	// Test getting an unknown job
+	if _, ok := backfiller.GetJob("unknown"); ok {
+		t.Fatal("Expected unknown job to not be found")
+	}
+
+	// Test filling up the queue
+	for i := 0; i < worker.DefaultBackfillQueueSize; i++ {
+		if _, err := backfiller.Enqueue(ctx, "0x9", 1); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	if _, err := backfiller.Enqueue(ctx, "0x9", 1); err != worker.ErrBackfillQueueFull {
+		t.Fatalf("Expected ErrBackfillQueueFull, got %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/devshark/tx-parser-go/api"
+	"github.com/devshark/tx-parser-go/app/internal/repository"
+)
+
+// ContractTag tags the subscriptions of the contracts deployed by subscribed addresses
+const ContractTag = "contract"
+
+// WithContractSubscriptions enables subscribing to the contracts deployed by subscribed addresses
+func (p *ParserWorker) WithContractSubscriptions(enabled bool) *ParserWorker {
+	p.contractSubscriptions = enabled
+
+	return p
+}
+
+// subscribeCreatedContracts subscribes to the contracts deployed by the subscribed senders of the
+// matched transactions, and adds them to the addresses the deployments are saved for.
+// The address of a contract is only known from the receipt of its deployment. A contract that has
+// a subscription already, even expired, keeps it as is.
+func (p *ParserWorker) subscribeCreatedContracts(ctx context.Context, matched []*api.Transaction, addresses [][]string) error {
+	if !p.contractSubscriptions {
+		return nil
+	}
+
+	for i, tx := range matched {
+		if !tx.ContractCreation || tx.ContractAddress == "" || tx.Failed() || !containsFold(addresses[i], tx.From) {
+			continue
+		}
+
+		existing, err := p.subscriberRepo.GetSubscription(ctx, tx.ContractAddress)
+
+		switch {
+		case errors.Is(err, repository.ErrNotSubscribed):
+			subscription := api.Subscription{
+				Address:              tx.ContractAddress,
+				SubscriptionMetadata: api.SubscriptionMetadata{Label: "deployed by " + strings.ToLower(tx.From), Tags: []string{ContractTag}},
+				CreatedAtBlock:       tx.BlockNumber,
+				CreatedAt:            tx.Timestamp,
+			}
+
+			if err := p.subscriberRepo.SaveSubscription(ctx, subscription); err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		case existing.Expired(time.Now()):
+			continue
+		}
+
+		p.logger.Printf("subscribed to contract %s deployed by %s", tx.ContractAddress, strings.ToLower(tx.From))
+
+		addresses[i] = append(addresses[i], tx.ContractAddress)
+	}
+
+	return nil
+}
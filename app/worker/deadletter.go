@@ -0,0 +1,99 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/devshark/tx-parser-go/api"
+)
+
+const (
+	// DefaultDeadLetterInterval is how often failed blocks are re-attempted
+	DefaultDeadLetterInterval = time.Minute
+	// DefaultMaxDeadLetterAttempts is how many times a failed block is re-attempted before it is parked
+	DefaultMaxDeadLetterAttempts = 10
+)
+
+// WithDeadLetterRetries sets how often and how many times failed blocks are re-attempted
+func (p *ParserWorker) WithDeadLetterRetries(interval time.Duration, maxAttempts int) *ParserWorker {
+	p.deadLetterInterval = interval
+	p.maxDeadLetterAttempts = maxAttempts
+
+	return p
+}
+
+// deadLetter records a block that could not be parsed so it can be re-attempted later,
+// it reports whether the block was persisted
+func (p *ParserWorker) deadLetter(ctx context.Context, blockNum int64, cause error) bool {
+	// the block was not lost, we are just shutting down
+	if ctx.Err() != nil {
+		return false
+	}
+
+	failed := api.FailedBlock{
+		Number:    blockNum,
+		LastError: cause.Error(),
+		FailedAt:  time.Now(),
+	}
+
+	if err := p.blockRepo.AddFailedBlock(ctx, failed); err != nil {
+		p.logger.Printf("failed to record failed block %d: %v", blockNum, err)
+
+		return false
+	}
+
+	return true
+}
+
+// runDeadLetterLoop periodically re-attempts the failed blocks until the context is cancelled
+func (p *ParserWorker) runDeadLetterLoop(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(p.deadLetterInterval):
+			p.retryFailedBlocks(ctx)
+		}
+	}
+}
+
+// retryFailedBlocks parses every failed block that has attempts left,
+// removing it from the failed blocks once it succeeds
+func (p *ParserWorker) retryFailedBlocks(ctx context.Context) {
+	failedBlocks, err := p.blockRepo.GetFailedBlocks(ctx)
+	if err != nil {
+		p.logger.Printf("failed to get failed blocks: %v", err)
+
+		return
+	}
+
+	for _, failed := range failedBlocks {
+		if failed.Attempts >= p.maxDeadLetterAttempts {
+			continue
+		}
+
+		if err := p.reparseBlock(ctx, failed.Number); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+
+			failed.Attempts++
+			failed.LastError = err.Error()
+			failed.FailedAt = time.Now()
+
+			if err := p.blockRepo.AddFailedBlock(ctx, failed); err != nil {
+				p.logger.Printf("failed to update failed block %d: %v", failed.Number, err)
+			}
+
+			continue
+		}
+
+		if err := p.blockRepo.RemoveFailedBlock(ctx, failed.Number); err != nil {
+			p.logger.Printf("failed to remove failed block %d: %v", failed.Number, err)
+
+			continue
+		}
+
+		p.logger.Printf("recovered failed block %d", failed.Number)
+	}
+}
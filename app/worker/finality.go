@@ -0,0 +1,110 @@
+package worker
+
+import (
+	"context"
+
+	"github.com/devshark/tx-parser-go/api"
+	"github.com/devshark/tx-parser-go/app/internal/blockchain"
+)
+
+// DefaultConfirmationDepth is the number of blocks (including its own) on top of which
+// a block's transactions are considered confirmed
+const DefaultConfirmationDepth = 12
+
+// finality is the chain's progress used to derive the status of a block
+type finality struct {
+	head      int64
+	safe      int64 // 0 when unknown
+	finalized int64 // 0 when unknown
+}
+
+// WithConfirmationDepth sets the number of blocks needed to consider a block confirmed
+func (p *ParserWorker) WithConfirmationDepth(depth int64) *ParserWorker {
+	p.confirmationDepth = depth
+
+	return p
+}
+
+// WithFinalityTags makes the worker use the node's "safe" and "finalized" block tags,
+// when the blockchain client supports them
+func (p *ParserWorker) WithFinalityTags(enabled bool) *ParserWorker {
+	p.finalityTags = enabled
+
+	return p
+}
+
+// blockStatus derives the status of a block's transactions from the chain's progress
+func blockStatus(number int64, chain finality, confirmationDepth int64) api.TransactionStatus {
+	switch {
+	case chain.finalized > 0 && number <= chain.finalized:
+		return api.StatusFinalized
+	case chain.safe > 0 && number <= chain.safe:
+		return api.StatusConfirmed
+	case chain.head-number+1 >= confirmationDepth:
+		return api.StatusConfirmed
+	default:
+		return api.StatusPending
+	}
+}
+
+// currentFinality returns the latest known chain progress
+func (p *ParserWorker) currentFinality() finality {
+	p.finalityMu.RLock()
+	defer p.finalityMu.RUnlock()
+
+	return p.finality
+}
+
+// refreshFinality records the new head and, if enabled, the node's safe and finalized blocks
+func (p *ParserWorker) refreshFinality(ctx context.Context, head int64) {
+	chain := p.currentFinality()
+	chain.head = head
+
+	if client, ok := p.blockchain.(blockchain.FinalityClient); ok && p.finalityTags {
+		if safe, err := client.GetBlockNumberByTag(ctx, blockchain.TagSafe); err != nil {
+			p.logger.Printf("failed to get safe block: %v", err)
+		} else {
+			chain.safe = safe
+		}
+
+		if finalized, err := client.GetBlockNumberByTag(ctx, blockchain.TagFinalized); err != nil {
+			p.logger.Printf("failed to get finalized block: %v", err)
+		} else {
+			chain.finalized = finalized
+		}
+	}
+
+	p.finalityMu.Lock()
+	p.finality = chain
+	p.finalityMu.Unlock()
+}
+
+// updateStatuses moves the stored records forward to their new status as the chain progresses.
+// The records are swept by block number, so that the ones stored before a restart, by a backfill
+// or for a requeued failed block are promoted as well. A sweep only runs once the chain moved past
+// the last one, the records stored in between were given the status of their block already.
+func (p *ParserWorker) updateStatuses(ctx context.Context) {
+	p.chainMu.Lock()
+	defer p.chainMu.Unlock()
+
+	chain := p.currentFinality()
+
+	if chain.finalized > p.finalizedUpTo {
+		if err := p.transactionRepo.PromoteStatus(ctx, chain.finalized, api.StatusFinalized); err != nil {
+			p.logger.Printf("failed to finalize blocks up to %d: %v", chain.finalized, err)
+		} else {
+			p.finalizedUpTo = chain.finalized
+		}
+	}
+
+	// the highest block confirmed either by depth or by the safe tag, see blockStatus
+	confirmed := max(chain.head-p.confirmationDepth+1, chain.safe)
+
+	if confirmed > p.confirmedUpTo {
+		if err := p.transactionRepo.PromoteStatus(ctx, confirmed, api.StatusConfirmed); err != nil {
+			p.logger.Printf("failed to confirm blocks up to %d: %v", confirmed, err)
+		} else {
+			p.confirmedUpTo = confirmed
+		}
+	}
+}
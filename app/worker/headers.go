@@ -0,0 +1,63 @@
+package worker
+
+import "sync"
+
+// headerChain keeps the hashes of the most recently ingested blocks (number -> hash)
+// so that a new block can be checked against the parent we already know about
+type headerChain struct {
+	sync.RWMutex
+	size    int
+	highest int64
+	headers map[int64]string
+}
+
+func newHeaderChain(size int) *headerChain {
+	return &headerChain{
+		size:    size,
+		headers: make(map[int64]string),
+	}
+}
+
+// get returns the hash recorded for the given block number, if any
+func (h *headerChain) get(number int64) (string, bool) {
+	h.RLock()
+	defer h.RUnlock()
+
+	hash, ok := h.headers[number]
+
+	return hash, ok && hash != ""
+}
+
+// add records the hash of a block and drops headers that fell out of the window
+func (h *headerChain) add(number int64, hash string) {
+	h.Lock()
+	defer h.Unlock()
+
+	h.headers[number] = hash
+
+	if number > h.highest {
+		h.highest = number
+	}
+
+	for n := range h.headers {
+		if n <= h.highest-int64(h.size) {
+			delete(h.headers, n)
+		}
+	}
+}
+
+// truncate removes every header above the given block number
+func (h *headerChain) truncate(number int64) {
+	h.Lock()
+	defer h.Unlock()
+
+	for n := range h.headers {
+		if n > number {
+			delete(h.headers, n)
+		}
+	}
+
+	if h.highest > number {
+		h.highest = number
+	}
+}
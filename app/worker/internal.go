@@ -0,0 +1,73 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/devshark/tx-parser-go/api"
+	"github.com/devshark/tx-parser-go/app/internal/blockchain"
+	"github.com/devshark/tx-parser-go/app/internal/repository"
+)
+
+// WithInternalTransactions enables tracing the blocks for the value transferred
+// to and from subscribed addresses by contracts, which requires a node with the debug API
+func (p *ParserWorker) WithInternalTransactions(enabled bool) *ParserWorker {
+	p.internalTransactions = enabled
+
+	return p
+}
+
+// matchInternalTransactions adds the internal transactions of the block that involve a subscribed address to the matches
+func (p *ParserWorker) matchInternalTransactions(ctx context.Context, block *api.Block, status api.TransactionStatus, matches *repository.BlockMatches) error {
+	if !p.internalTransactions {
+		return nil
+	}
+
+	internal, err := blockInternalTransactions(ctx, p.blockchain, block, status)
+	if err != nil {
+		return err
+	}
+
+	for _, tx := range internal {
+		addresses, err := p.subscribedAddresses(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		for _, addr := range addresses {
+			matches.Transactions = append(matches.Transactions, repository.Match[api.Transaction]{Address: addr, Record: tx})
+		}
+	}
+
+	return nil
+}
+
+// blockInternalTransactions traces the transactions of a block for the calls that transferred value.
+// There are none if the client, or the node behind it, does not provide traces.
+func blockInternalTransactions(ctx context.Context, client blockchain.BlockchainClient, block *api.Block, status api.TransactionStatus) ([]api.Transaction, error) {
+	traceClient, ok := client.(blockchain.TraceClient)
+	if !ok || len(block.Transactions) == 0 {
+		return nil, nil
+	}
+
+	traces, err := traceClient.TraceBlockByNumber(ctx, block.Number)
+	if errors.Is(err, blockchain.ErrMethodNotFound) || errors.Is(err, blockchain.ErrNotImplemented) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("TraceBlockByNumber: %w", err)
+	}
+
+	internal, err := blockchain.InternalTransactions(block, traces)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range internal {
+		internal[i].Status = status
+	}
+
+	return internal, nil
+}
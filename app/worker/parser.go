@@ -5,20 +5,46 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/devshark/tx-parser-go/api"
 	"github.com/devshark/tx-parser-go/app/internal/blockchain"
 	"github.com/devshark/tx-parser-go/app/internal/repository"
-	"github.com/devshark/tx-parser-go/pkg/retry"
 )
 
+// DefaultMaxReorgDepth is the number of recent block headers kept to detect chain reorganizations,
+// it also spans the ~2 epochs it takes for a block to be finalized
+const DefaultMaxReorgDepth = 128
+
 type ParserWorker struct {
 	blockchain      blockchain.BlockchainClient
 	transactionRepo repository.TransactionRepository
 	subscriberRepo  repository.SubscriberRepository
 	blockRepo       repository.BlockRepository
 	logger          *log.Logger
+	chainMu         sync.Mutex // serializes block ingestion against the header chain
+	headers         *headerChain
+	startBlock      int64
+	concurrency     int
+	batchSize       int
+	tokenTransfers  bool
+	nftTransfers    bool
+	// internalTransactions traces the blocks for the value transferred by contracts
+	internalTransactions bool
+	// contractSubscriptions subscribes to the contracts deployed by subscribed addresses
+	contractSubscriptions bool
+	// statuses are derived from the confirmation depth and, optionally, the node's finality tags
+	confirmationDepth int64
+	finalityTags      bool
+	finalityMu        sync.RWMutex // mutex for finality
+	finality          finality
+	// the blocks up to which the statuses were last swept, guarded by chainMu
+	confirmedUpTo int64
+	finalizedUpTo int64
+	// failed blocks are re-attempted every deadLetterInterval until maxDeadLetterAttempts is reached
+	deadLetterInterval    time.Duration
+	maxDeadLetterAttempts int
 }
 
 // NewParserWorker creates a new ParserWorker with required arguments
@@ -33,6 +59,13 @@ func NewParserWorker(
 		subscriberRepo:  subscriberRepo,
 		blockRepo:       blockRepo,
 		logger:          log.Default(),
+		headers:         newHeaderChain(DefaultMaxReorgDepth),
+		concurrency:     DefaultConcurrency,
+		batchSize:       DefaultBatchSize,
+
+		confirmationDepth:     DefaultConfirmationDepth,
+		deadLetterInterval:    DefaultDeadLetterInterval,
+		maxDeadLetterAttempts: DefaultMaxDeadLetterAttempts,
 	}
 }
 
@@ -42,62 +75,284 @@ func (p *ParserWorker) WithCustomLogger(logger *log.Logger) *ParserWorker {
 	return p
 }
 
+// WithMaxReorgDepth sets how many recent block headers are kept to detect reorganizations
+func (p *ParserWorker) WithMaxReorgDepth(depth int) *ParserWorker {
+	p.headers = newHeaderChain(depth)
+
+	return p
+}
+
+// WithStartBlock sets the first block to parse when there is no stored checkpoint yet
+func (p *ParserWorker) WithStartBlock(blockNumber int64) *ParserWorker {
+	p.startBlock = blockNumber
+
+	return p
+}
+
 // Run method with improved concurrency and error handling
 func (p *ParserWorker) Run(ctx context.Context, schedule time.Duration) error {
-	// fetch latest block number first
-	// if it fails, return the error so it will handle the recovery sequence
-	lastParsedBlock, err := p.blockchain.GetLatestBlockNumber(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to get latest block number: %w", err)
+	// find where to resume first
+	// if the node is unreachable, keep trying every schedule instead of giving up
+	lastParsedBlock, err := p.resumeBlock(ctx)
+	for err != nil {
+		p.logger.Printf("failed to find where to resume, retrying in %s: %v", schedule, err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(schedule):
+		}
+
+		lastParsedBlock, err = p.resumeBlock(ctx)
 	}
 
+	p.logger.Printf("resuming after block %d", lastParsedBlock)
+
+	go p.runDeadLetterLoop(ctx)
+
+	// new heads pushed by the node trigger a parse right away,
+	// polling every schedule remains as the fallback while the subscription is down
+	var heads <-chan int64
+	if subscriber, ok := p.blockchain.(blockchain.HeadSubscriber); ok {
+		if heads, err = subscriber.SubscribeNewHeads(ctx); err != nil {
+			p.logger.Printf("failed to subscribe to new heads, polling instead: %v", err)
+		}
+	}
+
+	poll := time.NewTimer(schedule)
+	defer poll.Stop()
+
 	// If the context is cancelled, exit immediately
 	for {
+		var latestBlock int64
+
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		case <-time.After(schedule):
+		case head, ok := <-heads:
+			if !ok {
+				heads = nil
+				continue
+			}
+
+			latestBlock = head
+		case <-poll.C:
 			// Get the latest block number
-			latestBlock, err := p.blockchain.GetLatestBlockNumber(ctx)
+			latestBlock, err = p.blockchain.GetLatestBlockNumber(ctx)
 			if err != nil {
-				return err
+				// an outage of the node only delays parsing until the next poll
+				p.logger.Printf("failed to get latest block number: %v", err)
+				poll.Reset(schedule)
+				continue
 			}
+		}
 
-			// p.logger.Printf("last parsed block: %d, latest block: %d", lastParsedBlock, latestBlock)
+		// p.logger.Printf("last parsed block: %d, latest block: %d", lastParsedBlock, latestBlock)
 
-			for _blockNum := lastParsedBlock + 1; _blockNum <= latestBlock; _blockNum++ {
-				go func(blockNum int64) {
-					// Set up a retry loop to parse the block
-					action := func() error { return p.parseBlock(ctx, blockNum) }
-					if err := retry.Retry(ctx, action, retry.DefaultMaxAttempts); err != nil {
-						// Log any errors that happen, but don't crash
-						p.logger.Printf("failed to parse block %d: %v", blockNum, err)
-					}
+		p.refreshFinality(ctx, latestBlock)
 
-					// p.logger.Print("parsed block ", blockNum)
-				}(_blockNum)
-			}
+		// Get the last block number that we've parsed
+		lastParsedBlock = p.parseRange(ctx, lastParsedBlock+1, latestBlock)
 
-			p.blockRepo.UpdateLastParsedBlock(ctx, latestBlock)
-			// Get the last block number that we've parsed
-			lastParsedBlock = latestBlock
+		p.updateStatuses(ctx)
+
+		// only poll if no head arrives within the schedule
+		if !poll.Stop() {
+			select {
+			case <-poll.C:
+			default:
+			}
 		}
+		poll.Reset(schedule)
 	}
 }
 
-// parseBlock parses a single block
-func (p *ParserWorker) parseBlock(ctx context.Context, blockNum int64) error {
+// resumeBlock returns the block to resume after: the stored checkpoint if there is one,
+// otherwise the block before the configured start block, otherwise the latest block
+func (p *ParserWorker) resumeBlock(ctx context.Context) (int64, error) {
+	checkpoint, err := p.blockRepo.GetLastParsedBlock(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get last parsed block: %w", err)
+	}
+
+	if checkpoint > 0 {
+		return checkpoint, nil
+	}
+
+	if p.startBlock > 0 {
+		return p.startBlock - 1, nil
+	}
+
+	latestBlock, err := p.blockchain.GetLatestBlockNumber(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get latest block number: %w", err)
+	}
+
+	return latestBlock, nil
+}
+
+// reparseBlock parses a dead-lettered block again. The block is behind the checkpoint and may be older
+// than the recorded headers, so it is not checked against them: its records are saved with their status,
+// while the checkpoint and the headers stay as they are.
+func (p *ParserWorker) reparseBlock(ctx context.Context, blockNum int64) error {
 	block, err := p.blockchain.GetBlockByNumber(ctx, blockNum)
 	if err != nil {
 		return err
 	}
 
 	if block == nil {
-		return nil
+		return fmt.Errorf("%w: block %d", blockchain.ErrBlockNotAvailable, blockNum)
+	}
+
+	p.chainMu.Lock()
+	defer p.chainMu.Unlock()
+
+	matches, err := p.matchBlock(ctx, block)
+	if err != nil {
+		return err
+	}
+
+	if err := repository.CommitRecords(ctx, p.transactionRepo, p.blockRepo, block.Number, block.Hash, matches); err != nil {
+		return fmt.Errorf("CommitRecords: %w", err)
 	}
 
+	return nil
+}
+
+// ingestBlock saves the transactions of a block, rolling back the orphaned blocks first
+// if the block does not build on the parent we have recorded
+func (p *ParserWorker) ingestBlock(ctx context.Context, block *api.Block) error {
+	p.chainMu.Lock()
+	defer p.chainMu.Unlock()
+
+	if parentHash, ok := p.headers.get(block.Number - 1); ok && block.ParentHash != "" && !strings.EqualFold(parentHash, block.ParentHash) {
+		if err := p.handleReorg(ctx, block); err != nil {
+			return fmt.Errorf("handleReorg: %w", err)
+		}
+	}
+
+	return p.saveBlock(ctx, block)
+}
+
+// saveBlock commits the block's transactions, along with their receipts, transfers and withdrawals
+// of subscribed addresses, together with the last parsed block, and records its header
+func (p *ParserWorker) saveBlock(ctx context.Context, block *api.Block) error {
+	matches, err := p.matchBlock(ctx, block)
+	if err != nil {
+		return err
+	}
+
+	if err := repository.CommitBlock(ctx, p.transactionRepo, p.blockRepo, block.Number, block.Hash, matches); err != nil {
+		return fmt.Errorf("CommitBlock: %w", err)
+	}
+
+	p.headers.add(block.Number, block.Hash)
+
+	return nil
+}
+
+// matchBlock collects the block's records of subscribed addresses, with the status of the block
+func (p *ParserWorker) matchBlock(ctx context.Context, block *api.Block) (repository.BlockMatches, error) {
+	status := blockStatus(block.Number, p.currentFinality(), p.confirmationDepth)
+
+	matched := []*api.Transaction{}
+	addresses := [][]string{}
+
 	for _, tx := range block.Transactions {
-		if err := p.processTx(ctx, tx); err != nil {
+		tx.Status = status
+
+		subscribed, err := p.subscribedAddresses(ctx, tx)
+		if err != nil {
+			return repository.BlockMatches{}, err
+		}
+
+		if len(subscribed) > 0 {
+			matched = append(matched, &tx)
+			addresses = append(addresses, subscribed)
+		}
+	}
+
+	if err := attachReceipts(ctx, p.blockchain, block.Number, matched); err != nil {
+		return repository.BlockMatches{}, err
+	}
+
+	if err := p.subscribeCreatedContracts(ctx, matched, addresses); err != nil {
+		return repository.BlockMatches{}, err
+	}
+
+	matches := repository.BlockMatches{}
+
+	for i, tx := range matched {
+		for _, addr := range addresses[i] {
+			matches.Transactions = append(matches.Transactions, repository.Match[api.Transaction]{Address: addr, Record: *tx})
+		}
+	}
+
+	if err := p.matchInternalTransactions(ctx, block, status, &matches); err != nil {
+		return repository.BlockMatches{}, err
+	}
+
+	if err := p.matchWithdrawals(ctx, block, status, &matches); err != nil {
+		return repository.BlockMatches{}, err
+	}
+
+	if err := p.matchTransfers(ctx, block, status, &matches); err != nil {
+		return repository.BlockMatches{}, err
+	}
+
+	return matches, nil
+}
+
+// handleReorg walks back from the parent of the given block to the common ancestor,
+// marks the transactions of the orphaned blocks as such and re-ingests the canonical ones
+func (p *ParserWorker) handleReorg(ctx context.Context, block *api.Block) error {
+	canonical := []*api.Block{}
+	parentHash := block.ParentHash
+	ancestor := block.Number - 1
+
+	for {
+		storedHash, ok := p.headers.get(ancestor)
+		if !ok {
+			p.logger.Printf("reorg at block %d is deeper than the %d recorded headers", block.Number, p.headers.size)
+			break
+		}
+
+		if strings.EqualFold(storedHash, parentHash) {
+			break
+		}
+
+		parent, err := p.blockchain.GetBlockByNumber(ctx, ancestor)
+		if err != nil {
+			return err
+		}
+
+		if parent == nil {
+			return fmt.Errorf("canonical block %d not found", ancestor)
+		}
+
+		canonical = append(canonical, parent)
+		parentHash = parent.ParentHash
+		ancestor--
+	}
+
+	p.logger.Printf("chain reorganization detected at block %d, common ancestor is block %d", block.Number, ancestor)
+
+	for number := ancestor + 1; number < block.Number; number++ {
+		orphanHash, ok := p.headers.get(number)
+		if !ok {
+			continue
+		}
+
+		if err := p.transactionRepo.UpdateBlockStatus(ctx, orphanHash, api.StatusOrphaned); err != nil {
+			return fmt.Errorf("UpdateBlockStatus: %w", err)
+		}
+	}
+
+	p.headers.truncate(ancestor)
+
+	// re-ingest the canonical blocks from the oldest to the newest
+	for i := len(canonical) - 1; i >= 0; i-- {
+		if err := p.saveBlock(ctx, canonical[i]); err != nil {
 			return err
 		}
 	}
@@ -105,26 +360,44 @@ func (p *ParserWorker) parseBlock(ctx context.Context, blockNum int64) error {
 	return nil
 }
 
-// processTx processes a single transaction
-func (p *ParserWorker) processTx(ctx context.Context, tx api.Transaction) error {
-	addresses := []string{tx.From, tx.To}
+// matchWithdrawals adds the withdrawals of the block to a subscribed address to the matches
+func (p *ParserWorker) matchWithdrawals(ctx context.Context, block *api.Block, status api.TransactionStatus, matches *repository.BlockMatches) error {
+	for _, withdrawal := range block.Withdrawals {
+		subscribed, err := p.subscriberRepo.IsSubscribed(ctx, withdrawal.Address)
+		if err != nil {
+			return err
+		}
+
+		if !subscribed {
+			continue
+		}
+
+		withdrawal.Status = status
+
+		matches.Withdrawals = append(matches.Withdrawals, repository.Match[api.Withdrawal]{Address: withdrawal.Address, Record: withdrawal})
+	}
+
+	return nil
+}
 
-	for _, addr := range addresses {
+// subscribedAddresses returns the addresses of the transaction that are subscribed to
+func (p *ParserWorker) subscribedAddresses(ctx context.Context, tx api.Transaction) ([]string, error) {
+	addresses := []string{}
+
+	for _, addr := range []string{tx.From, tx.To} {
 		if strings.TrimSpace(addr) == "" { // just skip immediately if address is empty
 			continue
 		}
 
 		subscribed, err := p.subscriberRepo.IsSubscribed(ctx, addr)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		if subscribed {
-			if err = p.transactionRepo.SaveTransaction(ctx, addr, tx); err != nil {
-				return err
-			}
+			addresses = append(addresses, addr)
 		}
 	}
 
-	return nil
+	return addresses, nil
 }
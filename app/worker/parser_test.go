@@ -1,16 +1,29 @@
 package worker_test
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/devshark/tx-parser-go/api"
+	"github.com/devshark/tx-parser-go/app/internal/blockchain"
 	"github.com/devshark/tx-parser-go/app/internal/repository"
+	"github.com/devshark/tx-parser-go/app/internal/simchain"
 	"github.com/devshark/tx-parser-go/app/worker"
+	"github.com/devshark/tx-parser-go/pkg/retry"
 )
 
 // MockBlockchainClient implements blockchain.BlockchainClient for testing
@@ -18,8 +31,12 @@ type MockBlockchainClient struct {
 	initialBlockNumber      int64
 	latestBlockNumber       int64
 	blocks                  map[int64]*api.Block
+	blockErrors             map[int64]error
+	blockDelay              func(number int64) time.Duration
 	mu                      sync.RWMutex
 	getLastParsedBlockCalls atomic.Int32
+	inFlight                atomic.Int32
+	maxInFlight             atomic.Int32
 }
 
 func (m *MockBlockchainClient) GetLatestBlockNumber(ctx context.Context) (int64, error) {
@@ -38,11 +55,79 @@ func (m *MockBlockchainClient) GetLatestBlockNumber(ctx context.Context) (int64,
 }
 
 func (m *MockBlockchainClient) GetBlockByNumber(ctx context.Context, number int64) (*api.Block, error) {
+	inFlight := m.inFlight.Add(1)
+	defer m.inFlight.Add(-1)
+
+	for {
+		maxInFlight := m.maxInFlight.Load()
+		if inFlight <= maxInFlight || m.maxInFlight.CompareAndSwap(maxInFlight, inFlight) {
+			break
+		}
+	}
+
+	if m.blockDelay != nil {
+		time.Sleep(m.blockDelay(number))
+	}
+
 	m.mu.RLock()
 	defer m.mu.RUnlock()
+	if err, ok := m.blockErrors[number]; ok {
+		return nil, err
+	}
 	return m.blocks[number], nil
 }
 
+// setBlockError makes fetching the given block fail, a nil error makes it succeed again
+func (m *MockBlockchainClient) setBlockError(number int64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.blockErrors == nil {
+		m.blockErrors = make(map[int64]error)
+	}
+
+	if err == nil {
+		delete(m.blockErrors, number)
+		return
+	}
+
+	m.blockErrors[number] = err
+}
+
+// reorg replaces the mock's chain, simulating a reorganization
+func (m *MockBlockchainClient) reorg(latestBlockNumber int64, blocks map[int64]*api.Block) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for number, block := range blocks {
+		m.blocks[number] = block
+	}
+
+	m.latestBlockNumber = latestBlockNumber
+}
+
+// waitFor polls the condition until it holds or the timeout elapses
+func waitFor(timeout time.Duration, condition func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if condition() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return condition()
+}
+
+// allTransactions returns the transactions of the address along with the orphaned ones,
+// which GetTransactions leaves out
+func allTransactions(ctx context.Context, repo repository.TransactionRepository, address string) []api.Transaction {
+	txs, _ := repo.GetTransactions(ctx, address)
+	orphaned, _ := repo.QueryTransactions(ctx, address, api.TransactionQuery{Status: api.StatusOrphaned})
+
+	return append(txs, orphaned.Transactions...)
+}
+
 func TestNewParserWorker(t *testing.T) {
 	mockBC := &MockBlockchainClient{}
 
@@ -65,10 +150,13 @@ func TestParserWorker_Run(t *testing.T) {
 		initialBlockNumber: 0,
 		latestBlockNumber:  10,
 		blocks: map[int64]*api.Block{
-			1: {Number: 1, Transactions: []api.Transaction{{From: "0x1", To: "0x2", Hash: "0x999", Value: 100}}},
-			2: {Number: 2, Transactions: []api.Transaction{{From: "0x2", To: "0x3", Hash: "0x888", Value: 200}}},
+			1: {Number: 1, Transactions: []api.Transaction{{From: "0x1", To: "0x2", Hash: "0x999", Value: api.NewBigInt(100)}}},
+			2: {Number: 2, Transactions: []api.Transaction{{From: "0x2", To: "0x3", Hash: "0x888", Value: api.NewBigInt(200)}}},
 		},
 	}
+	for i := int64(3); i <= 10; i++ {
+		mockBC.blocks[i] = &api.Block{Number: i}
+	}
 
 	mockTxRepo := repository.NewInMemoryTransactionRepository()
 	mockSubRepo := repository.NewInMemorySubscriberRepository()
@@ -115,3 +203,1519 @@ func TestParserWorker_Run(t *testing.T) {
 		}
 	}
 }
+
+func TestParserWorker_Reorg(t *testing.T) {
+	mockBC := &MockBlockchainClient{
+		initialBlockNumber: 0,
+		latestBlockNumber:  3,
+		blocks: map[int64]*api.Block{
+			1: {Number: 1, Hash: "0xa1", ParentHash: "0xa0", Transactions: []api.Transaction{{From: "0x1", To: "0x2", Hash: "0x111", BlockHash: "0xa1"}}},
+			2: {Number: 2, Hash: "0xa2", ParentHash: "0xa1", Transactions: []api.Transaction{{From: "0x1", To: "0x2", Hash: "0x222", BlockHash: "0xa2"}}},
+			3: {Number: 3, Hash: "0xa3", ParentHash: "0xa2", Transactions: []api.Transaction{{From: "0x1", To: "0x2", Hash: "0x333", BlockHash: "0xa3"}}},
+		},
+	}
+
+	mockTxRepo := repository.NewInMemoryTransactionRepository()
+	mockSubRepo := repository.NewInMemorySubscriberRepository()
+	mockBlockRepo := repository.NewInMemoryBlockRepository()
+
+	worker := worker.
+		NewParserWorker(mockBC, mockTxRepo, mockSubRepo, mockBlockRepo).
+		WithConfirmationDepth(1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	mockSubRepo.Subscribe(ctx, "0x1")
+
+	go worker.Run(ctx, 50*time.Millisecond)
+
+	hashes := func() map[string]string {
+		txs := allTransactions(ctx, mockTxRepo, "0x1")
+		result := make(map[string]string, len(txs))
+		for _, tx := range txs {
+			result[tx.Hash] = fmt.Sprintf("%s %s", tx.BlockHash, tx.Status)
+		}
+		return result
+	}
+
+	if !waitFor(time.Second, func() bool { return len(hashes()) == 3 }) {
+		t.Fatalf("Expected 3 transactions before the reorg, got %v", hashes())
+	}
+
+	// blocks 2 and 3 are replaced by a fork that is extended by block 4
+	mockBC.reorg(4, map[int64]*api.Block{
+		2: {Number: 2, Hash: "0xb2", ParentHash: "0xa1", Transactions: []api.Transaction{{From: "0x1", To: "0x2", Hash: "0x222", BlockHash: "0xb2"}}},
+		3: {Number: 3, Hash: "0xb3", ParentHash: "0xb2"},
+		4: {Number: 4, Hash: "0xb4", ParentHash: "0xb3", Transactions: []api.Transaction{{From: "0x1", To: "0x2", Hash: "0x444", BlockHash: "0xb4"}}},
+	})
+
+	// the transaction included again in the fork replaces its orphaned copy
+	expected := map[string]string{
+		"0x111": "0xa1 confirmed",
+		"0x222": "0xb2 confirmed",
+		"0x333": "0xa3 orphaned",
+		"0x444": "0xb4 confirmed",
+	}
+
+	if !waitFor(time.Second, func() bool { return reflect.DeepEqual(hashes(), expected) }) {
+		t.Errorf("Expected transactions %v after the reorg, got %v", expected, hashes())
+	}
+}
+
+func TestParserWorker_Resume(t *testing.T) {
+	blocks := map[int64]*api.Block{
+		2: {Number: 2, Transactions: []api.Transaction{{From: "0x1", To: "0x2", Hash: "0x222"}}},
+		3: {Number: 3},
+		4: {Number: 4},
+		5: {Number: 5, Transactions: []api.Transaction{{From: "0x1", To: "0x2", Hash: "0x555"}}},
+		6: {Number: 6, Transactions: []api.Transaction{{From: "0x1", To: "0x2", Hash: "0x666"}}},
+	}
+
+	cases := []struct {
+		name       string
+		checkpoint int64
+		startBlock int64
+		expected   []string
+	}{
+		{"resumes from checkpoint", 4, 0, []string{"0x555", "0x666"}},
+		{"checkpoint takes precedence over start block", 4, 1, []string{"0x555", "0x666"}},
+		{"starts from start block without checkpoint", 0, 2, []string{"0x222", "0x555", "0x666"}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			mockBC := &MockBlockchainClient{
+				initialBlockNumber: 6,
+				latestBlockNumber:  6,
+				blocks:             blocks,
+			}
+
+			mockTxRepo := repository.NewInMemoryTransactionRepository()
+			mockSubRepo := repository.NewInMemorySubscriberRepository()
+			mockBlockRepo := repository.NewInMemoryBlockRepository()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			mockSubRepo.Subscribe(ctx, "0x1")
+			if c.checkpoint > 0 {
+				mockBlockRepo.UpdateLastParsedBlock(ctx, c.checkpoint)
+			}
+
+			worker := worker.
+				NewParserWorker(mockBC, mockTxRepo, mockSubRepo, mockBlockRepo).
+				WithStartBlock(c.startBlock)
+
+			go worker.Run(ctx, 50*time.Millisecond)
+
+			hashes := func() []string {
+				txs, _ := mockTxRepo.GetTransactions(ctx, "0x1")
+				result := make([]string, 0, len(txs))
+				for _, tx := range txs {
+					result = append(result, tx.Hash)
+				}
+				sort.Strings(result)
+				return result
+			}
+
+			if !waitFor(time.Second, func() bool { return reflect.DeepEqual(hashes(), c.expected) }) {
+				t.Errorf("Expected transactions %v, got %v", c.expected, hashes())
+			}
+		})
+	}
+}
+
+func TestParserWorker_DeadLetter(t *testing.T) {
+	mockBC := &MockBlockchainClient{
+		initialBlockNumber: 0,
+		latestBlockNumber:  3,
+		blocks: map[int64]*api.Block{
+			1: {Number: 1, Transactions: []api.Transaction{{From: "0x1", To: "0x2", Hash: "0x111"}}},
+			2: {Number: 2, Transactions: []api.Transaction{{From: "0x1", To: "0x2", Hash: "0x222"}}},
+			3: {Number: 3, Transactions: []api.Transaction{{From: "0x1", To: "0x2", Hash: "0x333"}}},
+		},
+	}
+	mockBC.setBlockError(2, errors.New("node unavailable"))
+
+	mockTxRepo := repository.NewInMemoryTransactionRepository()
+	mockSubRepo := repository.NewInMemorySubscriberRepository()
+	mockBlockRepo := repository.NewInMemoryBlockRepository()
+
+	worker := worker.
+		NewParserWorker(mockBC, mockTxRepo, mockSubRepo, mockBlockRepo).
+		WithDeadLetterRetries(50*time.Millisecond, 100)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	mockSubRepo.Subscribe(ctx, "0x1")
+
+	go worker.Run(ctx, 50*time.Millisecond)
+
+	// the failed block is dead-lettered and the checkpoint moves over it
+	if !waitFor(3*time.Second, func() bool {
+		failed, _ := mockBlockRepo.GetFailedBlocks(ctx)
+		last, _ := mockBlockRepo.GetLastParsedBlock(ctx)
+		return len(failed) == 1 && failed[0].Number == 2 && last == 3
+	}) {
+		failed, _ := mockBlockRepo.GetFailedBlocks(ctx)
+		t.Fatalf("Expected block 2 to be dead-lettered, got %+v", failed)
+	}
+
+	if txs, _ := mockTxRepo.GetTransactions(ctx, "0x1"); len(txs) != 2 {
+		t.Errorf("Expected 2 transactions while block 2 is failing, got %d", len(txs))
+	}
+
+	// once the node recovers, the dead letter loop re-ingests the block
+	mockBC.setBlockError(2, nil)
+
+	if !waitFor(time.Second, func() bool {
+		failed, _ := mockBlockRepo.GetFailedBlocks(ctx)
+		return len(failed) == 0
+	}) {
+		t.Fatal("Expected block 2 to be removed from the failed blocks")
+	}
+
+	if txs, _ := mockTxRepo.GetTransactions(ctx, "0x1"); len(txs) != 3 {
+		t.Errorf("Expected 3 transactions after recovery, got %d", len(txs))
+	}
+}
+
+// TestParserWorker_DeadLetterOldBlock retries a block behind the recent headers,
+// either below the header window or within it
+func TestParserWorker_DeadLetterOldBlock(t *testing.T) {
+	for _, depth := range []int{2, worker.DefaultMaxReorgDepth} {
+		t.Run(strconv.Itoa(depth), func(t *testing.T) {
+			blocks := make(map[int64]*api.Block)
+			for i := int64(1); i <= 6; i++ {
+				blocks[i] = &api.Block{
+					Number:       i,
+					Hash:         fmt.Sprintf("0xb%d", i),
+					ParentHash:   fmt.Sprintf("0xb%d", i-1),
+					Transactions: []api.Transaction{{From: "0x1", To: "0x2", Hash: fmt.Sprintf("0x%d00", i)}},
+				}
+			}
+
+			mockBC := &MockBlockchainClient{
+				initialBlockNumber: 0,
+				latestBlockNumber:  6,
+				blocks:             blocks,
+			}
+			mockBC.setBlockError(2, errors.New("node unavailable"))
+
+			mockTxRepo := repository.NewInMemoryTransactionRepository()
+			mockSubRepo := repository.NewInMemorySubscriberRepository()
+			mockBlockRepo := repository.NewInMemoryBlockRepository()
+
+			worker := worker.
+				NewParserWorker(mockBC, mockTxRepo, mockSubRepo, mockBlockRepo).
+				WithMaxReorgDepth(depth).
+				WithDeadLetterRetries(50*time.Millisecond, 100)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			mockSubRepo.Subscribe(ctx, "0x1")
+
+			go worker.Run(ctx, 50*time.Millisecond)
+
+			if !waitFor(3*time.Second, func() bool {
+				failed, _ := mockBlockRepo.GetFailedBlocks(ctx)
+				last, _ := mockBlockRepo.GetLastParsedBlock(ctx)
+				return len(failed) == 1 && failed[0].Number == 2 && last == 6
+			}) {
+				failed, _ := mockBlockRepo.GetFailedBlocks(ctx)
+				t.Fatalf("Expected block 2 to be dead-lettered, got %+v", failed)
+			}
+
+			// by the time block 2 is retried it does not build on the block 1 that was parsed,
+			// which must not be taken for a reorg of the recent blocks
+			mockBC.reorg(6, map[int64]*api.Block{2: {
+				Number:       2,
+				Hash:         "0xb2",
+				ParentHash:   "0xother",
+				Transactions: []api.Transaction{{From: "0x1", To: "0x2", Hash: "0x200"}},
+			}})
+			mockBC.setBlockError(2, nil)
+
+			if !waitFor(time.Second, func() bool {
+				failed, _ := mockBlockRepo.GetFailedBlocks(ctx)
+				return len(failed) == 0
+			}) {
+				t.Fatal("Expected block 2 to be removed from the failed blocks")
+			}
+
+			if last, _ := mockBlockRepo.GetLastParsedBlock(ctx); last != 6 {
+				t.Errorf("Expected the checkpoint to stay at block 6, got %d", last)
+			}
+
+			statuses := func() map[string]api.TransactionStatus {
+				txs := allTransactions(ctx, mockTxRepo, "0x1")
+				result := make(map[string]api.TransactionStatus, len(txs))
+				for _, tx := range txs {
+					result[tx.Hash] = tx.Status
+				}
+				return result
+			}
+
+			for hash, status := range statuses() {
+				if status == api.StatusOrphaned {
+					t.Errorf("Expected transaction %s to not be orphaned", hash)
+				}
+			}
+
+			// the recent headers are intact, so a reorg of block 6 is still detected
+			mockBC.reorg(7, map[int64]*api.Block{
+				6: {Number: 6, Hash: "0xc6", ParentHash: "0xb5", Transactions: []api.Transaction{{From: "0x1", To: "0x2", Hash: "0x601"}}},
+				7: {Number: 7, Hash: "0xc7", ParentHash: "0xc6", Transactions: []api.Transaction{{From: "0x1", To: "0x2", Hash: "0x700"}}},
+			})
+
+			if !waitFor(time.Second, func() bool {
+				last, _ := mockBlockRepo.GetLastParsedBlock(ctx)
+				return last == 7
+			}) {
+				t.Fatal("Expected block 7 to be parsed")
+			}
+
+			result := statuses()
+			if len(result) != 8 || result["0x600"] != api.StatusOrphaned || result["0x601"] == api.StatusOrphaned || result["0x700"] == api.StatusOrphaned {
+				t.Errorf("Expected only the transaction of the replaced block 6 to be orphaned, got %v", result)
+			}
+		})
+	}
+}
+
+func TestParserWorker_MissingBlock(t *testing.T) {
+	mockBC := &MockBlockchainClient{
+		initialBlockNumber: 0,
+		latestBlockNumber:  3,
+		blocks: map[int64]*api.Block{
+			1: {Number: 1, Transactions: []api.Transaction{{From: "0x1", To: "0x2", Hash: "0x111"}}},
+			3: {Number: 3, Transactions: []api.Transaction{{From: "0x1", To: "0x2", Hash: "0x333"}}},
+		},
+	}
+
+	mockTxRepo := repository.NewInMemoryTransactionRepository()
+	mockSubRepo := repository.NewInMemorySubscriberRepository()
+	mockBlockRepo := repository.NewInMemoryBlockRepository()
+
+	worker := worker.
+		NewParserWorker(mockBC, mockTxRepo, mockSubRepo, mockBlockRepo).
+		WithDeadLetterRetries(50*time.Millisecond, 100)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	mockSubRepo.Subscribe(ctx, "0x1")
+
+	go worker.Run(ctx, 50*time.Millisecond)
+
+	// the node does not return block 2 yet, the checkpoint stays before it and it is not dead-lettered
+	if !waitFor(time.Second, func() bool {
+		last, _ := mockBlockRepo.GetLastParsedBlock(ctx)
+		return last == 1
+	}) {
+		t.Fatal("Expected last parsed block to reach 1")
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	if last, _ := mockBlockRepo.GetLastParsedBlock(ctx); last != 1 {
+		t.Errorf("Expected last parsed block to stay 1, got %d", last)
+	}
+
+	if failed, _ := mockBlockRepo.GetFailedBlocks(ctx); len(failed) != 0 {
+		t.Errorf("Expected no failed blocks, got %+v", failed)
+	}
+
+	mockBC.mu.Lock()
+	mockBC.blocks[2] = &api.Block{Number: 2, Transactions: []api.Transaction{{From: "0x1", To: "0x2", Hash: "0x222"}}}
+	mockBC.mu.Unlock()
+
+	if !waitFor(time.Second, func() bool {
+		last, _ := mockBlockRepo.GetLastParsedBlock(ctx)
+		failed, _ := mockBlockRepo.GetFailedBlocks(ctx)
+		return last == 3 && len(failed) == 0
+	}) {
+		t.Fatal("Expected every block to be parsed once block 2 is available")
+	}
+
+	if txs, _ := mockTxRepo.GetTransactions(ctx, "0x1"); len(txs) != 3 {
+		t.Errorf("Expected 3 transactions, got %d", len(txs))
+	}
+}
+
+func TestParserWorker_BlockNotAvailable(t *testing.T) {
+	alice := simchain.Address("alice")
+
+	chain := simchain.New(simchain.WithAddresses(alice, simchain.Address("bob")), simchain.WithTransactionsPerBlock(2))
+	chain.Mine(2)
+
+	// the node reports a head it does not serve yet, answering null for it as nodes do
+	var block3Requests atomic.Int32
+
+	handler := chain.Handler()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), `"eth_getBlockByNumber","params":["0x3"`) {
+			block3Requests.Add(1)
+		}
+
+		if strings.Contains(string(body), "eth_blockNumber") {
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":1,"result":"0x%x"}`, chain.Head()+1)
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	mockTxRepo := repository.NewInMemoryTransactionRepository()
+	mockSubRepo := repository.NewInMemorySubscriberRepository()
+	mockBlockRepo := repository.NewInMemoryBlockRepository()
+
+	worker := worker.
+		NewParserWorker(blockchain.NewPublicNodeClient(server.URL, log.Default()), mockTxRepo, mockSubRepo, mockBlockRepo).
+		WithStartBlock(1).
+		WithDeadLetterRetries(50*time.Millisecond, 100)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	mockSubRepo.Subscribe(ctx, alice)
+
+	go worker.Run(ctx, 50*time.Millisecond)
+
+	if !waitFor(3*time.Second, func() bool {
+		last, _ := mockBlockRepo.GetLastParsedBlock(ctx)
+		return last == 2
+	}) {
+		t.Fatal("Expected last parsed block to reach 2")
+	}
+
+	// wait until block 3 is fetched again after the retries of the first attempt ran out
+	if !waitFor(5*time.Second, func() bool { return block3Requests.Load() > retry.DefaultMaxAttempts }) {
+		t.Fatalf("Expected block 3 to be fetched again, got %d requests", block3Requests.Load())
+	}
+
+	// block 3 is not available yet, it is neither dead-lettered nor skipped
+	if last, _ := mockBlockRepo.GetLastParsedBlock(ctx); last != 2 {
+		t.Errorf("Expected last parsed block to stay 2, got %d", last)
+	}
+
+	if failed, _ := mockBlockRepo.GetFailedBlocks(ctx); len(failed) != 0 {
+		t.Errorf("Expected no failed blocks, got %+v", failed)
+	}
+
+	chain.Mine(1)
+
+	if !waitFor(3*time.Second, func() bool {
+		last, _ := mockBlockRepo.GetLastParsedBlock(ctx)
+		return last >= 3
+	}) {
+		t.Fatal("Expected block 3 to be parsed once the node has it")
+	}
+
+	if failed, _ := mockBlockRepo.GetFailedBlocks(ctx); len(failed) != 0 {
+		t.Errorf("Expected no failed blocks, got %+v", failed)
+	}
+}
+
+func TestParserWorker_Pipeline(t *testing.T) {
+	const numBlocks = 60
+	const concurrency = 3
+
+	blocks := make(map[int64]*api.Block, numBlocks)
+	for i := int64(1); i <= numBlocks; i++ {
+		blocks[i] = &api.Block{Number: i, Transactions: []api.Transaction{
+			{From: "0x1", To: "0x2", Hash: fmt.Sprintf("0x%d-0", i), TransactionIndex: 0},
+			{From: "0x2", To: "0x1", Hash: fmt.Sprintf("0x%d-1", i), TransactionIndex: 1},
+		}}
+	}
+
+	mockBC := &MockBlockchainClient{
+		initialBlockNumber: 0,
+		latestBlockNumber:  numBlocks,
+		blocks:             blocks,
+		// later blocks of each group come back first to force out of order fetches
+		blockDelay: func(number int64) time.Duration {
+			return time.Duration(5-number%5) * time.Millisecond
+		},
+	}
+
+	mockTxRepo := repository.NewInMemoryTransactionRepository()
+	mockSubRepo := repository.NewInMemorySubscriberRepository()
+	mockBlockRepo := repository.NewInMemoryBlockRepository()
+
+	worker := worker.
+		NewParserWorker(mockBC, mockTxRepo, mockSubRepo, mockBlockRepo).
+		WithConcurrency(concurrency)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	mockSubRepo.Subscribe(ctx, "0x1")
+
+	go worker.Run(ctx, 50*time.Millisecond)
+
+	if !waitFor(2*time.Second, func() bool {
+		last, _ := mockBlockRepo.GetLastParsedBlock(ctx)
+		return last == numBlocks
+	}) {
+		t.Fatal("Expected all blocks to be parsed")
+	}
+
+	if maxInFlight := mockBC.maxInFlight.Load(); maxInFlight > concurrency {
+		t.Errorf("Expected at most %d concurrent fetches, got %d", concurrency, maxInFlight)
+	}
+
+	txs, _ := mockTxRepo.GetTransactions(ctx, "0x1")
+	if len(txs) != 2*numBlocks {
+		t.Fatalf("Expected %d transactions, got %d", 2*numBlocks, len(txs))
+	}
+
+	for i, tx := range txs {
+		expected := fmt.Sprintf("0x%d-%d", i/2+1, i%2)
+		if tx.Hash != expected {
+			t.Fatalf("Expected transaction %s at position %d, got %s", expected, i, tx.Hash)
+		}
+	}
+}
+
+// finalityBlockchainClient adds the node's finality tags to the mock
+type finalityBlockchainClient struct {
+	*MockBlockchainClient
+	safe      atomic.Int64
+	finalized atomic.Int64
+}
+
+func (m *finalityBlockchainClient) GetBlockNumberByTag(ctx context.Context, tag string) (int64, error) {
+	if tag == "safe" {
+		return m.safe.Load(), nil
+	}
+	return m.finalized.Load(), nil
+}
+
+func TestParserWorker_Finality(t *testing.T) {
+	blocks := make(map[int64]*api.Block)
+	for i := int64(1); i <= 5; i++ {
+		hash := fmt.Sprintf("0x%d", i)
+		blocks[i] = &api.Block{Number: i, Hash: hash, Transactions: []api.Transaction{{From: "0x1", To: "0x2", Hash: fmt.Sprintf("0x%d00", i), BlockHash: hash}}}
+	}
+
+	mockBC := &finalityBlockchainClient{
+		MockBlockchainClient: &MockBlockchainClient{initialBlockNumber: 0, latestBlockNumber: 5, blocks: blocks},
+	}
+
+	mockTxRepo := repository.NewInMemoryTransactionRepository()
+	mockSubRepo := repository.NewInMemorySubscriberRepository()
+	mockBlockRepo := repository.NewInMemoryBlockRepository()
+
+	worker := worker.
+		NewParserWorker(mockBC, mockTxRepo, mockSubRepo, mockBlockRepo).
+		WithConfirmationDepth(3).
+		WithFinalityTags(true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	mockSubRepo.Subscribe(ctx, "0x1")
+
+	go worker.Run(ctx, 50*time.Millisecond)
+
+	statuses := func() []api.TransactionStatus {
+		txs, _ := mockTxRepo.GetTransactions(ctx, "0x1")
+		result := make([]api.TransactionStatus, len(blocks))
+		for _, tx := range txs {
+			number, _ := strconv.Atoi(tx.BlockHash[2:])
+			result[number-1] = tx.Status
+		}
+		return result
+	}
+
+	// blocks 4 and 5 do not have 3 confirmations yet
+	expected := []api.TransactionStatus{api.StatusConfirmed, api.StatusConfirmed, api.StatusConfirmed, api.StatusPending, api.StatusPending}
+	if !waitFor(time.Second, func() bool { return reflect.DeepEqual(statuses(), expected) }) {
+		t.Fatalf("Expected statuses %v, got %v", expected, statuses())
+	}
+
+	// the node reports block 2 as finalized and block 4 as safe
+	mockBC.finalized.Store(2)
+	mockBC.safe.Store(4)
+
+	expected = []api.TransactionStatus{api.StatusFinalized, api.StatusFinalized, api.StatusConfirmed, api.StatusConfirmed, api.StatusPending}
+	if !waitFor(time.Second, func() bool { return reflect.DeepEqual(statuses(), expected) }) {
+		t.Fatalf("Expected statuses %v, got %v", expected, statuses())
+	}
+}
+
+func TestParserWorker_FinalityAfterRestart(t *testing.T) {
+	blocks := make(map[int64]*api.Block)
+	for i := int64(301); i <= 305; i++ {
+		blocks[i] = &api.Block{Number: i, Hash: fmt.Sprintf("0x%d", i)}
+	}
+
+	mockBC := &finalityBlockchainClient{
+		MockBlockchainClient: &MockBlockchainClient{initialBlockNumber: 300, latestBlockNumber: 305, blocks: blocks},
+	}
+	mockBC.finalized.Store(250)
+
+	mockTxRepo := repository.NewInMemoryTransactionRepository()
+	mockSubRepo := repository.NewInMemorySubscriberRepository()
+	mockBlockRepo := repository.NewInMemoryBlockRepository()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// stored before the restart, or by a backfill, far behind the recorded headers
+	mockTxRepo.SaveTransaction(ctx, "0x1", api.Transaction{Hash: "0x100", BlockNumber: 100, BlockHash: "0x100", Status: api.StatusPending})
+	mockTxRepo.SaveTransaction(ctx, "0x1", api.Transaction{Hash: "0x290", BlockNumber: 290, BlockHash: "0x290", Status: api.StatusPending})
+	mockTxRepo.SaveTransaction(ctx, "0x1", api.Transaction{Hash: "0x300", BlockNumber: 300, BlockHash: "0x300", Status: api.StatusPending})
+	mockBlockRepo.UpdateLastParsedBlock(ctx, 300)
+
+	worker := worker.
+		NewParserWorker(mockBC, mockTxRepo, mockSubRepo, mockBlockRepo).
+		WithConfirmationDepth(10).
+		WithFinalityTags(true)
+
+	go worker.Run(ctx, 50*time.Millisecond)
+
+	statuses := func() []api.TransactionStatus {
+		txs, _ := mockTxRepo.GetTransactions(ctx, "0x1")
+		result := []api.TransactionStatus{}
+		for _, tx := range txs {
+			result = append(result, tx.Status)
+		}
+		return result
+	}
+
+	// block 100 is finalized, block 290 has 16 confirmations and block 300 only 6
+	expected := []api.TransactionStatus{api.StatusFinalized, api.StatusConfirmed, api.StatusPending}
+	if !waitFor(time.Second, func() bool { return reflect.DeepEqual(statuses(), expected) }) {
+		t.Fatalf("Expected statuses %v, got %v", expected, statuses())
+	}
+}
+
+// rangeBlockchainClient adds batch fetching to the mock, block 7 is always missing from batches
+type rangeBlockchainClient struct {
+	*MockBlockchainClient
+	rangeCalls atomic.Int32
+}
+
+func (m *rangeBlockchainClient) GetBlocksByRange(ctx context.Context, from, to int64) ([]*api.Block, error) {
+	m.rangeCalls.Add(1)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	blocks := make([]*api.Block, to-from+1)
+	batchErr := &blockchain.BatchError{Errors: map[int64]error{}}
+	for number := from; number <= to; number++ {
+		if number == 7 {
+			batchErr.Errors[number] = errors.New("missing from batch")
+			continue
+		}
+		blocks[number-from] = m.blocks[number]
+	}
+
+	return blocks, batchErr
+}
+
+func TestParserWorker_Batches(t *testing.T) {
+	const numBlocks = 25
+
+	blocks := make(map[int64]*api.Block, numBlocks)
+	for i := int64(1); i <= numBlocks; i++ {
+		blocks[i] = &api.Block{Number: i, Transactions: []api.Transaction{{From: "0x1", To: "0x2", Hash: fmt.Sprintf("0x%d", i)}}}
+	}
+
+	mockBC := &rangeBlockchainClient{
+		MockBlockchainClient: &MockBlockchainClient{initialBlockNumber: 0, latestBlockNumber: numBlocks, blocks: blocks},
+	}
+
+	mockTxRepo := repository.NewInMemoryTransactionRepository()
+	mockSubRepo := repository.NewInMemorySubscriberRepository()
+	mockBlockRepo := repository.NewInMemoryBlockRepository()
+
+	worker := worker.
+		NewParserWorker(mockBC, mockTxRepo, mockSubRepo, mockBlockRepo).
+		WithConcurrency(2).
+		WithBatchSize(10)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	mockSubRepo.Subscribe(ctx, "0x1")
+
+	go worker.Run(ctx, 50*time.Millisecond)
+
+	if !waitFor(2*time.Second, func() bool {
+		txs, _ := mockTxRepo.GetTransactions(ctx, "0x1")
+		return len(txs) == numBlocks
+	}) {
+		t.Fatal("Expected every block to be parsed, including the one missing from its batch")
+	}
+
+	if calls := mockBC.rangeCalls.Load(); calls != 3 {
+		t.Errorf("Expected 3 batches, got %d", calls)
+	}
+}
+
+// unavailableRangeClient fails its first batches as a whole, as a node rate limiting or down would,
+// and counts the blocks fetched one by one
+type unavailableRangeClient struct {
+	*MockBlockchainClient
+	failures    atomic.Int32
+	rangeCalls  atomic.Int32
+	singleCalls atomic.Int32
+}
+
+func (m *unavailableRangeClient) GetBlockByNumber(ctx context.Context, number int64) (*api.Block, error) {
+	m.singleCalls.Add(1)
+
+	return m.MockBlockchainClient.GetBlockByNumber(ctx, number)
+}
+
+func (m *unavailableRangeClient) GetBlocksByRange(ctx context.Context, from, to int64) ([]*api.Block, error) {
+	m.rangeCalls.Add(1)
+
+	if m.failures.Add(-1) >= 0 {
+		return nil, errors.New("connection reset by peer")
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	blocks := make([]*api.Block, 0, to-from+1)
+	for number := from; number <= to; number++ {
+		blocks = append(blocks, m.blocks[number])
+	}
+
+	return blocks, nil
+}
+
+func TestParserWorker_BatchFailedAsWhole(t *testing.T) {
+	const numBlocks = 10
+
+	blocks := make(map[int64]*api.Block, numBlocks)
+	for i := int64(1); i <= numBlocks; i++ {
+		blocks[i] = &api.Block{Number: i, Transactions: []api.Transaction{{From: "0x1", To: "0x2", Hash: fmt.Sprintf("0x%d", i)}}}
+	}
+
+	mockBC := &unavailableRangeClient{
+		MockBlockchainClient: &MockBlockchainClient{initialBlockNumber: 0, latestBlockNumber: numBlocks, blocks: blocks},
+	}
+	mockBC.failures.Store(2)
+
+	mockTxRepo := repository.NewInMemoryTransactionRepository()
+	mockSubRepo := repository.NewInMemorySubscriberRepository()
+	mockBlockRepo := repository.NewInMemoryBlockRepository()
+
+	worker := worker.
+		NewParserWorker(mockBC, mockTxRepo, mockSubRepo, mockBlockRepo).
+		WithConcurrency(1).
+		WithBatchSize(numBlocks)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	mockSubRepo.Subscribe(ctx, "0x1")
+
+	go worker.Run(ctx, 50*time.Millisecond)
+
+	if !waitFor(2*time.Second, func() bool {
+		txs, _ := mockTxRepo.GetTransactions(ctx, "0x1")
+		return len(txs) == numBlocks
+	}) {
+		t.Fatal("Expected every block to be parsed once the batches succeed")
+	}
+
+	// the batch is retried as a whole, its blocks are not fetched one by one
+	if calls := mockBC.rangeCalls.Load(); calls != 3 {
+		t.Errorf("Expected 3 attempts of the batch, got %d", calls)
+	}
+
+	if calls := mockBC.singleCalls.Load(); calls != 0 {
+		t.Errorf("Expected no block to be fetched on its own, got %d", calls)
+	}
+}
+
+// headsBlockchainClient adds pushed new heads to the mock
+type headsBlockchainClient struct {
+	*MockBlockchainClient
+	heads chan int64
+}
+
+func (m *headsBlockchainClient) SubscribeNewHeads(ctx context.Context) (<-chan int64, error) {
+	return m.heads, nil
+}
+
+func TestParserWorker_NewHeads(t *testing.T) {
+	blocks := make(map[int64]*api.Block)
+	for i := int64(1); i <= 3; i++ {
+		blocks[i] = &api.Block{Number: i, Transactions: []api.Transaction{{From: "0x1", To: "0x2", Hash: fmt.Sprintf("0x%d00", i)}}}
+	}
+
+	mockBC := &headsBlockchainClient{
+		MockBlockchainClient: &MockBlockchainClient{initialBlockNumber: 0, latestBlockNumber: 0, blocks: blocks},
+		heads:                make(chan int64),
+	}
+
+	mockTxRepo := repository.NewInMemoryTransactionRepository()
+	mockSubRepo := repository.NewInMemorySubscriberRepository()
+	mockBlockRepo := repository.NewInMemoryBlockRepository()
+
+	worker := worker.NewParserWorker(mockBC, mockTxRepo, mockSubRepo, mockBlockRepo)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	mockSubRepo.Subscribe(ctx, "0x1")
+
+	// polling would only kick in after an hour, so only pushed heads can trigger parsing
+	go worker.Run(ctx, time.Hour)
+
+	for head := int64(1); head <= 3; head++ {
+		mockBC.heads <- head
+
+		if !waitFor(time.Second, func() bool {
+			val, _ := mockBlockRepo.GetLastParsedBlock(ctx)
+			return val == head
+		}) {
+			val, _ := mockBlockRepo.GetLastParsedBlock(ctx)
+			t.Fatalf("Expected last parsed block to be %d, got %d", head, val)
+		}
+	}
+
+	if txs, _ := mockTxRepo.GetTransactions(ctx, "0x1"); len(txs) != 3 {
+		t.Errorf("Expected 3 transactions, got %d", len(txs))
+	}
+}
+
+// flakyBlockchainClient fails the first calls for the latest block number, like a node outage
+type flakyBlockchainClient struct {
+	*MockBlockchainClient
+	failures atomic.Int32
+}
+
+func (m *flakyBlockchainClient) GetLatestBlockNumber(ctx context.Context) (int64, error) {
+	if m.failures.Add(-1) >= 0 {
+		return 0, errors.New("node unavailable")
+	}
+
+	return m.MockBlockchainClient.GetLatestBlockNumber(ctx)
+}
+
+func TestParserWorker_NodeOutage(t *testing.T) {
+	mockBC := &flakyBlockchainClient{
+		MockBlockchainClient: &MockBlockchainClient{
+			initialBlockNumber: 0,
+			latestBlockNumber:  2,
+			blocks: map[int64]*api.Block{
+				1: {Number: 1, Transactions: []api.Transaction{{From: "0x1", To: "0x2", Hash: "0x100"}}},
+				2: {Number: 2, Transactions: []api.Transaction{{From: "0x1", To: "0x2", Hash: "0x200"}}},
+			},
+		},
+	}
+	mockBC.failures.Store(3)
+
+	mockTxRepo := repository.NewInMemoryTransactionRepository()
+	mockSubRepo := repository.NewInMemorySubscriberRepository()
+	mockBlockRepo := repository.NewInMemoryBlockRepository()
+
+	worker := worker.NewParserWorker(mockBC, mockTxRepo, mockSubRepo, mockBlockRepo)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	mockSubRepo.Subscribe(ctx, "0x1")
+
+	errs := make(chan error, 1)
+	go func() { errs <- worker.Run(ctx, 20*time.Millisecond) }()
+
+	// the worker outlives the outage and catches up once the node is back
+	if !waitFor(time.Second, func() bool {
+		val, _ := mockBlockRepo.GetLastParsedBlock(ctx)
+		return val == 2
+	}) {
+		val, _ := mockBlockRepo.GetLastParsedBlock(ctx)
+		t.Fatalf("Expected last parsed block to be 2, got %d", val)
+	}
+
+	cancel()
+
+	if err := <-errs; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected Run to stop with context.Canceled, got %v", err)
+	}
+}
+
+// receiptBlockchainClient adds receipts to the mock, block receipts are only available for block 1
+type receiptBlockchainClient struct {
+	*MockBlockchainClient
+	receipts     map[string]*api.Receipt
+	txReceiptsMu sync.Mutex
+	txReceipts   []string
+}
+
+func (m *receiptBlockchainClient) GetBlockReceipts(ctx context.Context, number int64) ([]*api.Receipt, error) {
+	if number != 1 {
+		return nil, blockchain.ErrMethodNotFound
+	}
+
+	receipts := []*api.Receipt{}
+	for _, tx := range m.blocks[number].Transactions {
+		receipts = append(receipts, m.receipts[tx.Hash])
+	}
+
+	return receipts, nil
+}
+
+func (m *receiptBlockchainClient) GetTransactionReceipt(ctx context.Context, hash string) (*api.Receipt, error) {
+	m.txReceiptsMu.Lock()
+	defer m.txReceiptsMu.Unlock()
+
+	m.txReceipts = append(m.txReceipts, hash)
+
+	return m.receipts[hash], nil
+}
+
+func TestParserWorker_Receipts(t *testing.T) {
+	mockBC := &receiptBlockchainClient{
+		MockBlockchainClient: &MockBlockchainClient{
+			initialBlockNumber: 0,
+			latestBlockNumber:  2,
+			blocks: map[int64]*api.Block{
+				1: {Number: 1, Transactions: []api.Transaction{
+					{From: "0x1", To: "0x2", Hash: "0x100"},
+					{From: "0x3", To: "0x4", Hash: "0x101"},
+					{From: "0x2", To: "0x1", Hash: "0x102"},
+				}},
+				2: {Number: 2, Transactions: []api.Transaction{
+					{From: "0x1", To: "0x2", Hash: "0x200"},
+					{From: "0x1", To: "0x2", Hash: "0x201"},
+				}},
+			},
+		},
+		receipts: map[string]*api.Receipt{
+			"0x100": {TransactionHash: "0x100", Status: api.ReceiptSuccess},
+			"0x101": {TransactionHash: "0x101", Status: api.ReceiptSuccess},
+			"0x102": {TransactionHash: "0x102", Status: api.ReceiptFailed},
+			"0x200": {TransactionHash: "0x200", Status: api.ReceiptSuccess},
+			"0x201": {TransactionHash: "0x201", Status: api.ReceiptFailed},
+		},
+	}
+
+	mockTxRepo := repository.NewInMemoryTransactionRepository()
+	mockSubRepo := repository.NewInMemorySubscriberRepository()
+	mockBlockRepo := repository.NewInMemoryBlockRepository()
+
+	worker := worker.NewParserWorker(mockBC, mockTxRepo, mockSubRepo, mockBlockRepo)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	mockSubRepo.Subscribe(ctx, "0x1")
+
+	go worker.Run(ctx, 50*time.Millisecond)
+
+	if !waitFor(time.Second, func() bool {
+		val, _ := mockBlockRepo.GetLastParsedBlock(ctx)
+		return val == 2
+	}) {
+		t.Fatal("Expected the worker to parse up to block 2")
+	}
+
+	txs, _ := mockTxRepo.GetTransactions(ctx, "0x1")
+	if len(txs) != 4 {
+		t.Fatalf("Expected 4 transactions, got %d", len(txs))
+	}
+
+	failed := []string{}
+	for _, tx := range txs {
+		if tx.Receipt == nil {
+			t.Fatalf("Expected transaction %s to have a receipt", tx.Hash)
+		}
+
+		if tx.Failed() {
+			failed = append(failed, tx.Hash)
+		}
+	}
+
+	sort.Strings(failed)
+	if !reflect.DeepEqual(failed, []string{"0x102", "0x201"}) {
+		t.Errorf("Expected 0x102 and 0x201 to have failed, got %v", failed)
+	}
+
+	// block 1 had its receipts in a single call, block 2 fell back to one call per transaction
+	mockBC.txReceiptsMu.Lock()
+	defer mockBC.txReceiptsMu.Unlock()
+
+	sort.Strings(mockBC.txReceipts)
+	if !reflect.DeepEqual(mockBC.txReceipts, []string{"0x200", "0x201"}) {
+		t.Errorf("Expected the receipts of 0x200 and 0x201 to be fetched one by one, got %v", mockBC.txReceipts)
+	}
+}
+
+type logBlockchainClient struct {
+	*MockBlockchainClient
+	logs map[string][]api.Log // logs by block hash
+}
+
+func (m *logBlockchainClient) GetLogs(ctx context.Context, filter blockchain.LogFilter) ([]api.Log, error) {
+	return m.logs[filter.BlockHash], nil
+}
+
+func TestParserWorker_TokenTransfers(t *testing.T) {
+	alice := "0x00000000000000000000000000000000000a11ce"
+	bob := "0x0000000000000000000000000000000000000b0b"
+	token := "0x000000000000000000000000000000000000c0de"
+
+	transfer := func(txHash string, logIndex uint, from, to string, amount int64) api.Log {
+		return api.Log{
+			Address:         token,
+			Topics:          []string{blockchain.TransferEventTopic, blockchain.AddressTopic(from), blockchain.AddressTopic(to)},
+			Data:            fmt.Sprintf("0x%064x", amount),
+			LogIndex:        logIndex,
+			TransactionHash: txHash,
+		}
+	}
+
+	mockBC := &logBlockchainClient{
+		MockBlockchainClient: &MockBlockchainClient{
+			initialBlockNumber: 0,
+			latestBlockNumber:  2,
+			blocks: map[int64]*api.Block{
+				1: {Number: 1, Hash: "0xb1", Transactions: []api.Transaction{{From: "0x3", To: token, Hash: "0x100"}}},
+				2: {Number: 2, Hash: "0xb2", Transactions: []api.Transaction{{From: "0x3", To: token, Hash: "0x200"}}},
+			},
+		},
+		logs: map[string][]api.Log{
+			"0xb1": {transfer("0x100", 0, bob, alice, 100), transfer("0x100", 1, bob, "0x0000000000000000000000000000000000000003", 5)},
+			"0xb2": {transfer("0x200", 0, alice, bob, 40)},
+		},
+	}
+
+	mockTxRepo := repository.NewInMemoryTransactionRepository()
+	mockSubRepo := repository.NewInMemorySubscriberRepository()
+	mockBlockRepo := repository.NewInMemoryBlockRepository()
+
+	worker := worker.NewParserWorker(mockBC, mockTxRepo, mockSubRepo, mockBlockRepo).
+		WithTokenTransfers(true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	mockSubRepo.Subscribe(ctx, alice)
+
+	go worker.Run(ctx, 50*time.Millisecond)
+
+	if !waitFor(time.Second, func() bool {
+		val, _ := mockBlockRepo.GetLastParsedBlock(ctx)
+		return val == 2
+	}) {
+		t.Fatal("Expected the worker to parse up to block 2")
+	}
+
+	transfers, _ := mockTxRepo.GetTokenTransfers(ctx, alice, "")
+	if len(transfers) != 2 {
+		t.Fatalf("Expected 2 token transfers, got %d", len(transfers))
+	}
+
+	amounts := []string{}
+	for _, transfer := range transfers {
+		if transfer.Contract != token {
+			t.Errorf("Expected the transfer to be of token %s, got %s", token, transfer.Contract)
+		}
+
+		amounts = append(amounts, transfer.Amount.String())
+	}
+
+	sort.Strings(amounts)
+	if !reflect.DeepEqual(amounts, []string{"100", "40"}) {
+		t.Errorf("Expected transfers of 100 and 40, got %v", amounts)
+	}
+
+	// the transfer between other addresses is not stored
+	if transfers, _ := mockTxRepo.GetTokenTransfers(ctx, bob, ""); len(transfers) != 0 {
+		t.Errorf("Expected no token transfers for an address that is not subscribed, got %d", len(transfers))
+	}
+}
+
+func TestParserWorker_NFTTransfers(t *testing.T) {
+	alice := "0x00000000000000000000000000000000000a11ce"
+	bob := "0x0000000000000000000000000000000000000b0b"
+	collection := "0x000000000000000000000000000000000000c0de"
+
+	mockBC := &logBlockchainClient{
+		MockBlockchainClient: &MockBlockchainClient{
+			initialBlockNumber: 0,
+			latestBlockNumber:  1,
+			blocks: map[int64]*api.Block{
+				1: {Number: 1, Hash: "0xb1", Transactions: []api.Transaction{{From: "0x3", To: collection, Hash: "0x100"}}},
+			},
+		},
+		logs: map[string][]api.Log{
+			"0xb1": {
+				// ERC-20 transfer
+				{
+					Address:         collection,
+					Topics:          []string{blockchain.TransferEventTopic, blockchain.AddressTopic(bob), blockchain.AddressTopic(alice)},
+					Data:            fmt.Sprintf("0x%064x", 100),
+					TransactionHash: "0x100",
+					LogIndex:        0,
+				},
+				// ERC-721 transfer of token 42
+				{
+					Address:         collection,
+					Topics:          []string{blockchain.TransferEventTopic, blockchain.AddressTopic(bob), blockchain.AddressTopic(alice), fmt.Sprintf("0x%064x", 42)},
+					Data:            "0x",
+					TransactionHash: "0x100",
+					LogIndex:        1,
+				},
+				// ERC-1155 transfer of 3 of token 9
+				{
+					Address:         collection,
+					Topics:          []string{blockchain.TransferSingleEventTopic, blockchain.AddressTopic(bob), blockchain.AddressTopic(alice), blockchain.AddressTopic(bob)},
+					Data:            fmt.Sprintf("0x%064x%064x", 9, 3),
+					TransactionHash: "0x100",
+					LogIndex:        2,
+				},
+			},
+		},
+	}
+
+	mockTxRepo := repository.NewInMemoryTransactionRepository()
+	mockSubRepo := repository.NewInMemorySubscriberRepository()
+	mockBlockRepo := repository.NewInMemoryBlockRepository()
+
+	worker := worker.NewParserWorker(mockBC, mockTxRepo, mockSubRepo, mockBlockRepo).
+		WithNFTTransfers(true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	mockSubRepo.Subscribe(ctx, alice)
+
+	go worker.Run(ctx, 50*time.Millisecond)
+
+	if !waitFor(time.Second, func() bool {
+		val, _ := mockBlockRepo.GetLastParsedBlock(ctx)
+		return val == 1
+	}) {
+		t.Fatal("Expected the worker to parse up to block 1")
+	}
+
+	transfers, _ := mockTxRepo.GetNFTTransfers(ctx, alice, "")
+	if len(transfers) != 2 {
+		t.Fatalf("Expected 2 NFT transfers, got %d", len(transfers))
+	}
+
+	sort.Slice(transfers, func(i, j int) bool { return transfers[i].LogIndex < transfers[j].LogIndex })
+
+	if transfers[0].Standard != api.StandardERC721 || transfers[0].TokenID.String() != "42" || transfers[0].To != alice {
+		t.Errorf("Expected token 42 to be received by %s, got %+v", alice, transfers[0])
+	}
+
+	if transfers[1].Standard != api.StandardERC1155 || transfers[1].TokenID.String() != "9" || transfers[1].Amount.String() != "3" || transfers[1].From != alice {
+		t.Errorf("Expected 3 of token 9 to be sent by %s, got %+v", alice, transfers[1])
+	}
+
+	if transfers[0].BlockHash != "0xb1" || transfers[0].Status == "" {
+		t.Errorf("Expected the transfer to carry its block and status, got %+v", transfers[0])
+	}
+
+	// token transfers are not tracked unless enabled
+	if tokenTransfers, _ := mockTxRepo.GetTokenTransfers(ctx, alice, ""); len(tokenTransfers) != 0 {
+		t.Errorf("Expected no token transfers, got %d", len(tokenTransfers))
+	}
+}
+
+type traceBlockchainClient struct {
+	*MockBlockchainClient
+	traces map[int64][]blockchain.TransactionTrace
+}
+
+func (m *traceBlockchainClient) TraceBlockByNumber(ctx context.Context, number int64) ([]blockchain.TransactionTrace, error) {
+	return m.traces[number], nil
+}
+
+func TestParserWorker_InternalTransactions(t *testing.T) {
+	mockBC := &traceBlockchainClient{
+		MockBlockchainClient: &MockBlockchainClient{
+			initialBlockNumber: 0,
+			latestBlockNumber:  1,
+			blocks: map[int64]*api.Block{
+				1: {Number: 1, Hash: "0xb1", Transactions: []api.Transaction{
+					{From: "0x1", To: "0xe", Hash: "0x100", Kind: api.KindExternal},
+					{From: "0x3", To: "0xe", Hash: "0x101", Kind: api.KindExternal},
+				}},
+			},
+		},
+		traces: map[int64][]blockchain.TransactionTrace{
+			1: {
+				// the subscribed address sends ether through the contract, which forwards part of it
+				{TxHash: "0x100", Result: blockchain.CallFrame{Type: "CALL", From: "0x1", To: "0xe", Value: "0x64", Calls: []blockchain.CallFrame{
+					{Type: "CALL", From: "0xe", To: "0x4", Value: "0x32"},
+				}}},
+				// an exchange withdrawal to the subscribed address
+				{TxHash: "0x101", Result: blockchain.CallFrame{Type: "CALL", From: "0x3", To: "0xe", Calls: []blockchain.CallFrame{
+					{Type: "CALL", From: "0xe", To: "0x1", Value: "0x10"},
+				}}},
+			},
+		},
+	}
+
+	mockTxRepo := repository.NewInMemoryTransactionRepository()
+	mockSubRepo := repository.NewInMemorySubscriberRepository()
+	mockBlockRepo := repository.NewInMemoryBlockRepository()
+
+	worker := worker.NewParserWorker(mockBC, mockTxRepo, mockSubRepo, mockBlockRepo).
+		WithInternalTransactions(true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	mockSubRepo.Subscribe(ctx, "0x1")
+
+	go worker.Run(ctx, 50*time.Millisecond)
+
+	if !waitFor(time.Second, func() bool {
+		val, _ := mockBlockRepo.GetLastParsedBlock(ctx)
+		return val == 1
+	}) {
+		t.Fatal("Expected the worker to parse up to block 1")
+	}
+
+	txs, _ := mockTxRepo.GetTransactions(ctx, "0x1")
+	if len(txs) != 2 {
+		t.Fatalf("Expected 2 transactions, got %+v", txs)
+	}
+
+	external, internal := txs[0], txs[1]
+
+	if external.Kind != api.KindExternal || external.Hash != "0x100" {
+		t.Errorf("Expected the external transaction 0x100, got %+v", external)
+	}
+
+	if internal.Kind != api.KindInternal || internal.Hash != "0x101" || internal.To != "0x1" || internal.Value.String() != "16" || internal.Status == "" {
+		t.Errorf("Expected an internal transfer of 16 wei to 0x1 within 0x101, got %+v", internal)
+	}
+}
+
+func TestParserWorker_Withdrawals(t *testing.T) {
+	mockBC := &MockBlockchainClient{
+		initialBlockNumber: 0,
+		latestBlockNumber:  2,
+		blocks: map[int64]*api.Block{
+			1: {Number: 1, Hash: "0xb1", Withdrawals: []api.Withdrawal{
+				{Index: 10, ValidatorIndex: 1, Address: "0x1", Amount: api.NewBigInt(100), BlockHash: "0xb1", BlockNumber: 1},
+				{Index: 11, ValidatorIndex: 2, Address: "0x2", Amount: api.NewBigInt(200), BlockHash: "0xb1", BlockNumber: 1},
+			}},
+			2: {Number: 2, Hash: "0xb2", Withdrawals: []api.Withdrawal{
+				{Index: 12, ValidatorIndex: 1, Address: "0x1", Amount: api.NewBigInt(300), BlockHash: "0xb2", BlockNumber: 2},
+			}},
+		},
+	}
+
+	mockTxRepo := repository.NewInMemoryTransactionRepository()
+	mockSubRepo := repository.NewInMemorySubscriberRepository()
+	mockBlockRepo := repository.NewInMemoryBlockRepository()
+
+	worker := worker.NewParserWorker(mockBC, mockTxRepo, mockSubRepo, mockBlockRepo)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	mockSubRepo.Subscribe(ctx, "0x1")
+
+	go worker.Run(ctx, 50*time.Millisecond)
+
+	if !waitFor(time.Second, func() bool {
+		val, _ := mockBlockRepo.GetLastParsedBlock(ctx)
+		return val == 2
+	}) {
+		t.Fatal("Expected the worker to parse up to block 2")
+	}
+
+	withdrawals, _ := mockTxRepo.GetWithdrawals(ctx, "0x1", "")
+	if len(withdrawals) != 2 {
+		t.Fatalf("Expected 2 withdrawals, got %d", len(withdrawals))
+	}
+
+	sort.Slice(withdrawals, func(i, j int) bool { return withdrawals[i].Index < withdrawals[j].Index })
+
+	if withdrawals[0].Index != 10 || withdrawals[1].Index != 12 || withdrawals[0].Status == "" {
+		t.Errorf("Expected withdrawals 10 and 12 with a status, got %+v", withdrawals)
+	}
+}
+
+func TestParserWorker_ContractSubscriptions(t *testing.T) {
+	mockBC := &receiptBlockchainClient{
+		MockBlockchainClient: &MockBlockchainClient{
+			initialBlockNumber: 0,
+			latestBlockNumber:  2,
+			blocks: map[int64]*api.Block{
+				1: {Number: 1, Transactions: []api.Transaction{
+					{From: "0x1", Hash: "0x100", BlockNumber: 1, ContractCreation: true},
+					{From: "0x1", Hash: "0x101", ContractCreation: true},
+					{From: "0x3", Hash: "0x102", ContractCreation: true},
+					{From: "0x1", Hash: "0x103", BlockNumber: 1, ContractCreation: true},
+				}},
+				2: {Number: 2, Transactions: []api.Transaction{
+					{From: "0x4", To: "0xc1", Hash: "0x200"},
+					{From: "0x4", To: "0xc3", Hash: "0x201"},
+				}},
+			},
+		},
+		receipts: map[string]*api.Receipt{
+			"0x100": {TransactionHash: "0x100", Status: api.ReceiptSuccess, ContractAddress: "0xC1"},
+			"0x101": {TransactionHash: "0x101", Status: api.ReceiptFailed, ContractAddress: "0xc2"},
+			"0x102": {TransactionHash: "0x102", Status: api.ReceiptSuccess, ContractAddress: "0xc3"},
+			"0x103": {TransactionHash: "0x103", Status: api.ReceiptSuccess, ContractAddress: "0xc4"},
+			"0x200": {TransactionHash: "0x200", Status: api.ReceiptSuccess},
+			"0x201": {TransactionHash: "0x201", Status: api.ReceiptSuccess},
+		},
+	}
+
+	mockTxRepo := repository.NewInMemoryTransactionRepository()
+	mockSubRepo := repository.NewInMemorySubscriberRepository()
+	mockBlockRepo := repository.NewInMemoryBlockRepository()
+
+	worker := worker.NewParserWorker(mockBC, mockTxRepo, mockSubRepo, mockBlockRepo).
+		WithContractSubscriptions(true).
+		WithConcurrency(1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	mockSubRepo.Subscribe(ctx, "0x1")
+
+	// a contract whose subscription expired is not subscribed to again
+	expired := time.Now().Add(-time.Minute)
+	mockSubRepo.SaveSubscription(ctx, api.Subscription{Address: "0xc4", SubscriptionMetadata: api.SubscriptionMetadata{Label: "retired", ExpiresAt: &expired}})
+
+	go worker.Run(ctx, 50*time.Millisecond)
+
+	if !waitFor(time.Second, func() bool {
+		val, _ := mockBlockRepo.GetLastParsedBlock(ctx)
+		return val == 2
+	}) {
+		t.Fatal("Expected the worker to parse up to block 2")
+	}
+
+	txs, _ := mockTxRepo.GetTransactions(ctx, "0x1")
+	if len(txs) != 3 {
+		t.Fatalf("Expected 3 deployments by 0x1, got %d", len(txs))
+	}
+
+	if !txs[0].ContractCreation || txs[0].ContractAddress != "0xc1" {
+		t.Errorf("Expected 0x100 to have deployed 0xc1, got %+v", txs[0])
+	}
+
+	// only the contract successfully deployed by the subscribed address is subscribed to
+	for address, expected := range map[string]bool{"0xc1": true, "0xc2": false, "0xc3": false, "0xc4": false} {
+		if subscribed, _ := mockSubRepo.IsSubscribed(ctx, address); subscribed != expected {
+			t.Errorf("Expected the subscription to %s to be %t, got %t", address, expected, subscribed)
+		}
+	}
+
+	// the subscription of the contract tells where it comes from
+	subscriptions, _ := mockSubRepo.GetSubscriptions(ctx)
+	if len(subscriptions) != 3 || subscriptions[1].Address != "0xc1" || subscriptions[1].CreatedAtBlock != 1 ||
+		subscriptions[1].Label != "deployed by 0x1" || !subscriptions[1].HasTag("contract") {
+		t.Errorf("Expected the subscription of 0xc1 to be tagged and created at block 1, got %+v", subscriptions)
+	}
+
+	if len(subscriptions) == 3 && (subscriptions[2].Label != "retired" || subscriptions[2].HasTag("contract")) {
+		t.Errorf("Expected the expired subscription of 0xc4 to be left as is, got %+v", subscriptions[2])
+	}
+
+	if txs, _ := mockTxRepo.GetTransactions(ctx, "0xc4"); len(txs) != 0 {
+		t.Errorf("Expected no transactions for the expired 0xc4, got %+v", txs)
+	}
+
+	// the contract has its deployment and the transactions sent to it afterwards
+	txs, _ = mockTxRepo.GetTransactions(ctx, "0xc1")
+
+	hashes := []string{}
+	for _, tx := range txs {
+		hashes = append(hashes, tx.Hash)
+	}
+
+	if !reflect.DeepEqual(hashes, []string{"0x100", "0x200"}) {
+		t.Errorf("Expected the transactions of 0xc1 to be 0x100 and 0x200, got %v", hashes)
+	}
+}
+
+func TestParserWorker_SimulatedChain(t *testing.T) {
+	alice, bob, carol := simchain.Address("alice"), simchain.Address("bob"), simchain.Address("carol")
+
+	chain := simchain.New(simchain.WithAddresses(alice, bob, carol), simchain.WithTransactionsPerBlock(4))
+	chain.Mine(3)
+
+	// the node is down for a few calls when the worker starts
+	chain.FailNext(simchain.AnyMethod, 3, blockchain.ErrServerError)
+
+	mockTxRepo := repository.NewInMemoryTransactionRepository()
+	mockSubRepo := repository.NewInMemorySubscriberRepository()
+	mockBlockRepo := repository.NewInMemoryBlockRepository()
+
+	worker := worker.NewParserWorker(chain, mockTxRepo, mockSubRepo, mockBlockRepo).
+		WithStartBlock(1).
+		WithConfirmationDepth(1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	mockSubRepo.Subscribe(ctx, alice)
+
+	go worker.Run(ctx, 20*time.Millisecond)
+
+	parsedUpTo := func(number int64) bool {
+		val, _ := mockBlockRepo.GetLastParsedBlock(ctx)
+		return val == number
+	}
+
+	if !waitFor(2*time.Second, func() bool { return parsedUpTo(3) }) {
+		t.Fatal("Expected the worker to parse up to block 3")
+	}
+
+	replaced := []*api.Block{chain.Block(2), chain.Block(3)}
+
+	// blocks 2 and 3 are replaced by a fork, extended by block 4
+	if _, err := chain.Reorg(2); err != nil {
+		t.Fatalf("Reorg returned unexpected error: %v", err)
+	}
+
+	chain.Mine(1)
+
+	stored := func() map[string]api.Transaction {
+		txs := allTransactions(ctx, mockTxRepo, alice)
+		result := make(map[string]api.Transaction, len(txs))
+		for _, tx := range txs {
+			result[tx.Hash] = tx
+		}
+		return result
+	}
+
+	// every transaction of alice on the canonical chain is stored, those of the replaced blocks are orphaned
+	expected := map[string]string{}
+	for number := int64(1); number <= 4; number++ {
+		for _, tx := range chain.Block(number).Transactions {
+			if tx.From == alice || tx.To == alice {
+				expected[tx.Hash] = tx.BlockHash
+			}
+		}
+	}
+
+	for _, block := range replaced {
+		for _, tx := range block.Transactions {
+			if tx.From == alice || tx.To == alice {
+				expected[tx.Hash] = string(api.StatusOrphaned)
+			}
+		}
+	}
+
+	actual := func() map[string]string {
+		result := map[string]string{}
+		for hash, tx := range stored() {
+			result[hash] = tx.BlockHash
+			if tx.Status == api.StatusOrphaned {
+				result[hash] = string(api.StatusOrphaned)
+			}
+		}
+		return result
+	}
+
+	if !waitFor(2*time.Second, func() bool { return parsedUpTo(4) && reflect.DeepEqual(actual(), expected) }) {
+		t.Errorf("Expected transactions %v after the reorg, got %v", expected, actual())
+	}
+}
+
+// committingBlockRepository records the blocks committed through it
+type committingBlockRepository struct {
+	*repository.InMemoryBlockRepository
+	txRepo  repository.TransactionRepository
+	mu      sync.Mutex
+	commits map[int64]repository.BlockMatches
+	failing atomic.Bool
+}
+
+func (r *committingBlockRepository) CommitBlock(ctx context.Context, blockNumber int64, blockHash string, matches repository.BlockMatches) error {
+	if r.failing.CompareAndSwap(true, false) {
+		return errors.New("commit failed")
+	}
+
+	r.mu.Lock()
+	r.commits[blockNumber] = matches
+	r.mu.Unlock()
+
+	return repository.CommitBlock(ctx, r.txRepo, r.InMemoryBlockRepository, blockNumber, blockHash, matches)
+}
+
+func (r *committingBlockRepository) committed(blockNumber int64) (repository.BlockMatches, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	matches, ok := r.commits[blockNumber]
+
+	return matches, ok
+}
+
+func TestParserWorker_CommitBlock(t *testing.T) {
+	mockBC := &MockBlockchainClient{
+		initialBlockNumber: 0,
+		latestBlockNumber:  2,
+		blocks: map[int64]*api.Block{
+			1: {Number: 1, Hash: "0xb1"},
+			2: {Number: 2, Hash: "0xb2",
+				Transactions: []api.Transaction{{From: "0x1", To: "0x2", Hash: "0x999", BlockHash: "0xb2", Value: api.NewBigInt(100)}},
+				Withdrawals:  []api.Withdrawal{{Index: 10, Address: "0x1", Amount: api.NewBigInt(100), BlockHash: "0xb2", BlockNumber: 2}},
+			},
+		},
+	}
+
+	mockTxRepo := repository.NewInMemoryTransactionRepository()
+	mockSubRepo := repository.NewInMemorySubscriberRepository()
+	mockBlockRepo := &committingBlockRepository{
+		InMemoryBlockRepository: repository.NewInMemoryBlockRepository(),
+		txRepo:                  mockTxRepo,
+		commits:                 map[int64]repository.BlockMatches{},
+	}
+
+	// the first commit fails, as if the database was unreachable, and is retried
+	mockBlockRepo.failing.Store(true)
+
+	worker := worker.NewParserWorker(mockBC, mockTxRepo, mockSubRepo, mockBlockRepo)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	mockSubRepo.Subscribe(ctx, "0x1")
+	mockSubRepo.Subscribe(ctx, "0x2")
+
+	go worker.Run(ctx, 50*time.Millisecond)
+
+	if !waitFor(3*time.Second, func() bool {
+		val, _ := mockBlockRepo.GetLastParsedBlock(ctx)
+		return val == 2
+	}) {
+		t.Fatal("Expected the worker to parse up to block 2")
+	}
+
+	if _, ok := mockBlockRepo.committed(1); !ok {
+		t.Error("Expected block 1 to be committed even without matches")
+	}
+
+	// the records of a block are committed together, once per subscribed address
+	matches, _ := mockBlockRepo.committed(2)
+	if len(matches.Transactions) != 2 || len(matches.Withdrawals) != 1 {
+		t.Errorf("Expected the transaction for both addresses and the withdrawal in a single commit, got %+v", matches)
+	}
+
+	if failed, _ := mockBlockRepo.GetFailedBlocks(ctx); len(failed) != 0 {
+		t.Errorf("Expected no failed blocks, got %+v", failed)
+	}
+}
@@ -0,0 +1,270 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/devshark/tx-parser-go/api"
+	"github.com/devshark/tx-parser-go/app/internal/blockchain"
+	"github.com/devshark/tx-parser-go/pkg/retry"
+)
+
+const (
+	// DefaultConcurrency is the number of blocks, or batches of blocks, fetched in parallel
+	DefaultConcurrency = 4
+	// DefaultBatchSize is the number of blocks fetched in a single batch when catching up
+	DefaultBatchSize = 20
+)
+
+// fetchResult is a fetched block waiting for its turn in the commit stage
+type fetchResult struct {
+	number int64
+	block  *api.Block
+	err    error
+}
+
+// WithConcurrency sets the number of blocks fetched in parallel
+func (p *ParserWorker) WithConcurrency(concurrency int) *ParserWorker {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	p.concurrency = concurrency
+
+	return p
+}
+
+// WithBatchSize sets the number of blocks fetched in a single batch when catching up
+func (p *ParserWorker) WithBatchSize(size int) *ParserWorker {
+	if size < 1 {
+		size = 1
+	}
+
+	p.batchSize = size
+
+	return p
+}
+
+// parseRange fetches the blocks between from and to (inclusive) with a bounded pool of workers
+// and commits them strictly in block order, moving the checkpoint along with every settled block.
+// A block is settled once it is either committed or recorded as a failed block.
+// It returns the highest block up to which every block is settled.
+func (p *ParserWorker) parseRange(ctx context.Context, from, to int64) int64 {
+	if to < from {
+		return from - 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// fetch in batches when catching up and the client supports it
+	chunkSize := int64(1)
+	if _, ok := p.blockchain.(blockchain.RangeClient); ok && to-from+1 > int64(p.concurrency) {
+		chunkSize = int64(p.batchSize)
+	}
+
+	// window bounds how many blocks the fetchers can run ahead of the commit stage
+	window := make(chan struct{}, 2*p.concurrency*int(chunkSize))
+	chunks := make(chan [2]int64)
+	results := make(chan fetchResult)
+
+	go func() {
+		defer close(chunks)
+
+		for start := from; start <= to; start += chunkSize {
+			end := min(start+chunkSize-1, to)
+
+			for number := start; number <= end; number++ {
+				select {
+				case window <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			select {
+			case chunks <- [2]int64{start, end}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < p.concurrency; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for chunk := range chunks {
+				for _, result := range p.fetchChunk(ctx, chunk[0], chunk[1]) {
+					select {
+					case results <- result:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// buffer the out of order results until the next block in line arrives
+	pending := make(map[int64]fetchResult)
+	next := from
+	stopped := false
+
+	for result := range results {
+		if stopped {
+			continue
+		}
+
+		pending[result.number] = result
+
+		for {
+			result, ok := pending[next]
+			if !ok {
+				break
+			}
+
+			delete(pending, next)
+
+			if !p.settleBlock(ctx, result) {
+				// stop committing so the checkpoint stays contiguous, the rest is fetched again later
+				stopped = true
+				cancel()
+
+				break
+			}
+
+			next++
+			<-window
+		}
+	}
+
+	return next - 1
+}
+
+// fetchChunk fetches consecutive blocks in a single batch when there are several of them,
+// the blocks that failed individually are fetched one by one. A batch that keeps failing as
+// a whole fails every block in it, rather than sending each of them to a node that is rate
+// limiting or failing already.
+func (p *ParserWorker) fetchChunk(ctx context.Context, from, to int64) []fetchResult {
+	results := make([]fetchResult, 0, to-from+1)
+
+	if from == to {
+		return append(results, p.fetchBlock(ctx, from))
+	}
+
+	var blocks []*api.Block
+
+	action := func() error {
+		var err error
+		blocks, err = p.blockchain.(blockchain.RangeClient).GetBlocksByRange(ctx, from, to)
+
+		// the blocks that failed individually are retried one by one below
+		var batchErr *blockchain.BatchError
+		if errors.As(err, &batchErr) {
+			return nil
+		}
+
+		// a whole batch is only retried on rate limits, server and transport errors
+		if err != nil && !blockchain.IsRetryable(err) {
+			return retry.Permanent(err)
+		}
+
+		return err
+	}
+
+	if err := retry.Retry(ctx, action, retry.DefaultMaxAttempts); err != nil {
+		if ctx.Err() != nil || blockchain.IsRetryable(err) {
+			for number := from; number <= to; number++ {
+				results = append(results, fetchResult{number: number, err: err})
+			}
+
+			return results
+		}
+
+		// e.g. the node does not support batches, fetch the blocks one by one
+		blocks = nil
+	}
+
+	for number := from; number <= to; number++ {
+		if i := number - from; i < int64(len(blocks)) && blocks[i] != nil {
+			results = append(results, fetchResult{number: number, block: blocks[i]})
+			continue
+		}
+
+		results = append(results, p.fetchBlock(ctx, number))
+	}
+
+	return results
+}
+
+// fetchBlock fetches a single block, retrying on failures
+func (p *ParserWorker) fetchBlock(ctx context.Context, number int64) fetchResult {
+	var block *api.Block
+
+	action := func() error {
+		var err error
+		block, err = p.blockchain.GetBlockByNumber(ctx, number)
+		if err != nil && !blockchain.IsRetryable(err) {
+			return retry.Permanent(err)
+		}
+
+		return err
+	}
+
+	err := retry.Retry(ctx, action, retry.DefaultMaxAttempts)
+
+	return fetchResult{number: number, block: block, err: err}
+}
+
+// settleBlock commits a fetched block, which moves the checkpoint to it along with its records,
+// or dead-letters it if it could not be fetched or committed and then moves the checkpoint to it.
+// A block the node does not have yet is neither, the checkpoint stays before it so that it is
+// fetched again with the next blocks. It reports whether the block was settled.
+func (p *ParserWorker) settleBlock(ctx context.Context, result fetchResult) bool {
+	if result.err == nil && result.block == nil {
+		result.err = fmt.Errorf("%w: block %d", blockchain.ErrBlockNotAvailable, result.number)
+	}
+
+	if errors.Is(result.err, blockchain.ErrBlockNotAvailable) {
+		p.logger.Printf("stopping before block %d: %v", result.number, result.err)
+
+		return false
+	}
+
+	err := result.err
+	if err == nil {
+		err = retry.Retry(ctx, func() error { return p.ingestBlock(ctx, result.block) }, retry.DefaultMaxAttempts)
+		if err == nil {
+			return true
+		}
+	}
+
+	if err != nil {
+		// Log any errors that happen, but don't crash
+		p.logger.Printf("failed to parse block %d: %v", result.number, err)
+
+		if !p.deadLetter(ctx, result.number, err) {
+			return false
+		}
+	}
+
+	if err := p.blockRepo.UpdateLastParsedBlock(ctx, result.number); err != nil {
+		p.logger.Printf("failed to update last parsed block to %d: %v", result.number, err)
+
+		return false
+	}
+
+	return true
+}
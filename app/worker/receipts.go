@@ -0,0 +1,61 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/devshark/tx-parser-go/api"
+	"github.com/devshark/tx-parser-go/app/internal/blockchain"
+)
+
+// attachReceipts fetches the receipts of the given transactions of a block, with a single
+// eth_getBlockReceipts call when there are several of them and one call per transaction otherwise.
+// Nothing is attached if the client, or the node behind it, does not provide receipts.
+func attachReceipts(ctx context.Context, client blockchain.BlockchainClient, blockNumber int64, txs []*api.Transaction) error {
+	receiptClient, ok := client.(blockchain.ReceiptClient)
+	if !ok || len(txs) == 0 {
+		return nil
+	}
+
+	receipts := make(map[string]*api.Receipt)
+
+	if len(txs) > 1 {
+		// fall back to the receipts of the transactions one by one if this fails
+		if blockReceipts, err := receiptClient.GetBlockReceipts(ctx, blockNumber); err == nil {
+			for _, receipt := range blockReceipts {
+				receipts[strings.ToLower(receipt.TransactionHash)] = receipt
+			}
+		}
+	}
+
+	for _, tx := range txs {
+		if receipt, ok := receipts[strings.ToLower(tx.Hash)]; ok {
+			setReceipt(tx, receipt)
+			continue
+		}
+
+		receipt, err := receiptClient.GetTransactionReceipt(ctx, tx.Hash)
+		if errors.Is(err, blockchain.ErrMethodNotFound) || errors.Is(err, blockchain.ErrNotImplemented) {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("GetTransactionReceipt: %w", err)
+		}
+
+		setReceipt(tx, receipt)
+	}
+
+	return nil
+}
+
+// setReceipt attaches the receipt to the transaction, along with the address of the contract it deployed
+func setReceipt(tx *api.Transaction, receipt *api.Receipt) {
+	tx.Receipt = receipt
+
+	if tx.ContractCreation && receipt != nil {
+		tx.ContractAddress = strings.ToLower(receipt.ContractAddress)
+	}
+}
@@ -0,0 +1,202 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/devshark/tx-parser-go/api"
+	"github.com/devshark/tx-parser-go/app/internal/blockchain"
+	"github.com/devshark/tx-parser-go/app/internal/repository"
+)
+
+// WithTokenTransfers enables tracking the ERC-20 transfers of subscribed addresses
+func (p *ParserWorker) WithTokenTransfers(enabled bool) *ParserWorker {
+	p.tokenTransfers = enabled
+
+	return p
+}
+
+// WithNFTTransfers enables tracking the ERC-721 and ERC-1155 transfers of subscribed addresses
+func (p *ParserWorker) WithNFTTransfers(enabled bool) *ParserWorker {
+	p.nftTransfers = enabled
+
+	return p
+}
+
+// matchTransfers adds the token and NFT transfers of the block that involve a subscribed address to the matches
+func (p *ParserWorker) matchTransfers(ctx context.Context, block *api.Block, status api.TransactionStatus, matches *repository.BlockMatches) error {
+	transfers, err := blockTransfers(ctx, p.blockchain, block, status, p.tokenTransfers, p.nftTransfers)
+	if err != nil {
+		return err
+	}
+
+	for _, transfer := range transfers.tokens {
+		addresses, err := p.subscribedOf(ctx, transfer.From, transfer.To)
+		if err != nil {
+			return err
+		}
+
+		for _, addr := range addresses {
+			matches.TokenTransfers = append(matches.TokenTransfers, repository.Match[api.TokenTransfer]{Address: addr, Record: transfer})
+		}
+	}
+
+	for _, transfer := range transfers.nfts {
+		addresses, err := p.subscribedOf(ctx, transfer.From, transfer.To)
+		if err != nil {
+			return err
+		}
+
+		for _, addr := range addresses {
+			matches.NFTTransfers = append(matches.NFTTransfers, repository.Match[api.NFTTransfer]{Address: addr, Record: transfer})
+		}
+	}
+
+	return nil
+}
+
+// subscribedOf returns the given addresses that are subscribed, without duplicates
+func (p *ParserWorker) subscribedOf(ctx context.Context, addresses ...string) ([]string, error) {
+	subscribed := []string{}
+
+	for _, addr := range uniqueAddresses(addresses...) {
+		ok, err := p.subscriberRepo.IsSubscribed(ctx, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			subscribed = append(subscribed, addr)
+		}
+	}
+
+	return subscribed, nil
+}
+
+// decodedTransfers are the token and NFT transfers decoded from the logs of a block
+type decodedTransfers struct {
+	tokens []api.TokenTransfer
+	nfts   []api.NFTTransfer
+}
+
+// blockTransfers decodes the ERC-20 transfers of a block if tokens is set and its
+// ERC-721 and ERC-1155 transfers if nfts is set, from a single query of its logs
+func blockTransfers(ctx context.Context, client blockchain.BlockchainClient, block *api.Block, status api.TransactionStatus, tokens, nfts bool) (decodedTransfers, error) {
+	result := decodedTransfers{tokens: []api.TokenTransfer{}, nfts: []api.NFTTransfer{}}
+
+	if !tokens && !nfts {
+		return result, nil
+	}
+
+	topics := []string{blockchain.TransferEventTopic}
+	if nfts {
+		topics = append(topics, blockchain.TransferSingleEventTopic, blockchain.TransferBatchEventTopic)
+	}
+
+	logs, err := blockLogs(ctx, client, block, topics)
+	if err != nil {
+		return result, err
+	}
+
+	for _, l := range logs {
+		if tokens {
+			if transfer, ok := blockchain.DecodeTokenTransfer(l); ok {
+				transfer.BlockHash = block.Hash
+				transfer.BlockNumber = block.Number
+				transfer.Timestamp = block.Timestamp
+				transfer.Status = status
+
+				result.tokens = append(result.tokens, transfer)
+
+				continue
+			}
+		}
+
+		if nfts {
+			decoded, _ := blockchain.DecodeNFTTransfers(l)
+			for _, transfer := range decoded {
+				transfer.BlockHash = block.Hash
+				transfer.BlockNumber = block.Number
+				transfer.Timestamp = block.Timestamp
+				transfer.Status = status
+
+				result.nfts = append(result.nfts, transfer)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// blockLogs returns the logs of a block with one of the given event topics, with eth_getLogs
+// if the client supports it and from the receipts of the whole block otherwise
+func blockLogs(ctx context.Context, client blockchain.BlockchainClient, block *api.Block, topics []string) ([]api.Log, error) {
+	if logClient, ok := client.(blockchain.LogClient); ok {
+		filter := blockchain.LogFilter{FromBlock: block.Number, ToBlock: block.Number, Topics: [][]string{topics}}
+
+		// pin the block by hash so the logs cannot come from a block that replaced it
+		if block.Hash != "" {
+			filter.BlockHash = block.Hash
+		}
+
+		logs, err := logClient.GetLogs(ctx, filter)
+		if err == nil {
+			return logs, nil
+		}
+
+		if !errors.Is(err, blockchain.ErrMethodNotFound) && !errors.Is(err, blockchain.ErrNotImplemented) {
+			return nil, fmt.Errorf("GetLogs: %w", err)
+		}
+	}
+
+	receiptClient, ok := client.(blockchain.ReceiptClient)
+	if !ok {
+		return nil, nil
+	}
+
+	receipts, err := receiptClient.GetBlockReceipts(ctx, block.Number)
+	if errors.Is(err, blockchain.ErrMethodNotFound) || errors.Is(err, blockchain.ErrNotImplemented) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("GetBlockReceipts: %w", err)
+	}
+
+	logs := []api.Log{}
+
+	for _, receipt := range receipts {
+		for _, l := range receipt.Logs {
+			if len(l.Topics) > 0 && containsFold(topics, l.Topics[0]) {
+				logs = append(logs, l)
+			}
+		}
+	}
+
+	return logs, nil
+}
+
+// uniqueAddresses returns the non-empty addresses, without duplicates
+func uniqueAddresses(addresses ...string) []string {
+	unique := make([]string, 0, len(addresses))
+
+	for _, addr := range addresses {
+		if strings.TrimSpace(addr) != "" && !containsFold(unique, addr) {
+			unique = append(unique, addr)
+		}
+	}
+
+	return unique
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -1,6 +1,7 @@
 package client
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -40,6 +41,35 @@ type CurrentBlockResponse struct {
 
 type AddressTransactionsResponse struct {
 	Transactions []api.Transaction `json:"transactions"`
+	NextCursor   string            `json:"next_cursor,omitempty"`
+}
+
+type TokenTransfersResponse struct {
+	TokenTransfers []api.TokenTransfer `json:"token_transfers"`
+}
+
+type NFTTransfersResponse struct {
+	NFTTransfers []api.NFTTransfer `json:"nft_transfers"`
+}
+
+type WithdrawalsResponse struct {
+	Withdrawals []api.Withdrawal `json:"withdrawals"`
+}
+
+type ActivityResponse struct {
+	Activity []api.Activity `json:"activity"`
+}
+
+type FailedBlocksResponse struct {
+	FailedBlocks []api.FailedBlock `json:"failed_blocks"`
+}
+
+type SubscriptionsResponse struct {
+	Subscriptions []api.Subscription `json:"subscriptions"`
+}
+
+type BackfillJobResponse struct {
+	Job api.BackfillJob `json:"job"`
 }
 
 func (c *Client) GetCurrentBlock() int {
@@ -57,8 +87,28 @@ func (c *Client) GetCurrentBlock() int {
 	return int(currentBlockResponse.BlockNumber)
 }
 
+// GetTransactions returns every transaction of the address, following the pages
 func (c *Client) GetTransactions(address string) []api.Transaction {
+	return c.allTransactions(address, api.TransactionQuery{})
+}
+
+// GetConfirmedTransactions returns only the transactions that reached the confirmation depth or finality
+func (c *Client) GetConfirmedTransactions(address string) []api.Transaction {
+	return c.allTransactions(address, api.TransactionQuery{Confirmed: true})
+}
+
+// GetSuccessfulTransactions leaves out the transactions whose receipt reports they reverted
+func (c *Client) GetSuccessfulTransactions(address string) []api.Transaction {
+	return c.allTransactions(address, api.TransactionQuery{ExcludeFailed: true})
+}
+
+// QueryTransactions returns a page of the transactions of the address selected by the query,
+// the next page is queried with the NextCursor of the page as the Cursor of the query
+func (c *Client) QueryTransactions(address string, query api.TransactionQuery) *api.TransactionPage {
 	url := fmt.Sprintf("%s/transactions/%s", c.baseUrl, address)
+	if values := TransactionQueryValues(query); len(values) > 0 {
+		url += "?" + values.Encode()
+	}
 
 	var addressTransactionsResponse AddressTransactionsResponse
 
@@ -69,7 +119,94 @@ func (c *Client) GetTransactions(address string) []api.Transaction {
 		return nil
 	}
 
-	return addressTransactionsResponse.Transactions
+	return &api.TransactionPage{
+		Transactions: addressTransactionsResponse.Transactions,
+		NextCursor:   addressTransactionsResponse.NextCursor,
+	}
+}
+
+// allTransactions returns the transactions selected by the query from every page
+func (c *Client) allTransactions(address string, query api.TransactionQuery) []api.Transaction {
+	var transactions []api.Transaction
+
+	for {
+		page := c.QueryTransactions(address, query)
+		if page == nil {
+			return nil
+		}
+
+		transactions = append(transactions, page.Transactions...)
+
+		if page.NextCursor == "" {
+			return transactions
+		}
+
+		query.Cursor = page.NextCursor
+	}
+}
+
+// GetTokenTransfers returns the ERC-20 transfers sent or received by the address
+func (c *Client) GetTokenTransfers(address string) []api.TokenTransfer {
+	url := fmt.Sprintf("%s/token-transfers/%s", c.baseUrl, address)
+
+	var tokenTransfersResponse TokenTransfersResponse
+
+	err := c.get(url, &tokenTransfersResponse)
+	if err != nil {
+		c.logger.Printf("error: %v\n", err)
+
+		return nil
+	}
+
+	return tokenTransfersResponse.TokenTransfers
+}
+
+// GetNFTTransfers returns the ERC-721 and ERC-1155 transfers sent or received by the address
+func (c *Client) GetNFTTransfers(address string) []api.NFTTransfer {
+	url := fmt.Sprintf("%s/nft-transfers/%s", c.baseUrl, address)
+
+	var nftTransfersResponse NFTTransfersResponse
+
+	err := c.get(url, &nftTransfersResponse)
+	if err != nil {
+		c.logger.Printf("error: %v\n", err)
+
+		return nil
+	}
+
+	return nftTransfersResponse.NFTTransfers
+}
+
+// GetWithdrawals returns the beacon chain withdrawals to the address
+func (c *Client) GetWithdrawals(address string) []api.Withdrawal {
+	url := fmt.Sprintf("%s/withdrawals/%s", c.baseUrl, address)
+
+	var withdrawalsResponse WithdrawalsResponse
+
+	err := c.get(url, &withdrawalsResponse)
+	if err != nil {
+		c.logger.Printf("error: %v\n", err)
+
+		return nil
+	}
+
+	return withdrawalsResponse.Withdrawals
+}
+
+// GetActivity returns the transactions, transfers and withdrawals of the address, in block order
+func (c *Client) GetActivity(address string) []api.Activity {
+	url := fmt.Sprintf("%s/activity/%s", c.baseUrl, address)
+
+	var activityResponse ActivityResponse
+
+	err := c.get(url, &activityResponse)
+	if err != nil {
+		c.logger.Printf("error: %v\n", err)
+
+		return nil
+	}
+
+	return activityResponse.Activity
 }
 
 func (c *Client) Subscribe(address string) bool {
@@ -84,6 +221,114 @@ func (c *Client) Subscribe(address string) bool {
 	return true
 }
 
+// SubscribeWithMetadata subscribes the address with a label, tags or an expiry,
+// replacing the metadata of the subscription if the address is subscribed already
+func (c *Client) SubscribeWithMetadata(address string, metadata api.SubscriptionMetadata) bool {
+	url := fmt.Sprintf("%s/subscribe/%s", c.baseUrl, address)
+
+	body, err := json.Marshal(metadata)
+	if err != nil {
+		c.logger.Printf("error subscribing address: %v\n", err)
+
+		return false
+	}
+
+	if err := c.postNoContent(url, bytes.NewReader(body), http.StatusAccepted); err != nil {
+		c.logger.Printf("error subscribing address: %v\n", err)
+
+		return false
+	}
+
+	return true
+}
+
+// Unsubscribe stops watching the address, false if it was not subscribed
+func (c *Client) Unsubscribe(address string) bool {
+	url := fmt.Sprintf("%s/subscribe/%s", c.baseUrl, address)
+
+	if err := c.delete(url, http.StatusNoContent); err != nil {
+		c.logger.Printf("error unsubscribing address: %v\n", err)
+
+		return false
+	}
+
+	return true
+}
+
+// GetSubscriptions returns every subscription, expired ones included
+func (c *Client) GetSubscriptions() []api.Subscription {
+	url := fmt.Sprintf("%s/subscriptions", c.baseUrl)
+
+	var subscriptionsResponse SubscriptionsResponse
+
+	err := c.get(url, &subscriptionsResponse)
+	if err != nil {
+		c.logger.Printf("error: %v\n", err)
+
+		return nil
+	}
+
+	return subscriptionsResponse.Subscriptions
+}
+
+// SubscribeFromBlock subscribes the address and backfills its transactions starting from the given block
+func (c *Client) SubscribeFromBlock(address string, fromBlock int64) *api.BackfillJob {
+	url := fmt.Sprintf("%s/subscribe/%s?fromBlock=%d", c.baseUrl, address, fromBlock)
+
+	var backfillJobResponse BackfillJobResponse
+
+	if err := c.post(url, nil, http.StatusAccepted, &backfillJobResponse); err != nil {
+		c.logger.Printf("error subscribing address: %v\n", err)
+
+		return nil
+	}
+
+	return &backfillJobResponse.Job
+}
+
+func (c *Client) GetBackfillJob(id string) *api.BackfillJob {
+	url := fmt.Sprintf("%s/backfill/%s", c.baseUrl, id)
+
+	var backfillJobResponse BackfillJobResponse
+
+	err := c.get(url, &backfillJobResponse)
+	if err != nil {
+		c.logger.Printf("error: %v\n", err)
+
+		return nil
+	}
+
+	return &backfillJobResponse.Job
+}
+
+func (c *Client) GetFailedBlocks() []api.FailedBlock {
+	url := fmt.Sprintf("%s/blocks/failed", c.baseUrl)
+
+	var failedBlocksResponse FailedBlocksResponse
+
+	err := c.get(url, &failedBlocksResponse)
+	if err != nil {
+		c.logger.Printf("error: %v\n", err)
+
+		return nil
+	}
+
+	return failedBlocksResponse.FailedBlocks
+}
+
+// RequeueFailedBlock asks the server to re-attempt a failed block
+func (c *Client) RequeueFailedBlock(blockNumber int64) bool {
+	url := fmt.Sprintf("%s/blocks/failed/%d/retry", c.baseUrl, blockNumber)
+
+	if err := c.postNoContent(url, nil, http.StatusAccepted); err != nil {
+		c.logger.Printf("error requeueing block: %v\n", err)
+
+		return false
+	}
+
+	return true
+}
+
 func (c *Client) get(url string, response any) error {
 	req, _ := http.NewRequest(http.MethodGet, url, nil)
 
@@ -103,6 +348,32 @@ func (c *Client) get(url string, response any) error {
 	return nil
 }
 
+func (c *Client) post(url string, body io.Reader, expectedStatus int, response any) error {
+	req, err := http.NewRequest("POST", url, body)
+	if err != nil {
+		return fmt.Errorf("NewRequest: %w", err)
+	}
+
+	req.Header.Add("User-Agent", "go-client/v1")
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("client.Do: %w", err)
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != expectedStatus {
+		return fmt.Errorf("status code: expected %d, got %d", expectedStatus, res.StatusCode)
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(response); err != nil {
+		return fmt.Errorf("json Decode: %w", err)
+	}
+
+	return nil
+}
+
 func (c *Client) postNoContent(url string, body io.Reader, expectedStatus int) error {
 	req, err := http.NewRequest("POST", url, body)
 	if err != nil {
@@ -122,3 +393,25 @@ func (c *Client) postNoContent(url string, body io.Reader, expectedStatus int) e
 
 	return nil
 }
+
+func (c *Client) delete(url string, expectedStatus int) error {
+	req, err := http.NewRequest(http.MethodDelete, url, nil)
+	if err != nil {
+		return fmt.Errorf("NewRequest: %w", err)
+	}
+
+	req.Header.Add("User-Agent", "go-client/v1")
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("client.Do: %w", err)
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != expectedStatus {
+		return fmt.Errorf("status code: expected %d, got %d", expectedStatus, res.StatusCode)
+	}
+
+	return nil
+}
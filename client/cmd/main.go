@@ -17,10 +17,12 @@ func main() {
 	config := NewConfig()
 	logger := log.Default()
 
-	var parserClient api.Parser = client.
+	httpClient := client.
 		NewClient(config.parserUrl).
 		WithCustomHttpDoer(http.DefaultClient)
 
+	var parserClient api.Parser = httpClient
+
 	currentBlock := parserClient.GetCurrentBlock()
 	logger.Printf("current block: %d", currentBlock)
 
@@ -53,6 +55,15 @@ func main() {
 				transactions := parserClient.GetTransactions(address)
 				logger.Printf("%d transactions for %s\n", len(transactions), address)
 				// logger.Printf("%d transactions for %s: %+v\n", len(transactions), address, transactions)
+
+				tokenTransfers := httpClient.GetTokenTransfers(address)
+				logger.Printf("%d token transfers for %s\n", len(tokenTransfers), address)
+
+				nftTransfers := httpClient.GetNFTTransfers(address)
+				logger.Printf("%d NFT transfers for %s\n", len(nftTransfers), address)
+
+				withdrawals := httpClient.GetWithdrawals(address)
+				logger.Printf("%d withdrawals for %s\n", len(withdrawals), address)
 			}
 		}
 	}
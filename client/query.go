@@ -0,0 +1,212 @@
+package client
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/devshark/tx-parser-go/api"
+)
+
+// TransactionQueryValues encodes the query as the parameters of GET /transactions/{address}
+func TransactionQueryValues(query api.TransactionQuery) url.Values {
+	values := url.Values{}
+
+	if query.FromBlock > 0 {
+		values.Set("fromBlock", strconv.FormatInt(query.FromBlock, 10))
+	}
+
+	if query.ToBlock > 0 {
+		values.Set("toBlock", strconv.FormatInt(query.ToBlock, 10))
+	}
+
+	if !query.FromTime.IsZero() {
+		values.Set("fromTime", query.FromTime.Format(time.RFC3339))
+	}
+
+	if !query.ToTime.IsZero() {
+		values.Set("toTime", query.ToTime.Format(time.RFC3339))
+	}
+
+	if query.Direction != "" {
+		values.Set("direction", string(query.Direction))
+	}
+
+	if query.MinValue != nil {
+		values.Set("minValue", query.MinValue.String())
+	}
+
+	if query.MaxValue != nil {
+		values.Set("maxValue", query.MaxValue.String())
+	}
+
+	if query.Counterparty != "" {
+		values.Set("counterparty", query.Counterparty)
+	}
+
+	if query.Status != "" {
+		values.Set("status", string(query.Status))
+	}
+
+	if query.Confirmed {
+		values.Set("confirmed", "true")
+	}
+
+	if query.ExcludeFailed {
+		values.Set("excludeFailed", "true")
+	}
+
+	if query.Kind != "" {
+		values.Set("kind", string(query.Kind))
+	}
+
+	if query.Cursor != "" {
+		values.Set("cursor", query.Cursor)
+	}
+
+	if query.Limit > 0 {
+		values.Set("limit", strconv.Itoa(query.Limit))
+	}
+
+	return values
+}
+
+// ParseTransactionQuery decodes the parameters of GET /transactions/{address}.
+// Times are either RFC 3339 or seconds since the epoch, values are decimal amounts in wei.
+func ParseTransactionQuery(values url.Values) (api.TransactionQuery, error) {
+	var (
+		query api.TransactionQuery
+		err   error
+	)
+
+	if query.FromBlock, err = parseBlockParam(values, "fromBlock"); err != nil {
+		return query, err
+	}
+
+	if query.ToBlock, err = parseBlockParam(values, "toBlock"); err != nil {
+		return query, err
+	}
+
+	if query.FromTime, err = parseTimeParam(values, "fromTime"); err != nil {
+		return query, err
+	}
+
+	if query.ToTime, err = parseTimeParam(values, "toTime"); err != nil {
+		return query, err
+	}
+
+	query.Direction = api.TransactionDirection(values.Get("direction"))
+	if query.Direction != "" && query.Direction != api.DirectionIn && query.Direction != api.DirectionOut {
+		return query, fmt.Errorf("invalid direction %q, expected in or out", query.Direction)
+	}
+
+	if query.MinValue, err = parseValueParam(values, "minValue"); err != nil {
+		return query, err
+	}
+
+	if query.MaxValue, err = parseValueParam(values, "maxValue"); err != nil {
+		return query, err
+	}
+
+	query.Counterparty = values.Get("counterparty")
+
+	if query.Status, err = ParseStatus(values.Get("status")); err != nil {
+		return query, err
+	}
+
+	if query.Confirmed, err = parseBoolParam(values, "confirmed"); err != nil {
+		return query, err
+	}
+
+	if query.ExcludeFailed, err = parseBoolParam(values, "excludeFailed"); err != nil {
+		return query, err
+	}
+
+	query.Kind = api.TransactionKind(values.Get("kind"))
+	if query.Kind != "" && query.Kind != api.KindExternal && query.Kind != api.KindInternal {
+		return query, fmt.Errorf("invalid kind %q, expected external or internal", query.Kind)
+	}
+
+	query.Cursor = values.Get("cursor")
+
+	if value := values.Get("limit"); value != "" {
+		if query.Limit, err = strconv.Atoi(value); err != nil || query.Limit < 1 {
+			return query, fmt.Errorf("invalid limit %q", value)
+		}
+	}
+
+	return query, nil
+}
+
+func parseBlockParam(values url.Values, name string) (int64, error) {
+	value := values.Get(name)
+	if value == "" {
+		return 0, nil
+	}
+
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || parsed < 0 {
+		return 0, fmt.Errorf("invalid %s %q", name, value)
+	}
+
+	return parsed, nil
+}
+
+func parseTimeParam(values url.Values, name string) (time.Time, error) {
+	value := values.Get(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s %q", name, value)
+	}
+
+	return parsed, nil
+}
+
+func parseValueParam(values url.Values, name string) (*api.BigInt, error) {
+	value := values.Get(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	parsed, err := api.ParseBigInt(value)
+	if err != nil || parsed.Sign() < 0 {
+		return nil, fmt.Errorf("invalid %s %q", name, value)
+	}
+
+	return &parsed, nil
+}
+
+func parseBoolParam(values url.Values, name string) (bool, error) {
+	value := values.Get(name)
+	if value == "" {
+		return false, nil
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s %q", name, value)
+	}
+
+	return parsed, nil
+}
+
+// ParseStatus decodes the status parameter of the queries, the orphaned records are only selected by asking for them
+func ParseStatus(value string) (api.TransactionStatus, error) {
+	status := api.TransactionStatus(value)
+
+	switch status {
+	case "", api.StatusPending, api.StatusConfirmed, api.StatusFinalized, api.StatusOrphaned:
+		return status, nil
+	default:
+		return "", fmt.Errorf("invalid status %q", value)
+	}
+}
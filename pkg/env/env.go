@@ -38,6 +38,18 @@ func GetEnvDuration(key string, defaultValue time.Duration) time.Duration {
 	return parse
 }
 
+func GetEnvBool(key string, defaultValue bool) bool {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	parse, err := strconv.ParseBool(value)
+	if err != nil {
+		return defaultValue
+	}
+	return parse
+}
+
 func GetEnvValues(key string) []string {
 	value, exists := os.LookupEnv(key)
 	if !exists {
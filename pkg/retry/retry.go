@@ -2,13 +2,43 @@ package retry
 
 import (
 	"context"
+	"errors"
 	"time"
 )
 
 const DefaultMaxAttempts = 5
 const BackoffCoefficient = 2
 
-// retry retries a function with exponential backoff up to a maximum number of attempts
+// Delayer is implemented by errors that tell how long to wait before the next attempt,
+// such as a rate limit with a Retry-After
+type Delayer interface {
+	RetryDelay() time.Duration
+}
+
+// permanentError stops the retries right away
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent wraps an error that is not worth retrying, Retry returns the wrapped error at once
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &permanentError{err: err}
+}
+
+// retry retries a function with exponential backoff up to a maximum number of attempts,
+// unless the error is permanent. An error implementing Delayer can extend the backoff.
 func Retry(ctx context.Context, f func() error, maxAttempts int) error {
 	backoff := 100 * time.Millisecond
 
@@ -19,15 +49,27 @@ func Retry(ctx context.Context, f func() error, maxAttempts int) error {
 			return nil
 		}
 
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			return permanent.err
+		}
+
 		attempt++
 		if attempt >= maxAttempts {
 			return err
 		}
 
+		wait := backoff
+
+		var delayer Delayer
+		if errors.As(err, &delayer) {
+			wait = max(wait, delayer.RetryDelay())
+		}
+
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		case <-time.After(backoff):
+		case <-time.After(wait):
 			backoff *= BackoffCoefficient
 		}
 	}
@@ -124,4 +124,52 @@ func TestRetry(t *testing.T) {
 			t.Errorf("Expected duration of at least %dms, got %v", expectedMinDuration, duration)
 		}
 	})
+
+	t.Run("Permanent error", func(t *testing.T) {
+		attempts := 0
+		permanentErr := errors.New("permanent error")
+		err := retry.Retry(context.Background(), func() error {
+			attempts++
+			return retry.Permanent(permanentErr)
+		}, retry.DefaultMaxAttempts)
+
+		if err != permanentErr {
+			t.Errorf("Expected the permanent error, got %v", err)
+		}
+		if attempts != 1 {
+			t.Errorf("Expected 1 attempt, got %d", attempts)
+		}
+	})
+
+	t.Run("Delayer extends the backoff", func(t *testing.T) {
+		attempts := 0
+		start := time.Now()
+		err := retry.Retry(context.Background(), func() error {
+			attempts++
+			if attempts < 2 {
+				return delayError{delay: 300 * time.Millisecond}
+			}
+			return nil
+		}, retry.DefaultMaxAttempts)
+
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+		if duration := time.Since(start); duration < 300*time.Millisecond {
+			t.Errorf("Expected to wait at least 300ms, waited %v", duration)
+		}
+	})
+}
+
+// delayError asks to wait for delay before the next attempt
+type delayError struct {
+	delay time.Duration
+}
+
+func (e delayError) Error() string {
+	return "slow down"
+}
+
+func (e delayError) RetryDelay() time.Duration {
+	return e.delay
 }
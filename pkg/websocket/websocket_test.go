@@ -0,0 +1,146 @@
+package websocket_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devshark/tx-parser-go/pkg/websocket"
+)
+
+// newEchoServer echoes every message back, after sending a ping first
+func newEchoServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		if err := conn.Ping(); err != nil {
+			t.Errorf("Unexpected error sending ping: %v", err)
+			return
+		}
+
+		for {
+			message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+
+			if err := conn.WriteMessage(message); err != nil {
+				return
+			}
+		}
+	}))
+}
+
+func TestDialAndEcho(t *testing.T) {
+	server := newEchoServer(t)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	conn, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer conn.Close()
+
+	// small, 16-bit and 64-bit length frames
+	for _, size := range []int{5, 1000, 70000} {
+		message := bytes.Repeat([]byte("a"), size)
+
+		if err := conn.WriteMessage(message); err != nil {
+			t.Fatalf("Unexpected error writing %d bytes: %v", size, err)
+		}
+
+		echo, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("Unexpected error reading %d bytes: %v", size, err)
+		}
+
+		if !bytes.Equal(echo, message) {
+			t.Errorf("Expected echo of %d bytes, got %d bytes", size, len(echo))
+		}
+	}
+}
+
+func TestDialErrors(t *testing.T) {
+	ctx := context.Background()
+
+	// Test unsupported scheme
+	if _, err := websocket.Dial(ctx, "http://localhost"); err == nil {
+		t.Fatal("Expected error for unsupported scheme, got nil")
+	}
+
+	// Test a server that does not upgrade
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	if _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http")); err == nil {
+		t.Fatal("Expected error for a bad handshake, got nil")
+	}
+}
+
+func TestUpgradeRejectsPlainRequests(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	if _, err := websocket.Upgrade(recorder, request); err == nil {
+		t.Fatal("Expected error for a plain http request, got nil")
+	}
+
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, recorder.Code)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	// the server never answers, not even the pings
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	conn, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer conn.Close()
+
+	conn.SetTimeout(50 * time.Millisecond)
+
+	if err := conn.Ping(); err != nil {
+		t.Fatalf("Unexpected error sending ping: %v", err)
+	}
+
+	start := time.Now()
+	if _, err := conn.ReadMessage(); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("Expected the read to time out, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the read to time out after 50ms, took %s", elapsed)
+	}
+}